@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/encryption"
+	"github.com/dominiclet/golang-base/lib/password"
+	"github.com/dominiclet/golang-base/service/billing"
+	"github.com/dominiclet/golang-base/service/notification"
+	"github.com/dominiclet/golang-base/service/oauth"
+	"github.com/dominiclet/golang-base/service/user"
+)
+
+func runConfigValidate(cmd *command, args []string) error {
+	parseFlags(newFlagSet(cmd), args, 0)
+
+	path := config.Path()
+	conf, err := config.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	// Keys and files referenced by the config are loaded when the components using them are initialized
+	checks := []struct {
+		component string
+		init      func()
+	}{
+		{"encryption", func() { encryption.InitEncrypter(conf) }},
+		{"password hashing", func() { password.InitHasher(conf) }},
+		{"password policy", func() { password.InitPolicy(conf) }},
+		{"email", func() { email.InitEmailService(conf, env.InitEnvVars(), nil) }},
+		{"notifications", func() { notification.InitNotificationService(nil, nil, nil, conf, env.InitEnvVars()) }},
+		{"oauth", func() {
+			oauth.InitOAuthService(nil, conf, env.InitEnvVars(), user.InitUserService(nil, nil, nil, nil, nil))
+		}},
+		{"billing", func() { billing.InitBillingService(nil, conf, nil, nil) }},
+	}
+	for _, check := range checks {
+		if err := checkInit(check.component, check.init); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Config file %s is valid\n", path)
+	return nil
+}
+
+// Runs initializer of component, returning its panic as error (components panic if their config is invalid)
+func checkInit(component string, initialize func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", component, r)
+		}
+	}()
+	initialize()
+	return nil
+}
@@ -0,0 +1,165 @@
+// Command errcatalog generates the catalog of error codes (docs/errors.json and docs/errors.md)
+// from lib/resperror, and fails if a code has no entry in resperror.ErrorMapping.
+//
+// Run from project root:
+//
+//	go run ./cmd/errcatalog         # Check codes and write catalog
+//	go run ./cmd/errcatalog -check  # Only check codes
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dominiclet/golang-base/lib/resperror"
+)
+
+// Entry of error catalog
+type CatalogEntry struct {
+	Code        resperror.Code `json:"code"`
+	Name        string         `json:"name"`
+	Group       string         `json:"group"`
+	StatusCode  int            `json:"status"`
+	Message     string         `json:"message"`
+	Description string         `json:"description,omitempty"`
+}
+
+func main() {
+	codesFile := flag.String("codes", "lib/resperror/codes.go", "File declaring the error codes")
+	outDir := flag.String("out", "docs", "Directory the catalog is written to")
+	check := flag.Bool("check", false, "Only check that every code is mapped, without writing the catalog")
+	flag.Parse()
+
+	catalog, problems, err := buildCatalog(*codesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read error codes: %v\n", err)
+		os.Exit(1)
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		os.Exit(1)
+	}
+	if *check {
+		fmt.Printf("All %d error codes are mapped\n", len(catalog))
+		return
+	}
+
+	if err := writeJSON(filepath.Join(*outDir, "errors.json"), catalog); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write JSON catalog: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeMarkdown(filepath.Join(*outDir, "errors.md"), catalog); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write Markdown catalog: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote catalog of %d error codes to %s\n", len(catalog), *outDir)
+}
+
+// Reads codes from the const declarations of codesFile in order and looks up their mapping.
+// Returns the catalog and a description of each code that is not mapped correctly
+func buildCatalog(codesFile string) ([]CatalogEntry, []string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), codesFile, nil, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var catalog []CatalogEntry
+	var problems []string
+	names := map[resperror.Code]string{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		group := strings.TrimSpace(genDecl.Doc.Text())
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				lit, ok := valueSpec.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.INT {
+					problems = append(problems, fmt.Sprintf("%s: value must be an integer literal", name.Name))
+					continue
+				}
+				value, err := strconv.Atoi(lit.Value)
+				if err != nil {
+					return nil, nil, err
+				}
+				code := resperror.Code(value)
+				if other, ok := names[code]; ok {
+					problems = append(problems, fmt.Sprintf("%s: code %d is also used by %s", name.Name, code, other))
+				}
+				names[code] = name.Name
+
+				mapping, ok := resperror.ErrorMapping[code]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s: code %d missing from ErrorMapping", name.Name, code))
+					continue
+				}
+				if mapping.Code != code {
+					problems = append(problems, fmt.Sprintf("%s: ErrorMapping entry has code %d", name.Name, mapping.Code))
+				}
+				if http.StatusText(mapping.StatusCode) == "" {
+					problems = append(problems, fmt.Sprintf("%s: invalid status code %d", name.Name, mapping.StatusCode))
+				}
+				catalog = append(catalog, CatalogEntry{
+					Code:        code,
+					Name:        name.Name,
+					Group:       group,
+					StatusCode:  mapping.StatusCode,
+					Message:     mapping.Message,
+					Description: strings.TrimSpace(valueSpec.Comment.Text()),
+				})
+			}
+		}
+	}
+	for code := range resperror.ErrorMapping {
+		if _, ok := names[code]; !ok {
+			problems = append(problems, fmt.Sprintf("ErrorMapping has entry for undeclared code %d", code))
+		}
+	}
+	return catalog, problems, nil
+}
+
+func writeJSON(path string, catalog []CatalogEntry) error {
+	content, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+func writeMarkdown(path string, catalog []CatalogEntry) error {
+	var buf bytes.Buffer
+	buf.WriteString("<!-- Code generated by go run ./cmd/errcatalog. DO NOT EDIT. -->\n\n")
+	buf.WriteString("# Error codes\n\n")
+	buf.WriteString("Error responses carry one of these codes in their `code` field. ")
+	buf.WriteString("Problem details responses have type URIs ending in the code.\n")
+	group := ""
+	for i, entry := range catalog {
+		if i == 0 || entry.Group != group {
+			group = entry.Group
+			fmt.Fprintf(&buf, "\n## %s\n\n", group)
+			buf.WriteString("| Code | Name | Status | Message | Description |\n")
+			buf.WriteString("| --- | --- | --- | --- | --- |\n")
+		}
+		fmt.Fprintf(&buf, "| %d | `%s` | %d %s | %s | %s |\n", entry.Code, entry.Name,
+			entry.StatusCode, http.StatusText(entry.StatusCode), escapeCell(entry.Message), escapeCell(entry.Description))
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func escapeCell(text string) string {
+	return strings.ReplaceAll(text, "|", "\\|")
+}
@@ -0,0 +1,121 @@
+// Command i18ncheck lists messages missing from the message catalogs of package i18n, and email
+// templates missing from the locale directories of the built-in email templates. Exits with
+// status 1 if anything is missing.
+//
+// Run from project root:
+//
+//	go run ./cmd/i18ncheck
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dominiclet/golang-base/lib/i18n"
+	"github.com/dominiclet/golang-base/lib/resperror"
+)
+
+// Template files that are shared by all locales, with texts from the message catalogs
+var sharedTemplates = map[string]bool{
+	"layout.txt":  true,
+	"layout.html": true,
+	"footer.txt":  true,
+	"footer.html": true,
+}
+
+func main() {
+	templateDir := flag.String("templates", "lib/email/templates", "Directory of the built-in email templates")
+	flag.Parse()
+
+	missing := checkCatalogs()
+	templatesMissing, err := checkTemplates(*templateDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to check email templates: %v\n", err)
+		os.Exit(1)
+	}
+	missing += templatesMissing
+	if missing > 0 {
+		fmt.Printf("%d translations missing\n", missing)
+		os.Exit(1)
+	}
+	fmt.Println("No translations missing")
+}
+
+// Compares catalogs with the default catalog, and the messages of error codes which are in
+// resperror.ErrorMapping for the default language. Returns no. of missing messages
+func checkCatalogs() int {
+	reference := map[string]bool{}
+	for _, key := range i18n.Keys(i18n.DefaultLanguage) {
+		reference[key] = true
+	}
+	for code := range resperror.ErrorMapping {
+		reference[i18n.ErrorKey(int(code))] = true
+	}
+
+	missingCount := 0
+	for _, lang := range i18n.Languages()[1:] {
+		keys := map[string]bool{}
+		for _, key := range i18n.Keys(lang) {
+			keys[key] = true
+		}
+		var missing, unknown []string
+		for key := range reference {
+			if !keys[key] {
+				missing = append(missing, key)
+			}
+		}
+		for key := range keys {
+			if !reference[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		report(fmt.Sprintf("Catalog %s: missing", lang), missing)
+		report(fmt.Sprintf("Catalog %s: not in %s catalog (unused)", lang, i18n.DefaultLanguage), unknown)
+		missingCount += len(missing)
+	}
+	return missingCount
+}
+
+// Compares template files in locale subdirectories with the default templates at the top level.
+// Returns no. of missing files
+func checkTemplates(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var templates, locales []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			locales = append(locales, entry.Name())
+		} else if !sharedTemplates[entry.Name()] {
+			templates = append(templates, entry.Name())
+		}
+	}
+
+	missingCount := 0
+	for _, locale := range locales {
+		var missing []string
+		for _, name := range templates {
+			if _, err := os.Stat(filepath.Join(dir, locale, name)); os.IsNotExist(err) {
+				missing = append(missing, name)
+			} else if err != nil {
+				return 0, err
+			}
+		}
+		report(fmt.Sprintf("Email templates %s: missing", locale), missing)
+		missingCount += len(missing)
+	}
+	return missingCount, nil
+}
+
+func report(title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	sort.Strings(items)
+	fmt.Printf("%s (%d):\n  %s\n", title, len(items), strings.Join(items, "\n  "))
+}
@@ -1,41 +1,108 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 
-	docs "github.com/dominiclet/golang-base/docs"
-	initserver "github.com/dominiclet/golang-base/init_server"
-	"github.com/dominiclet/golang-base/init_server/env"
-	"github.com/dominiclet/golang-base/init_server/logger"
-	"github.com/gin-gonic/gin"
-	swaggerfiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/lib/password"
+	"github.com/dominiclet/golang-base/lib/resperror"
 )
 
-const (
-	PORT = "8080"
-)
+// Subcommand of the CLI, eg. "user create"
+type command struct {
+	name        string
+	args        string // Shown in usage
+	description string
+	run         func(cmd *command, args []string) error
+}
+
+var commands = []command{
+	{"serve", "[-port port]", "Start the server", runServe},
+	{"migrate up", "", "Apply pending migrations", runMigrateUp},
+	{"migrate down", "[n]", "Roll back the last n migrations (defaults to 1)", runMigrateDown},
+	{"migrate status", "", "List migrations and when they were applied", runMigrateStatus},
+	{"migrate baseline", "", "Mark the baseline as applied on a DB created before migrations were used", runMigrateBaseline},
+	{"user create", "-name name -email email [-language tag] [-verified] [-admin]", "Create a user with the password read from stdin", runUserCreate},
+	{"user verify", "email", "Mark the email of a user as verified", runUserVerify},
+	{"user set-password", "email", "Set the password of a user to the password read from stdin, logging them out everywhere", runUserSetPassword},
+	{"user set-license", "-type trial|basic|test [-expiry YYYY-MM-DD] email", "Set the account type and license expiry of a user", runUserSetLicense},
+	{"session revoke", "email", "Log a user out of all sessions and revoke their OAuth tokens", runSessionRevoke},
+	{"config validate", "", "Check the config file", runConfigValidate},
+}
 
 // @title Golang base server
 // @version 1.0
 
 func main() {
-	if !env.IsDevDirect() {
-		gin.SetMode(gin.ReleaseMode)
+	configPath := flag.String("config", "", "Path of config file (defaults to $CONFIG_PATH or /opt/backend/config.yaml)")
+	flag.Usage = usage
+	flag.Parse()
+	if *configPath != "" {
+		os.Setenv(config.PathEnvVar, *configPath)
 	}
 
-	logger := logger.InitLogger()
+	cmd, args := findCommand(flag.Args())
+	if cmd == nil {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(cmd, args); err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+}
 
-	router := initserver.InitDeps()
+// Finds the command named by the first args. Returns the command and its remaining args
+func findCommand(args []string) (*command, []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, nil
+}
 
-	r := gin.Default()
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [-config path] <command> [args]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %s\n    \t%s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.description)
+	}
+}
 
-	router.RegisterRoutes(r)
+func printError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if customErr, ok := resperror.As(err); ok {
+		if violations, ok := customErr.Details.([]password.Violation); ok {
+			for _, violation := range violations {
+				fmt.Fprintf(os.Stderr, "  %s\n", violation.Message)
+			}
+		}
+	}
+}
 
-	// Register path for swagger
-	docs.SwaggerInfo.BasePath = "/api"
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
+// Parses flags of command. Exits with usage on invalid flags or if the no. of positional args differs from nArgs
+func parseFlags(flags *flag.FlagSet, args []string, nArgs int) {
+	flags.Parse(args)
+	if nArgs >= 0 && flags.NArg() != nArgs {
+		flags.Usage()
+		os.Exit(2)
+	}
+}
 
-	logger.WithField("port", PORT).Info("Starting server")
-	r.Run(fmt.Sprintf(":%s", PORT))
+// Creates flag set of command with usage showing its args
+func newFlagSet(cmd *command) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s\n", os.Args[0], strings.TrimSpace(cmd.name+" "+cmd.args))
+		flags.PrintDefaults()
+	}
+	return flags
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	initserver "github.com/dominiclet/golang-base/init_server"
+	"github.com/dominiclet/golang-base/init_server/migrate"
+)
+
+// Version of the migration with the schema from before migrations were used
+const baselineVersion = 1
+
+func runMigrateUp(cmd *command, args []string) error {
+	parseFlags(newFlagSet(cmd), args, 0)
+
+	applied, err := initserver.InitMigrator().Up(context.Background())
+	printMigrations("Applied", applied)
+	if err == nil && len(applied) == 0 {
+		fmt.Println("No pending migrations")
+	}
+	return err
+}
+
+func runMigrateDown(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	parseFlags(flags, args, -1)
+	n := 1
+	if flags.NArg() > 0 {
+		var err error
+		n, err = strconv.Atoi(flags.Arg(0))
+		if err != nil || n < 1 || flags.NArg() > 1 {
+			return errors.New("number of migrations to roll back must be a positive integer")
+		}
+	}
+
+	rolledBack, err := initserver.InitMigrator().Down(context.Background(), n)
+	printMigrations("Rolled back", rolledBack)
+	if err == nil && len(rolledBack) == 0 {
+		fmt.Println("No migrations to roll back")
+	}
+	return err
+}
+
+func runMigrateStatus(cmd *command, args []string) error {
+	parseFlags(newFlagSet(cmd), args, 0)
+
+	statuses, err := initserver.InitMigrator().Status(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if status.Modified {
+			state += " (modified since applied)"
+		}
+		if status.Unknown {
+			state += " (not in this binary)"
+		}
+		fmt.Printf("%d_%s\t%s\n", status.Version, status.Name, state)
+	}
+	return nil
+}
+
+func runMigrateBaseline(cmd *command, args []string) error {
+	parseFlags(newFlagSet(cmd), args, 0)
+
+	if err := initserver.InitMigrator().Baseline(context.Background(), baselineVersion); err != nil {
+		return err
+	}
+	fmt.Printf("Marked migrations up to %d as applied\n", baselineVersion)
+	return nil
+}
+
+func printMigrations(action string, migrations []migrate.Migration) {
+	for _, migration := range migrations {
+		fmt.Printf("%s %d_%s\n", action, migration.Version, migration.Name)
+	}
+}
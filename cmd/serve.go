@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	docs "github.com/dominiclet/golang-base/docs"
+	initserver "github.com/dominiclet/golang-base/init_server"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/gin-gonic/gin"
+	swaggerfiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+const (
+	defaultPort = "8080"
+	// Time in-flight requests are given to complete on shutdown
+	shutdownTimeout = 30 * time.Second
+)
+
+func runServe(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	port := flags.String("port", defaultPort, "Port to listen on")
+	parseFlags(flags, args, 0)
+
+	if !env.IsDevDirect() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	logger := logger.InitLogger()
+
+	router := initserver.InitDeps()
+	httpresp.RegisterValidatorFieldNames()
+
+	r := gin.Default()
+	if err := r.SetTrustedProxies(router.TrustedProxies()); err != nil {
+		router.Close()
+		return err
+	}
+
+	router.RegisterRoutes(r)
+
+	// Register path for swagger
+	docs.SwaggerInfo.BasePath = "/api"
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", *port),
+		Handler: r,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.WithField("port", *port).Info("Starting server")
+		serveErr <- server.ListenAndServe()
+	}()
+
+	// Stop gracefully on interrupt, so that in-flight requests complete and buffered data is written
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
+		router.Close()
+		return err
+	case <-quit:
+	}
+
+	logger.Info("Shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	router.Close()
+	return err
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	initserver "github.com/dominiclet/golang-base/init_server"
+)
+
+func runSessionRevoke(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	parseFlags(flags, args, 1)
+
+	ctx := context.Background()
+	services := initserver.InitServices()
+	u, err := findUser(ctx, services.UserService, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	count, err := services.SessionService.RevokeUserSessions(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+	if !services.OAuthService.Enabled() {
+		fmt.Printf("Revoked %d sessions of %s\n", count, u.Email)
+		return nil
+	}
+	if err := services.OAuthService.RevokeUserTokens(ctx, u.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked %d sessions and all OAuth tokens of %s\n", count, u.Email)
+	return nil
+}
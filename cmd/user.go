@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	initserver "github.com/dominiclet/golang-base/init_server"
+	"github.com/dominiclet/golang-base/service/user"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// Account types by name used in the CLI
+var accountTypes = map[string]user.AccountType{
+	"trial": user.TrialAccount,
+	"basic": user.BasicAccount,
+	"test":  user.TestAccount,
+}
+
+func runUserCreate(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	name := flags.String("name", "", "Name of user")
+	email := flags.String("email", "", "Email of user")
+	lang := flags.String("language", "", "Preferred language of emails (BCP 47 tag). Default language is used if not set")
+	verified := flags.Bool("verified", false, "Mark email as verified instead of sending a verification email")
+	admin := flags.Bool("admin", false, "Grant admin rights")
+	parseFlags(flags, args, 0)
+	if *name == "" || *email == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *lang != "" {
+		if _, err := language.Parse(*lang); err != nil {
+			return fmt.Errorf("invalid language tag %s", *lang)
+		}
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	userService := initserver.InitServices().UserService
+	var u *user.User
+	if *verified {
+		u, err = userService.CreateVerifiedUser(ctx, *name, *email, password, *lang)
+	} else {
+		u, err = userService.CreateUser(ctx, *name, *email, password, *lang)
+	}
+	if err != nil {
+		return err
+	}
+	if *admin {
+		if err := userService.SetAdmin(ctx, u, true); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Created user %s\n", u.Uuid)
+	return nil
+}
+
+func runUserVerify(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	parseFlags(flags, args, 1)
+
+	ctx := context.Background()
+	userService := initserver.InitServices().UserService
+	u, err := findUser(ctx, userService, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := userService.MarkVerified(ctx, u); err != nil {
+		return err
+	}
+	fmt.Printf("Verified %s\n", u.Email)
+	return nil
+}
+
+func runUserSetPassword(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	parseFlags(flags, args, 1)
+
+	ctx := context.Background()
+	userService := initserver.InitServices().UserService
+	u, err := findUser(ctx, userService, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	if err := userService.SetPassword(ctx, u, password); err != nil {
+		return err
+	}
+	fmt.Printf("Set password of %s\n", u.Email)
+	return nil
+}
+
+func runUserSetLicense(cmd *command, args []string) error {
+	flags := newFlagSet(cmd)
+	accountTypeName := flags.String("type", "", "Account type (trial, basic or test)")
+	expiryDate := flags.String("expiry", "", "Date the license expires (YYYY-MM-DD, UTC). Unchanged if not set")
+	parseFlags(flags, args, 1)
+	accountType, ok := accountTypes[*accountTypeName]
+	if !ok {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	userService := initserver.InitServices().UserService
+	u, err := findUser(ctx, userService, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	expiry := u.LicenseExpiry
+	if *expiryDate != "" {
+		if expiry, err = time.Parse("2006-01-02", *expiryDate); err != nil {
+			return fmt.Errorf("invalid expiry date %s", *expiryDate)
+		}
+	}
+	if err := userService.SetLicense(ctx, u, accountType, expiry); err != nil {
+		return err
+	}
+	fmt.Printf("Set license of %s to %s (expires %s)\n", u.Email, accountType, expiry.Format("2006-01-02"))
+	return nil
+}
+
+func findUser(ctx context.Context, userService *user.UserService, email string) (*user.User, error) {
+	u, err := userService.GetUserByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("there is no user with email %s", email)
+	}
+	return u, err
+}
+
+// Reads password from first line of stdin, so that it is not kept in shell history
+func readPassword() (string, error) {
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "Password: ")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no password given on stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
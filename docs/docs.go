@@ -16,28 +16,41 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/session/login": {
-            "post": {
-                "description": "Create login session for user",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/email_outbox": {
+            "get": {
+                "description": "List emails in outbox, most recent first (admin endpoint). Failed emails that were given up on have status dead, and emails to suppressed addresses have status suppressed",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "session"
+                    "email",
+                    "authRequired"
                 ],
-                "summary": "User login",
+                "summary": "List queued emails",
                 "parameters": [
                     {
-                        "description": "Email and password for authentication",
-                        "name": "req",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/session.UserLoginRequest"
-                        }
+                        "enum": [
+                            "pending",
+                            "sent",
+                            "dead",
+                            "suppressed"
+                        ],
+                        "type": "string",
+                        "description": "Filter by status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max. no. of emails to return (default 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "No. of emails to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -52,21 +65,18 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/session.UserLoginResponse"
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/email.OutboxMessage"
+                                            }
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "401": {
-                        "description": "Authentication failed",
-                        "schema": {
-                            "$ref": "#/definitions/httpresp.StandardResponse"
-                        }
-                    },
                     "403": {
-                        "description": "User is not verified",
+                        "description": "Not an admin",
                         "schema": {
                             "$ref": "#/definitions/httpresp.StandardResponse"
                         }
@@ -74,28 +84,24 @@ const docTemplate = `{
                 }
             }
         },
-        "/user": {
+        "/admin/email_outbox/{id}/retry": {
             "post": {
-                "description": "Create new user",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Queue pending email for immediate delivery, resetting its attempts (admin endpoint). Suppressed and dead emails cannot be retried, as their content is removed",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "email",
+                    "authRequired"
                 ],
-                "summary": "Create user",
+                "summary": "Retry queued email",
                 "parameters": [
                     {
-                        "description": "Create user data",
-                        "name": "req",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/user.CreateUserRequest"
-                        }
+                        "type": "integer",
+                        "description": "Email ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -110,15 +116,21 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/user.CreateUserResponse"
+                                            "$ref": "#/definitions/email.OutboxMessage"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
+                    "404": {
+                        "description": "Email not found",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
                     "409": {
-                        "description": "User with same email already exists",
+                        "description": "Email already sent, suppressed or dead",
                         "schema": {
                             "$ref": "#/definitions/httpresp.StandardResponse"
                         }
@@ -126,33 +138,61 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/reset_password": {
-            "post": {
-                "description": "Starts reset password process by generating and sending 6-digit token to provided email if account exists",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/email_suppressions": {
+            "get": {
+                "description": "List addresses that are not emailed because of bounces or complaints, most recent first (admin endpoint)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "email",
+                    "authRequired"
                 ],
-                "summary": "Reset password",
+                "summary": "List suppressed addresses",
                 "parameters": [
                     {
-                        "description": "Specify email of account to reset",
-                        "name": "req",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/user.ResetPasswordRequest"
-                        }
+                        "type": "string",
+                        "description": "Only return suppression of this address",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max. no. of addresses to return (default 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "No. of addresses to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/email.Suppression"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin",
                         "schema": {
                             "$ref": "#/definitions/httpresp.StandardResponse"
                         }
@@ -160,28 +200,24 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/reset_password/set_password": {
-            "post": {
-                "description": "Final API call for reset password process. Set new password, authenticating using auth code.",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/email_suppressions/{id}": {
+            "delete": {
+                "description": "Remove address from suppression list, so that it is emailed again (admin endpoint)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "email",
+                    "authRequired"
                 ],
-                "summary": "Set new password",
+                "summary": "Clear suppressed address",
                 "parameters": [
                     {
-                        "description": "Specify new password for account",
-                        "name": "req",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/user.SetNewPWRequest"
-                        }
+                        "type": "integer",
+                        "description": "Suppression ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -191,8 +227,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/httpresp.StandardResponse"
                         }
                     },
-                    "401": {
-                        "description": "Auth code rejected",
+                    "404": {
+                        "description": "Suppression not found",
                         "schema": {
                             "$ref": "#/definitions/httpresp.StandardResponse"
                         }
@@ -200,9 +236,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/reset_password/token_exchange": {
+        "/billing/webhook": {
             "post": {
-                "description": "Verify 6-digit token and exchange token with auth code to set new password",
+                "description": "Receives subscription events from the configured payment provider and updates user licenses accordingly. Requests must be signed by the provider",
                 "consumes": [
                     "application/json"
                 ],
@@ -210,20 +246,48 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "billing"
                 ],
-                "summary": "Reset password auth code exchange",
-                "parameters": [
-                    {
-                        "description": "Specify both email and token",
-                        "name": "req",
-                        "in": "body",
-                        "required": true,
+                "summary": "Payment provider webhook",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/user.ResetPWAuthCodeExchangeRequest"
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payload",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid signature",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Billing not configured",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
                         }
                     }
+                }
+            }
+        },
+        "/dev/email_preview": {
+            "get": {
+                "description": "List names of email templates that can be previewed (dev mode only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "email",
+                    "dev"
                 ],
+                "summary": "List email templates",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -236,61 +300,64 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/user.ResetPWAuthCodeExchangeResponse"
+                                            "type": "array",
+                                            "items": {
+                                                "type": "string"
+                                            }
                                         }
                                     }
                                 }
                             ]
                         }
-                    },
-                    "401": {
-                        "description": "Token rejected",
-                        "schema": {
-                            "$ref": "#/definitions/httpresp.StandardResponse"
-                        }
                     }
                 }
             }
         },
-        "/user/verify/resend_email": {
-            "post": {
-                "description": "Resends verification email while invalidating previous verification link",
-                "consumes": [
-                    "application/json"
-                ],
+        "/dev/email_preview/{template}": {
+            "get": {
+                "description": "Render email template with example data. Templates are reloaded on every request, so that changes to templates can be previewed without restarting (dev mode only)",
                 "produces": [
-                    "application/json"
+                    "text/html"
                 ],
                 "tags": [
-                    "user"
+                    "email",
+                    "dev"
                 ],
-                "summary": "Resned verification email",
+                "summary": "Preview email template",
                 "parameters": [
                     {
-                        "description": "Email and password must be provided to authenticate before sending",
-                        "name": "req",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/user.ResendVerificationEmailRequest"
-                        }
+                        "type": "string",
+                        "description": "Template name",
+                        "name": "template",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale of template (eg. de)",
+                        "name": "locale",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "html",
+                            "text"
+                        ],
+                        "type": "string",
+                        "description": "Body to show (default html)",
+                        "name": "format",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/httpresp.StandardResponse"
-                        }
-                    },
-                    "405": {
-                        "description": "User already verified",
+                        "description": "Rendered email",
                         "schema": {
-                            "$ref": "#/definitions/httpresp.StandardResponse"
+                            "type": "string"
                         }
                     },
-                    "429": {
-                        "description": "Too many requests made in a short period of time (2 mins)",
+                    "404": {
+                        "description": "Template not found",
                         "schema": {
                             "$ref": "#/definitions/httpresp.StandardResponse"
                         }
@@ -298,49 +365,17 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/verify/{userUuid}/{token}": {
+        "/dev/mailbox": {
             "get": {
-                "description": "Handles verification link for email",
-                "tags": [
-                    "user"
-                ],
-                "summary": "Verify email",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "userUuid",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Verification token",
-                        "name": "token",
-                        "in": "query",
-                        "required": true
-                    }
+                "description": "List emails sent recently, most recent first (dev mode only)",
+                "produces": [
+                    "application/json"
                 ],
-                "responses": {}
-            }
-        },
-        "/user/{uuid}": {
-            "get": {
-                "description": "Get basic user information (protected endpoint)",
                 "tags": [
-                    "user",
-                    "authRequired"
-                ],
-                "summary": "Get basic user information",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "UUID",
-                        "name": "uuid",
-                        "in": "query",
-                        "required": true
-                    }
+                    "email",
+                    "dev"
                 ],
+                "summary": "List emails in dev mailbox",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -353,63 +388,2486 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/handler_user.User"
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/email.MailboxMessage"
+                                            }
                                         }
                                     }
                                 }
                             ]
                         }
-                    },
-                    "404": {
-                        "description": "User not found",
+                    }
+                }
+            },
+            "delete": {
+                "description": "Discard all emails in dev mailbox (dev mode only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "email",
+                    "dev"
+                ],
+                "summary": "Clear dev mailbox",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/httpresp.StandardResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handler_user.User": {
-            "type": "object",
-            "properties": {
-                "account_type": {
-                    "type": "integer"
-                },
+        },
+        "/dev/mailbox/{id}": {
+            "get": {
+                "description": "Show HTML or text body of email, or the full message in MIME format (dev mode only)",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "email",
+                    "dev"
+                ],
+                "summary": "View email in dev mailbox",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Email ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "html",
+                            "text",
+                            "raw"
+                        ],
+                        "type": "string",
+                        "description": "Part of email to show (default html)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Email body",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Email not in mailbox",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/email/notifications": {
+            "post": {
+                "description": "Receives bounce and complaint notifications, either as JSON or as delivery status notification (RFC 3464, as complete message/rfc822 or multipart/report body). Permanently bouncing addresses and addresses that complained are added to the suppression list. Requests must carry the configured secret as bearer token",
+                "consumes": [
+                    "application/json",
+                    "message/rfc822",
+                    "multipart/report"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "email"
+                ],
+                "summary": "Bounce and complaint notifications",
+                "parameters": [
+                    {
+                        "description": "Notification (if JSON)",
+                        "name": "req",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/suppression.Notification"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid notification",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Notifications not configured",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/unsubscribe": {
+            "get": {
+                "description": "Handles unsubscribe link of notification emails by showing a page to confirm unsubscribing",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "notification"
+                ],
+                "summary": "Unsubscribe page",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "user",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Notification category",
+                        "name": "category",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of link",
+                        "name": "sig",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {}
+            },
+            "post": {
+                "description": "Disables notification category with the signed link of a notification email. Also used by mail clients for one-click unsubscribe (RFC 8058)",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "notification"
+                ],
+                "summary": "Unsubscribe",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "user",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Notification category",
+                        "name": "category",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of link",
+                        "name": "sig",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {}
+            }
+        },
+        "/oauth/.well-known/openid-configuration": {
+            "get": {
+                "description": "OpenID provider metadata for apps authenticating against this server",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "OpenID Connect discovery",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.DiscoveryDocument"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/authorize": {
+            "get": {
+                "description": "Starts authorization code flow (PKCE required). Users without a session are redirected to the login page, and users who have not granted consent to the consent page",
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Authorization endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Must be code",
+                        "name": "response_type",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "client_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Registered redirect URI",
+                        "name": "redirect_uri",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Space-delimited scopes",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "State",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Nonce",
+                        "name": "nonce",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE code challenge",
+                        "name": "code_challenge",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Must be S256",
+                        "name": "code_challenge_method",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "400": {
+                        "description": "Invalid client or redirect URI",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/clients": {
+            "get": {
+                "description": "List registered OAuth clients (admin endpoint)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth",
+                    "authRequired"
+                ],
+                "summary": "List OAuth clients",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/oauth.Client"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Register an app that authenticates users against this server. Client secret is only returned once (admin endpoint)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth",
+                    "authRequired"
+                ],
+                "summary": "Register OAuth client",
+                "parameters": [
+                    {
+                        "description": "Client details",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/oauth.RegisterClientRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/oauth.RegisterClientResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/clients/{clientId}": {
+            "delete": {
+                "description": "Delete OAuth client and revoke all its tokens (admin endpoint)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth",
+                    "authRequired"
+                ],
+                "summary": "Delete OAuth client",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "clientId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Client not found",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/consent/{requestId}": {
+            "get": {
+                "description": "Get client and scopes of pending consent request (protected endpoint)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth",
+                    "authRequired"
+                ],
+                "summary": "Get consent request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Consent request ID",
+                        "name": "requestId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/oauth.ConsentRequestResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Consent request not found",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Grant or deny consent. Returns the client URL to redirect the user back to (protected endpoint)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth",
+                    "authRequired"
+                ],
+                "summary": "Decide consent request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Consent request ID",
+                        "name": "requestId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Whether consent is granted",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/oauth.ConsentDecisionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/oauth.RedirectResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Consent request not found",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/introspect": {
+            "post": {
+                "description": "Get state of token (RFC 7662)",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Token introspection",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token to introspect",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.IntrospectionResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Client authentication failed",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/jwks": {
+            "get": {
+                "description": "Public keys used to verify ID tokens",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "JSON web key set",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jwt.JSONWebKeySet"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/revoke": {
+            "post": {
+                "description": "Revoke access or refresh token (RFC 7009)",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Token revocation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token to revoke",
+                        "name": "token",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "Client authentication failed",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/token": {
+            "post": {
+                "description": "Exchange authorization code or refresh token for tokens",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Token endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "authorization_code or refresh_token",
+                        "name": "grant_type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Redirect URI used in authorization request",
+                        "name": "redirect_uri",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "PKCE code verifier",
+                        "name": "code_verifier",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Refresh token",
+                        "name": "refresh_token",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Narrowed scope (refresh only)",
+                        "name": "scope",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Client authentication failed",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/oauth/userinfo": {
+            "get": {
+                "description": "Get claims about the user the bearer access token was issued for",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "oauth"
+                ],
+                "summary": "Userinfo",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/oauth.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/session/login": {
+            "post": {
+                "description": "Create login session for user. If user has 2FA enabled, a challenge token is returned instead (see /session/login/2fa)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "User login",
+                "parameters": [
+                    {
+                        "description": "Email and password for authentication",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/session.UserLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/session.UserLoginResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid email or password",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "User is not verified",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed login attempts (see Retry-After header)",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/resperror.RetryDetails"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/session/login/2fa": {
+            "post": {
+                "description": "Exchange challenge token from login and TOTP code (or recovery code) for login session",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "Two-factor login",
+                "parameters": [
+                    {
+                        "description": "Challenge token and code",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/session.TwoFactorLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/session.UserLoginResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid challenge token or code",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed login attempts (see Retry-After header)",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/resperror.RetryDetails"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "503": {
+                        "description": "2FA not configured",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/session/magic_link": {
+            "post": {
+                "description": "Sends a single-use login link to the email if an account with the email exists",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "Request magic link",
+                "parameters": [
+                    {
+                        "description": "Email to send link to",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/session.MagicLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/session/magic_link/consume": {
+            "get": {
+                "description": "Exchanges magic link token for login session. If user has 2FA enabled, a challenge token is returned instead",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "Consume magic link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Magic link token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/session.UserLoginResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid token",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/session/oidc/providers": {
+            "get": {
+                "description": "Get names of external identity providers that users can log in with",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "List external identity providers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/session.OIDCProvidersResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/session/oidc/{provider}/callback": {
+            "get": {
+                "description": "Completes login after the external identity provider redirects back. If user has 2FA enabled, a challenge token is returned instead of a session",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "External identity provider callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/session.UserLoginResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Login failed",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Email not verified by provider",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/session/oidc/{provider}/login": {
+            "get": {
+                "description": "Redirects to the login page of the external identity provider",
+                "tags": [
+                    "session"
+                ],
+                "summary": "Log in with external identity provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "404": {
+                        "description": "Unknown provider",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/session/unlock": {
+            "get": {
+                "description": "Lifts lockout of account with the single-use link sent by email when the account was locked",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "session"
+                ],
+                "summary": "Unlock account",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unlock token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or expired unlock link",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user": {
+            "post": {
+                "description": "Create new user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Create user",
+                "parameters": [
+                    {
+                        "description": "Create user data",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/user.CreateUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Password does not meet requirements",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/password.Violation"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "409": {
+                        "description": "User with same email already exists",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/2fa/confirm": {
+            "post": {
+                "description": "Enable 2FA by verifying the first TOTP code. Returns recovery codes which are only shown once (protected endpoint)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Confirm 2FA enrollment",
+                "parameters": [
+                    {
+                        "description": "TOTP code",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/twofactor.CodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/twofactor.ConfirmEnrollmentResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "2FA not configured",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/2fa/disable": {
+            "post": {
+                "description": "Disable 2FA after verifying a TOTP or recovery code (protected endpoint)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Disable 2FA",
+                "parameters": [
+                    {
+                        "description": "TOTP or recovery code",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/twofactor.CodeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "2FA not configured",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/2fa/enroll": {
+            "post": {
+                "description": "Generate TOTP secret for logged in user. 2FA is enabled only after enrollment is confirmed (protected endpoint)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Begin 2FA enrollment",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/twofactor.BeginEnrollmentResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "409": {
+                        "description": "2FA already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "2FA not configured",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/entitlements": {
+            "get": {
+                "description": "Get features and limits included in the plan of the logged in user (protected endpoint)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Get entitlements of current user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/user.EntitlementsResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/export": {
+            "get": {
+                "description": "Export account data of the logged in user (protected endpoint). Requires the export feature, and counts towards the export quota of the plan",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Export data of current user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/user.ExportResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Plan does not include exports",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Export quota exceeded (see Retry-After header)",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/language": {
+            "put": {
+                "description": "Set preferred language of emails sent to the logged in user (protected endpoint)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Set language of current user",
+                "parameters": [
+                    {
+                        "description": "BCP 47 language tag (eg. en or de)",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.SetLanguageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid language tag",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/notifications": {
+            "get": {
+                "description": "Get whether the user receives emails of each notification category (protected endpoint)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Get notification preferences",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/handler_notification.NotificationPreference"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Enable or disable emails of a notification category (protected endpoint). Security emails cannot be disabled",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Set notification preference",
+                "parameters": [
+                    {
+                        "description": "Category and whether it is enabled",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/notification.SetPreferenceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid category, or security emails cannot be disabled",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me/usage": {
+            "get": {
+                "description": "Get usage of metered resources and the limits of the logged in user for the current period (protected endpoint)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Get usage of current user",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/user.UsageSummaryResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/reset_password": {
+            "post": {
+                "description": "Starts reset password process by sending 6-character code (and single-click reset link if configured) to provided email if account exists",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Reset password",
+                "parameters": [
+                    {
+                        "description": "Specify email of account to reset",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.ResetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/reset_password/set_password": {
+            "post": {
+                "description": "Final API call for reset password process. Set new password, authenticating using auth code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Set new password",
+                "parameters": [
+                    {
+                        "description": "Specify new password for account",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.SetNewPWRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Password does not meet requirements",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/password.Violation"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Auth code rejected",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/reset_password/token_exchange": {
+            "post": {
+                "description": "Verify 6-character code or token from reset link, and exchange it for auth code to set new password. Code is invalidated after too many failed attempts",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Reset password auth code exchange",
+                "parameters": [
+                    {
+                        "description": "Specify both email and token",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.ResetPWAuthCodeExchangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/user.ResetPWAuthCodeExchangeResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Token rejected",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/verify/resend_email": {
+            "post": {
+                "description": "Resends verification email while invalidating previous verification link",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Resned verification email",
+                "parameters": [
+                    {
+                        "description": "Email and password must be provided to authenticate before sending",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.ResendVerificationEmailRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "User already verified",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests made in a short period of time (2 mins)",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/verify/{userUuid}/{token}": {
+            "get": {
+                "description": "Handles verification link for email",
+                "tags": [
+                    "user"
+                ],
+                "summary": "Verify email",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "userUuid",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Verification token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {}
+            }
+        },
+        "/user/{uuid}": {
+            "get": {
+                "description": "Get basic user information (protected endpoint)",
+                "tags": [
+                    "user",
+                    "authRequired"
+                ],
+                "summary": "Get basic user information",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID",
+                        "name": "uuid",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/httpresp.StandardDataResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/handler_user.User"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/httpresp.StandardResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "email.MailboxMessage": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "email.OutboxMessage": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                }
+            }
+        },
+        "email.Suppression": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler_notification.NotificationPreference": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "$ref": "#/definitions/notification.Category"
+                },
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handler_user.User": {
+            "type": "object",
+            "properties": {
+                "account_type": {
+                    "type": "integer"
+                },
                 "email": {
                     "type": "string"
                 },
-                "is_verified": {
+                "is_verified": {
+                    "type": "boolean"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "two_factor_enabled": {
+                    "type": "boolean"
+                },
+                "uuid": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpresp.StandardDataResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer"
+                },
+                "data": {},
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "httpresp.StandardResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/resperror.Code"
+                },
+                "details": {
+                    "description": "Only set for some errors"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "jwt.JSONWebKey": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "jwt.JSONWebKeySet": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jwt.JSONWebKey"
+                    }
+                }
+            }
+        },
+        "notification.Category": {
+            "type": "string",
+            "enum": [
+                "security",
+                "billing",
+                "product"
+            ],
+            "x-enum-comments": {
+                "CategoryBilling": "Eg. failed payments and license expiry reminders",
+                "CategoryProduct": "Eg. product updates and marketing",
+                "CategorySecurity": "Eg. login alerts. Always sent"
+            },
+            "x-enum-varnames": [
+                "CategorySecurity",
+                "CategoryBilling",
+                "CategoryProduct"
+            ]
+        },
+        "notification.SetPreferenceRequest": {
+            "type": "object",
+            "required": [
+                "category",
+                "enabled"
+            ],
+            "properties": {
+                "category": {
+                    "enum": [
+                        "security",
+                        "billing",
+                        "product"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/notification.Category"
+                        }
+                    ]
+                },
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "oauth.Client": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "public": {
+                    "type": "boolean"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "skip_consent": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "oauth.ConsentDecisionRequest": {
+            "type": "object",
+            "properties": {
+                "approve": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "oauth.ConsentRequestResponse": {
+            "type": "object",
+            "properties": {
+                "client_name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "oauth.DiscoveryDocument": {
+            "type": "object",
+            "properties": {
+                "authorization_endpoint": {
+                    "type": "string"
+                },
+                "claims_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "code_challenge_methods_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "grant_types_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id_token_signing_alg_values_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "introspection_endpoint": {
+                    "type": "string"
+                },
+                "issuer": {
+                    "type": "string"
+                },
+                "jwks_uri": {
+                    "type": "string"
+                },
+                "response_types_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "revocation_endpoint": {
+                    "type": "string"
+                },
+                "scopes_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "subject_types_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "token_endpoint": {
+                    "type": "string"
+                },
+                "token_endpoint_auth_methods_supported": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "userinfo_endpoint": {
+                    "type": "string"
+                }
+            }
+        },
+        "oauth.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "error_description": {
+                    "type": "string"
+                }
+            }
+        },
+        "oauth.IntrospectionResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
                     "type": "boolean"
                 },
+                "client_id": {
+                    "type": "string"
+                },
+                "exp": {
+                    "type": "integer"
+                },
+                "iat": {
+                    "type": "integer"
+                },
+                "iss": {
+                    "type": "string"
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "sub": {
+                    "type": "string"
+                },
+                "token_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "oauth.RedirectResponse": {
+            "type": "object",
+            "properties": {
+                "redirect_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "oauth.RegisterClientRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "redirect_uris"
+            ],
+            "properties": {
                 "name": {
                     "type": "string"
                 },
-                "uuid": {
+                "public": {
+                    "description": "Public clients (SPAs, mobile apps) are not issued a secret",
+                    "type": "boolean"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "skip_consent": {
+                    "description": "Skip consent screen for first-party clients",
+                    "type": "boolean"
+                }
+            }
+        },
+        "oauth.RegisterClientResponse": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string"
+                },
+                "client_secret": {
+                    "description": "Only returned once",
                     "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "public": {
+                    "type": "boolean"
+                },
+                "redirect_uris": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "skip_consent": {
+                    "type": "boolean"
                 }
             }
         },
-        "httpresp.StandardDataResponse": {
+        "oauth.TokenResponse": {
             "type": "object",
             "properties": {
-                "code": {
+                "access_token": {
+                    "type": "string"
+                },
+                "expires_in": {
                     "type": "integer"
                 },
-                "data": {},
+                "id_token": {
+                    "type": "string"
+                },
+                "refresh_token": {
+                    "type": "string"
+                },
+                "scope": {
+                    "type": "string"
+                },
+                "token_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "password.Violation": {
+            "type": "object",
+            "properties": {
                 "message": {
                     "type": "string"
+                },
+                "rule": {
+                    "type": "string"
                 }
             }
         },
-        "httpresp.StandardResponse": {
+        "resperror.Code": {
+            "type": "integer",
+            "enum": [
+                10000,
+                10001,
+                10002,
+                10003,
+                10004,
+                10101,
+                10102,
+                10103,
+                10104,
+                10105,
+                10106,
+                10107,
+                10108,
+                10201,
+                10202,
+                10301,
+                10401,
+                10501,
+                10502,
+                10503,
+                10601,
+                10602,
+                10603,
+                10604,
+                10605,
+                10701,
+                10702,
+                10703,
+                10801,
+                10802,
+                10901,
+                10902,
+                10903,
+                11001,
+                11002,
+                11003,
+                11004,
+                11005,
+                11006,
+                11007,
+                11008,
+                11101,
+                11102
+            ],
+            "x-enum-comments": {
+                "InvalidCredentialsError": "Login failed (does not reveal whether account exists)",
+                "OutboxMessageNotPendingError": "Content of sent, suppressed and dead emails is removed, so they cannot be retried",
+                "TooManyRequests": "Rate limit"
+            },
+            "x-enum-varnames": [
+                "UnknownError",
+                "BadRequest",
+                "TooManyRequests",
+                "Unauthorized",
+                "Forbidden",
+                "UserAlreadyExistsError",
+                "UserNotFound",
+                "UserNotVerifiedError",
+                "UserLicenseExpiredError",
+                "UserEmailNotFound",
+                "UserIncorrectPassword",
+                "InvalidCredentialsError",
+                "PasswordPolicyError",
+                "UserAlreadyVerifiedError",
+                "InvalidTokenError",
+                "FeatureNotAvailableError",
+                "QuotaExceededError",
+                "BillingNotConfiguredError",
+                "InvalidWebhookSignatureError",
+                "InvalidWebhookPayloadError",
+                "TwoFactorAlreadyEnabledError",
+                "TwoFactorNotEnabledError",
+                "TwoFactorEnrollmentNotStartedError",
+                "InvalidTwoFactorCodeError",
+                "TwoFactorNotConfiguredError",
+                "UnknownIdentityProviderError",
+                "IdentityProviderLoginError",
+                "IdentityProviderEmailNotVerifiedError",
+                "OAuthClientNotFoundError",
+                "OAuthConsentRequestNotFoundError",
+                "LoginThrottledError",
+                "AccountLockedError",
+                "InvalidUnlockTokenError",
+                "OutboxMessageNotFoundError",
+                "OutboxMessageAlreadySentError",
+                "MailboxMessageNotFoundError",
+                "EmailTemplateNotFoundError",
+                "EmailSuppressionNotFoundError",
+                "InvalidBounceNotificationError",
+                "BounceWebhookNotConfiguredError",
+                "OutboxMessageNotPendingError",
+                "SecurityNotificationsRequiredError",
+                "InvalidUnsubscribeLinkError"
+            ]
+        },
+        "resperror.RetryDetails": {
             "type": "object",
             "properties": {
-                "code": {
+                "retry_after": {
+                    "description": "No. of seconds after which request can be retried",
                     "type": "integer"
+                }
+            }
+        },
+        "session.MagicLinkRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "bind_browser": {
+                    "description": "Only allow link to be used from the requesting browser",
+                    "type": "boolean"
                 },
-                "message": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "session.OIDCProvidersResponse": {
+            "type": "object",
+            "properties": {
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "session.TwoFactorLoginRequest": {
+            "type": "object",
+            "required": [
+                "challenge_token",
+                "code"
+            ],
+            "properties": {
+                "challenge_token": {
+                    "type": "string"
+                },
+                "code": {
+                    "description": "TOTP code or recovery code",
                     "type": "string"
                 }
             }
@@ -432,14 +2890,73 @@ const docTemplate = `{
         "session.UserLoginResponse": {
             "type": "object",
             "properties": {
+                "challenge_token": {
+                    "description": "Set if 2FA is required",
+                    "type": "string"
+                },
                 "expiry": {
                     "type": "integer"
                 },
+                "two_factor_required": {
+                    "type": "boolean"
+                },
                 "uuid": {
                     "type": "string"
                 }
             }
         },
+        "suppression.Notification": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "permanent": {
+                    "description": "Whether bounce is permanent. Transient bounces do not suppress the address",
+                    "type": "boolean"
+                },
+                "type": {
+                    "description": "bounce or complaint",
+                    "type": "string"
+                }
+            }
+        },
+        "twofactor.BeginEnrollmentResponse": {
+            "type": "object",
+            "properties": {
+                "otpauth_uri": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                }
+            }
+        },
+        "twofactor.CodeRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "twofactor.ConfirmEnrollmentResponse": {
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "user.CreateUserRequest": {
             "type": "object",
             "required": [
@@ -451,6 +2968,10 @@ const docTemplate = `{
                 "email": {
                     "type": "string"
                 },
+                "language": {
+                    "description": "Preferred language of emails (eg. en or de)",
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
@@ -467,6 +2988,41 @@ const docTemplate = `{
                 }
             }
         },
+        "user.EntitlementsResponse": {
+            "type": "object",
+            "properties": {
+                "features": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "boolean"
+                    }
+                },
+                "limits": {
+                    "description": "-1 indicates no limit",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "plan": {
+                    "type": "string"
+                }
+            }
+        },
+        "user.ExportResponse": {
+            "type": "object",
+            "properties": {
+                "entitlements": {
+                    "$ref": "#/definitions/user.EntitlementsResponse"
+                },
+                "usage": {
+                    "$ref": "#/definitions/user.UsageSummaryResponse"
+                },
+                "user": {
+                    "$ref": "#/definitions/handler_user.User"
+                }
+            }
+        },
         "user.ResendVerificationEmailRequest": {
             "type": "object",
             "required": [
@@ -489,6 +3045,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "token": {
+                    "description": "Code from reset email, or token from reset link",
                     "type": "string"
                 }
             }
@@ -509,6 +3066,15 @@ const docTemplate = `{
                 }
             }
         },
+        "user.SetLanguageRequest": {
+            "type": "object",
+            "properties": {
+                "language": {
+                    "description": "Empty for default language",
+                    "type": "string"
+                }
+            }
+        },
         "user.SetNewPWRequest": {
             "type": "object",
             "properties": {
@@ -522,6 +3088,38 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "user.Usage": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "description": "-1 indicates no limit",
+                    "type": "integer"
+                },
+                "metric": {
+                    "type": "string"
+                },
+                "remaining": {
+                    "type": "integer"
+                },
+                "reset_at": {
+                    "type": "integer"
+                },
+                "used": {
+                    "type": "integer"
+                }
+            }
+        },
+        "user.UsageSummaryResponse": {
+            "type": "object",
+            "properties": {
+                "usages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/user.Usage"
+                    }
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,52 @@
+package billing
+
+import (
+	"io"
+
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/billing"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const maxWebhookBodyBytes = 1 << 20
+
+type BillingHandler struct {
+	billingService *billing.BillingService
+	logger         *logrus.Entry
+}
+
+func InitBillingHandler(billingService *billing.BillingService) *BillingHandler {
+	return &BillingHandler{
+		billingService: billingService,
+		logger:         logger.GetLogger().WithField("module", "billing_handler"),
+	}
+}
+
+// @Summary Payment provider webhook
+// @Description Receives subscription events from the configured payment provider and updates user licenses accordingly. Requests must be signed by the provider
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Failure 400 {object} httpresp.StandardResponse "Invalid payload"
+// @Failure 401 {object} httpresp.StandardResponse "Invalid signature"
+// @Failure 503 {object} httpresp.StandardResponse "Billing not configured"
+// @Router /billing/webhook [post]
+func (h *BillingHandler) Webhook(c *gin.Context) {
+	// Raw body is needed for signature verification
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodyBytes))
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		return
+	}
+	err = h.billingService.HandleWebhook(c, payload, c.Request.Header)
+	if err != nil {
+		h.logger.WithField("err", err).Error("Failed to handle webhook")
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendSuccess(c)
+}
@@ -0,0 +1,296 @@
+package email
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/outbox"
+	"github.com/dominiclet/golang-base/service/suppression"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultListLimit = 50
+
+const maxNotificationBodyBytes = 1 << 20
+
+type EmailHandler struct {
+	emailService       *email.EmailService
+	outboxService      *outbox.OutboxService
+	suppressionService *suppression.SuppressionService
+	logger             *logrus.Entry
+}
+
+func InitEmailHandler(emailService *email.EmailService, outboxService *outbox.OutboxService,
+	suppressionService *suppression.SuppressionService) *EmailHandler {
+	return &EmailHandler{
+		emailService:       emailService,
+		outboxService:      outboxService,
+		suppressionService: suppressionService,
+		logger:             logger.GetLogger().WithField("module", "email_handler"),
+	}
+}
+
+// @Summary List queued emails
+// @Description List emails in outbox, most recent first (admin endpoint). Failed emails that were given up on have status dead, and emails to suppressed addresses have status suppressed
+// @Tags email,authRequired
+// @Param status query string false "Filter by status" Enums(pending, sent, dead, suppressed)
+// @Param limit query int false "Max. no. of emails to return (default 50)"
+// @Param offset query int false "No. of emails to skip"
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=[]OutboxMessage}
+// @Failure 403 {object} httpresp.StandardResponse "Not an admin"
+// @Router /admin/email_outbox [get]
+func (h *EmailHandler) ListOutbox(c *gin.Context) {
+	var req ListOutboxRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultListLimit
+	}
+	messages, err := h.outboxService.ListMessages(c, req.Status, req.Limit, req.Offset)
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	resp := make([]OutboxMessage, 0, len(messages))
+	for i := range messages {
+		resp = append(resp, newOutboxMessage(&messages[i]))
+	}
+	httpresp.SendData(c, resp, http.StatusOK)
+}
+
+// @Summary Retry queued email
+// @Description Queue pending email for immediate delivery, resetting its attempts (admin endpoint). Suppressed and dead emails cannot be retried, as their content is removed
+// @Tags email,authRequired
+// @Param id path int true "Email ID"
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=OutboxMessage}
+// @Failure 404 {object} httpresp.StandardResponse "Email not found"
+// @Failure 409 {object} httpresp.StandardResponse "Email already sent, suppressed or dead"
+// @Router /admin/email_outbox/{id}/retry [post]
+func (h *EmailHandler) RetryOutboxMessage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		return
+	}
+	message, err := h.outboxService.RetryMessage(c, uint(id))
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendData(c, newOutboxMessage(message), http.StatusOK)
+}
+
+// @Summary Bounce and complaint notifications
+// @Description Receives bounce and complaint notifications, either as JSON or as delivery status notification (RFC 3464, as complete message/rfc822 or multipart/report body). Permanently bouncing addresses and addresses that complained are added to the suppression list. Requests must carry the configured secret as bearer token
+// @Tags email
+// @Accept json,message/rfc822,multipart/report
+// @Param req body suppression.Notification false "Notification (if JSON)"
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Failure 400 {object} httpresp.StandardResponse "Invalid notification"
+// @Failure 401 {object} httpresp.StandardResponse "Invalid token"
+// @Failure 503 {object} httpresp.StandardResponse "Notifications not configured"
+// @Router /email/notifications [post]
+func (h *EmailHandler) BounceNotification(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxNotificationBodyBytes))
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		return
+	}
+	err = h.suppressionService.HandleNotification(c, payload, c.Request.Header)
+	if err != nil {
+		h.logger.WithField("err", err).Error("Failed to handle bounce notification")
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendSuccess(c)
+}
+
+// @Summary List suppressed addresses
+// @Description List addresses that are not emailed because of bounces or complaints, most recent first (admin endpoint)
+// @Tags email,authRequired
+// @Param email query string false "Only return suppression of this address"
+// @Param limit query int false "Max. no. of addresses to return (default 50)"
+// @Param offset query int false "No. of addresses to skip"
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=[]Suppression}
+// @Failure 403 {object} httpresp.StandardResponse "Not an admin"
+// @Router /admin/email_suppressions [get]
+func (h *EmailHandler) ListSuppressions(c *gin.Context) {
+	var req ListSuppressionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultListLimit
+	}
+	suppressions, err := h.suppressionService.ListSuppressions(c, req.Email, req.Limit, req.Offset)
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	resp := make([]Suppression, 0, len(suppressions))
+	for _, s := range suppressions {
+		resp = append(resp, Suppression{
+			ID:        s.ID,
+			Email:     s.Email,
+			Reason:    s.Reason,
+			Details:   s.Details,
+			CreatedAt: s.CreatedAt,
+			UpdatedAt: s.UpdatedAt,
+		})
+	}
+	httpresp.SendData(c, resp, http.StatusOK)
+}
+
+// @Summary Clear suppressed address
+// @Description Remove address from suppression list, so that it is emailed again (admin endpoint)
+// @Tags email,authRequired
+// @Param id path int true "Suppression ID"
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Failure 404 {object} httpresp.StandardResponse "Suppression not found"
+// @Router /admin/email_suppressions/{id} [delete]
+func (h *EmailHandler) DeleteSuppression(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		return
+	}
+	if err := h.suppressionService.DeleteSuppression(c, uint(id)); err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendSuccess(c)
+}
+
+// @Summary List emails in dev mailbox
+// @Description List emails sent recently, most recent first (dev mode only)
+// @Tags email,dev
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=[]MailboxMessage}
+// @Router /dev/mailbox [get]
+func (h *EmailHandler) ListMailbox(c *gin.Context) {
+	messages := h.mailbox().Messages()
+	resp := make([]MailboxMessage, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		resp = append(resp, MailboxMessage{
+			ID:      messages[i].ID,
+			From:    messages[i].From,
+			To:      messages[i].To,
+			Subject: messages[i].Subject,
+			SentAt:  messages[i].SentAt,
+		})
+	}
+	httpresp.SendData(c, resp, http.StatusOK)
+}
+
+// @Summary View email in dev mailbox
+// @Description Show HTML or text body of email, or the full message in MIME format (dev mode only)
+// @Tags email,dev
+// @Param id path int true "Email ID"
+// @Param format query string false "Part of email to show (default html)" Enums(html, text, raw)
+// @Produce html
+// @Success 200 {string} string "Email body"
+// @Failure 404 {object} httpresp.StandardResponse "Email not in mailbox"
+// @Router /dev/mailbox/{id} [get]
+func (h *EmailHandler) GetMailboxMessage(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		return
+	}
+	message, ok := h.mailbox().Get(id)
+	if !ok {
+		httpresp.SendError(c, resperror.NewError(resperror.MailboxMessageNotFoundError))
+		return
+	}
+	switch c.Query("format") {
+	case "raw":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", message.Raw)
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(message.TextBody))
+	default:
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(message.HTMLBody))
+	}
+}
+
+// @Summary List email templates
+// @Description List names of email templates that can be previewed (dev mode only)
+// @Tags email,dev
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=[]string}
+// @Router /dev/email_preview [get]
+func (h *EmailHandler) ListTemplates(c *gin.Context) {
+	httpresp.SendData(c, h.emailService.TemplateNames(), http.StatusOK)
+}
+
+// @Summary Preview email template
+// @Description Render email template with example data. Templates are reloaded on every request, so that changes to templates can be previewed without restarting (dev mode only)
+// @Tags email,dev
+// @Param template path string true "Template name"
+// @Param locale query string false "Locale of template (eg. de)"
+// @Param format query string false "Body to show (default html)" Enums(html, text)
+// @Produce html
+// @Success 200 {string} string "Rendered email"
+// @Failure 404 {object} httpresp.StandardResponse "Template not found"
+// @Router /dev/email_preview/{template} [get]
+func (h *EmailHandler) PreviewTemplate(c *gin.Context) {
+	message, err := h.emailService.PreviewEmail(c.Param("template"), c.Query("locale"))
+	if err != nil {
+		if errors.Is(err, email.ErrTemplateNotFound) {
+			httpresp.SendError(c, resperror.NewError(resperror.EmailTemplateNotFoundError))
+			return
+		}
+		httpresp.SendErrorWithStatusCode(c, err, http.StatusInternalServerError)
+		return
+	}
+	if c.Query("format") == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte("Subject: "+message.Subject+"\n\n"+message.TextBody))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(message.HTMLBody))
+}
+
+// @Summary Clear dev mailbox
+// @Description Discard all emails in dev mailbox (dev mode only)
+// @Tags email,dev
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Router /dev/mailbox [delete]
+func (h *EmailHandler) ClearMailbox(c *gin.Context) {
+	h.mailbox().Reset()
+	httpresp.SendSuccess(c)
+}
+
+// Dev mailbox routes are only registered in dev mode, where the mailbox is always set
+func (h *EmailHandler) mailbox() *email.CaptureMailer {
+	return h.emailService.Mailbox()
+}
+
+func newOutboxMessage(message *outbox.OutboxMessage) OutboxMessage {
+	return OutboxMessage{
+		ID:            message.ID,
+		Recipient:     message.Recipient,
+		Subject:       message.Subject,
+		Status:        message.Status,
+		Attempts:      message.Attempts,
+		NextAttemptAt: message.NextAttemptAt,
+		ExpiresAt:     message.ExpiresAt,
+		LastError:     message.LastError,
+		SentAt:        message.SentAt,
+		CreatedAt:     message.CreatedAt,
+	}
+}
@@ -0,0 +1,45 @@
+package email
+
+import "time"
+
+type ListOutboxRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending sent dead suppressed"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=200"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+}
+
+type OutboxMessage struct {
+	ID            uint       `json:"id"`
+	Recipient     string     `json:"recipient"`
+	Subject       string     `json:"subject"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type MailboxMessage struct {
+	ID      int       `json:"id"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type ListSuppressionsRequest struct {
+	Email  string `form:"email"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=200"`
+	Offset int    `form:"offset" binding:"omitempty,min=0"`
+}
+
+type Suppression struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	Reason    string    `json:"reason"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package notification
+
+import "github.com/dominiclet/golang-base/service/notification"
+
+type NotificationPreference struct {
+	Category notification.Category `json:"category"`
+	Enabled  bool                  `json:"enabled"`
+}
+
+type SetPreferenceRequest struct {
+	Category notification.Category `json:"category" binding:"required,oneof=security billing product"`
+	Enabled  *bool                 `json:"enabled" binding:"required"`
+}
+
+type UnsubscribeRequest struct {
+	User      string                `form:"user" binding:"required"`
+	Category  notification.Category `form:"category" binding:"required"`
+	Signature string                `form:"sig" binding:"required"`
+}
@@ -0,0 +1,148 @@
+package notification
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/dominiclet/golang-base/init_server/logger"
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/dominiclet/golang-base/lib/i18n"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/notification"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Confirmation page of unsubscribe links. Links only unsubscribe once confirmed, as mail scanners may open them
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}"><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family:sans-serif;">
+<p>{{.Confirm}}</p>
+<form method="post"><button type="submit">{{.Button}}</button></form>
+</body></html>`))
+
+type unsubscribePageData struct {
+	Lang    string
+	Title   string
+	Confirm string
+	Button  string
+}
+
+type NotificationHandler struct {
+	notificationService *notification.NotificationService
+	logger              *logrus.Entry
+}
+
+func InitNotificationHandler(notificationService *notification.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+		logger:              logger.GetLogger().WithField("module", "notification_handler"),
+	}
+}
+
+// @Summary Get notification preferences
+// @Description Get whether the user receives emails of each notification category (protected endpoint)
+// @Tags user,authRequired
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=[]NotificationPreference}
+// @Router /user/me/notifications [get]
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	prefs, err := h.notificationService.GetPreferences(c, user.ID)
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	resp := make([]NotificationPreference, len(notification.Categories))
+	for i, category := range notification.Categories {
+		resp[i] = NotificationPreference{Category: category, Enabled: prefs[category]}
+	}
+	httpresp.SendData(c, resp, http.StatusOK)
+}
+
+// @Summary Set notification preference
+// @Description Enable or disable emails of a notification category (protected endpoint). Security emails cannot be disabled
+// @Tags user,authRequired
+// @Accept json
+// @Param req body SetPreferenceRequest true "Category and whether it is enabled"
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Failure 400 {object} httpresp.StandardResponse "Invalid category, or security emails cannot be disabled"
+// @Router /user/me/notifications [put]
+func (h *NotificationHandler) SetPreference(c *gin.Context) {
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	var req SetPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+	if err := h.notificationService.SetPreference(c, user.ID, req.Category, *req.Enabled); err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendSuccess(c)
+}
+
+// @Summary Unsubscribe page
+// @Description Handles unsubscribe link of notification emails by showing a page to confirm unsubscribing
+// @Tags notification
+// @Param user query string true "User UUID"
+// @Param category query string true "Notification category"
+// @Param sig query string true "Signature of link"
+// @Produce html
+// @Router /notifications/unsubscribe [get]
+func (h *NotificationHandler) UnsubscribePage(c *gin.Context) {
+	lang := httpresp.RequestLanguage(c)
+	var req UnsubscribeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.String(http.StatusBadRequest, i18n.Translate(lang, "unsubscribe.invalid"))
+		return
+	}
+	var page bytes.Buffer
+	err := unsubscribePage.Execute(&page, unsubscribePageData{
+		Lang:    lang.String(),
+		Title:   i18n.Translate(lang, "unsubscribe.title"),
+		Confirm: i18n.Translate(lang, "unsubscribe.confirm", req.Category),
+		Button:  i18n.Translate(lang, "unsubscribe.button"),
+	})
+	if err != nil {
+		h.logger.WithField("err", err).Error("Failed to render unsubscribe page")
+		httpresp.SendError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// @Summary Unsubscribe
+// @Description Disables notification category with the signed link of a notification email. Also used by mail clients for one-click unsubscribe (RFC 8058)
+// @Tags notification
+// @Param user query string true "User UUID"
+// @Param category query string true "Notification category"
+// @Param sig query string true "Signature of link"
+// @Produce plain
+// @Router /notifications/unsubscribe [post]
+func (h *NotificationHandler) Unsubscribe(c *gin.Context) {
+	lang := httpresp.RequestLanguage(c)
+	var req UnsubscribeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.String(http.StatusBadRequest, i18n.Translate(lang, "unsubscribe.invalid"))
+		return
+	}
+	err := h.notificationService.Unsubscribe(c, req.User, req.Category, req.Signature)
+	if err != nil {
+		h.logger.WithField("err", err).Warn("Failed to unsubscribe")
+		c.String(http.StatusBadRequest, i18n.Translate(lang, "unsubscribe.invalid"))
+		return
+	}
+	c.String(http.StatusOK, i18n.Translate(lang, "unsubscribe.success"))
+}
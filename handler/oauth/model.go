@@ -0,0 +1,44 @@
+package oauth
+
+type RegisterClientRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1"`
+	Public       bool     `json:"public"`       // Public clients (SPAs, mobile apps) are not issued a secret
+	SkipConsent  bool     `json:"skip_consent"` // Skip consent screen for first-party clients
+}
+
+type Client struct {
+	ClientID     string   `json:"client_id"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Public       bool     `json:"public"`
+	SkipConsent  bool     `json:"skip_consent"`
+}
+
+type RegisterClientResponse struct {
+	Client
+	ClientSecret string `json:"client_secret,omitempty"` // Only returned once
+}
+
+type ConsentRequestResponse struct {
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+}
+
+type ConsentDecisionRequest struct {
+	Approve bool `json:"approve"`
+}
+
+type RedirectResponse struct {
+	RedirectURL string `json:"redirect_url"`
+}
+
+type ConsentRequiredResponse struct {
+	ConsentRequestID string `json:"consent_request_id"`
+}
+
+// Error response format defined by RFC 6749
+type ErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
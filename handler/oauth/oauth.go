@@ -0,0 +1,397 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dominiclet/golang-base/handler/session"
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/oauth"
+	sessionsvc "github.com/dominiclet/golang-base/service/session"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type OAuthHandler struct {
+	oauthService   *oauth.OAuthService
+	sessionService *sessionsvc.SessionService
+	config         *config.Config
+	envVars        *env.EnvVars
+	logger         *logrus.Entry
+}
+
+func InitOAuthHandler(oauthService *oauth.OAuthService, sessionService *sessionsvc.SessionService,
+	config *config.Config, envVars *env.EnvVars) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService:   oauthService,
+		sessionService: sessionService,
+		config:         config,
+		envVars:        envVars,
+		logger:         logger.GetLogger().WithField("module", "oauth_handler"),
+	}
+}
+
+// @Summary OpenID Connect discovery
+// @Description OpenID provider metadata for apps authenticating against this server
+// @Tags oauth
+// @Produce json
+// @Success 200 {object} oauth.DiscoveryDocument
+// @Router /oauth/.well-known/openid-configuration [get]
+func (h *OAuthHandler) Discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, h.oauthService.GetDiscoveryDocument())
+}
+
+// @Summary JSON web key set
+// @Description Public keys used to verify ID tokens
+// @Tags oauth
+// @Produce json
+// @Success 200 {object} jwt.JSONWebKeySet
+// @Router /oauth/jwks [get]
+func (h *OAuthHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.oauthService.GetJWKS())
+}
+
+// @Summary Authorization endpoint
+// @Description Starts authorization code flow (PKCE required). Users without a session are redirected to the login page, and users who have not granted consent to the consent page
+// @Tags oauth
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param scope query string false "Space-delimited scopes"
+// @Param state query string false "State"
+// @Param nonce query string false "Nonce"
+// @Param code_challenge query string true "PKCE code challenge"
+// @Param code_challenge_method query string true "Must be S256"
+// @Success 302
+// @Failure 400 {object} ErrorResponse "Invalid client or redirect URI"
+// @Router /oauth/authorize [get]
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	req := oauth.AuthorizeRequest{
+		ResponseType:        c.Query("response_type"),
+		ClientID:            c.Query("client_id"),
+		RedirectURI:         c.Query("redirect_uri"),
+		Scope:               c.Query("scope"),
+		State:               c.Query("state"),
+		Nonce:               c.Query("nonce"),
+		CodeChallenge:       c.Query("code_challenge"),
+		CodeChallengeMethod: c.Query("code_challenge_method"),
+	}
+
+	// Interactive login reuses the session cookie of this server
+	userSession, err := h.getSession(c)
+	if err != nil {
+		if h.config.OAuth.LoginURL == "" {
+			httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+			return
+		}
+		returnTo := h.envVars.GetHttpProtocol() + "://" + h.config.Domain + c.Request.URL.RequestURI()
+		c.Redirect(http.StatusFound, withQuery(h.config.OAuth.LoginURL, "return_to", returnTo))
+		return
+	}
+
+	result, err := h.oauthService.Authorize(c, &userSession.User, userSession.CreatedAt, req)
+	if err != nil {
+		h.sendOAuthError(c, err)
+		return
+	}
+	if result.ConsentRequestID != "" {
+		if h.config.OAuth.ConsentURL == "" {
+			httpresp.SendData(c, ConsentRequiredResponse{ConsentRequestID: result.ConsentRequestID}, http.StatusOK)
+			return
+		}
+		c.Redirect(http.StatusFound, withQuery(h.config.OAuth.ConsentURL, "request_id", result.ConsentRequestID))
+		return
+	}
+	c.Redirect(http.StatusFound, result.RedirectURL)
+}
+
+// @Summary Get consent request
+// @Description Get client and scopes of pending consent request (protected endpoint)
+// @Tags oauth,authRequired
+// @Param requestId path string true "Consent request ID"
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=ConsentRequestResponse}
+// @Failure 404 {object} httpresp.StandardResponse "Consent request not found"
+// @Router /oauth/consent/{requestId} [get]
+func (h *OAuthHandler) GetConsentRequest(c *gin.Context) {
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	consentReq, client, err := h.oauthService.GetConsentRequest(c, user.ID, c.Param("requestId"))
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendData(c, ConsentRequestResponse{
+		ClientName: client.Name,
+		Scopes:     consentReq.Scopes,
+	}, http.StatusOK)
+}
+
+// @Summary Decide consent request
+// @Description Grant or deny consent. Returns the client URL to redirect the user back to (protected endpoint)
+// @Tags oauth,authRequired
+// @Param requestId path string true "Consent request ID"
+// @Param req body ConsentDecisionRequest true "Whether consent is granted"
+// @Accept json
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=RedirectResponse}
+// @Failure 404 {object} httpresp.StandardResponse "Consent request not found"
+// @Router /oauth/consent/{requestId} [post]
+func (h *OAuthHandler) DecideConsent(c *gin.Context) {
+	var req ConsentDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	redirectURL, err := h.oauthService.DecideConsent(c, user.ID, c.Param("requestId"), req.Approve)
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendData(c, RedirectResponse{RedirectURL: redirectURL}, http.StatusOK)
+}
+
+// @Summary Token endpoint
+// @Description Exchange authorization code or refresh token for tokens
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param grant_type formData string true "authorization_code or refresh_token"
+// @Param code formData string false "Authorization code"
+// @Param redirect_uri formData string false "Redirect URI used in authorization request"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "Refresh token"
+// @Param scope formData string false "Narrowed scope (refresh only)"
+// @Produce json
+// @Success 200 {object} oauth.TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse "Client authentication failed"
+// @Router /oauth/token [post]
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		h.sendOAuthError(c, err)
+		return
+	}
+	resp, err := h.oauthService.Exchange(c, client, oauth.TokenRequest{
+		GrantType:    c.PostForm("grant_type"),
+		Code:         c.PostForm("code"),
+		RedirectURI:  c.PostForm("redirect_uri"),
+		CodeVerifier: c.PostForm("code_verifier"),
+		RefreshToken: c.PostForm("refresh_token"),
+		Scope:        c.PostForm("scope"),
+	})
+	if err != nil {
+		h.sendOAuthError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Token revocation
+// @Description Revoke access or refresh token (RFC 7009)
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "Token to revoke"
+// @Success 200
+// @Failure 401 {object} ErrorResponse "Client authentication failed"
+// @Router /oauth/revoke [post]
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	client, err := h.authenticateClient(c)
+	if err != nil {
+		h.sendOAuthError(c, err)
+		return
+	}
+	if err := h.oauthService.Revoke(c, client, c.PostForm("token")); err != nil {
+		h.sendOAuthError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// @Summary Token introspection
+// @Description Get state of token (RFC 7662)
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "Token to introspect"
+// @Produce json
+// @Success 200 {object} oauth.IntrospectionResponse
+// @Failure 401 {object} ErrorResponse "Client authentication failed"
+// @Router /oauth/introspect [post]
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	if _, err := h.authenticateClient(c); err != nil {
+		h.sendOAuthError(c, err)
+		return
+	}
+	resp, err := h.oauthService.Introspect(c, c.PostForm("token"))
+	if err != nil {
+		h.sendOAuthError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Userinfo
+// @Description Get claims about the user the bearer access token was issued for
+// @Tags oauth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} ErrorResponse "Invalid token"
+// @Router /oauth/userinfo [get]
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	authHeader := c.GetHeader("Authorization")
+	accessToken, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok || accessToken == "" {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: oauth.ErrInvalidToken})
+		return
+	}
+	claims, err := h.oauthService.GetUserInfo(c, accessToken)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		h.sendOAuthError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, claims)
+}
+
+// @Summary Register OAuth client
+// @Description Register an app that authenticates users against this server. Client secret is only returned once (admin endpoint)
+// @Tags oauth,authRequired
+// @Param req body RegisterClientRequest true "Client details"
+// @Accept json
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=RegisterClientResponse}
+// @Failure 403 {object} httpresp.StandardResponse "Not an admin"
+// @Router /oauth/clients [post]
+func (h *OAuthHandler) RegisterClient(c *gin.Context) {
+	var req RegisterClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+	client, secret, err := h.oauthService.RegisterClient(c, req.Name, req.RedirectURIs, req.Public, req.SkipConsent)
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendData(c, RegisterClientResponse{
+		Client:       newClient(client),
+		ClientSecret: secret,
+	}, http.StatusOK)
+}
+
+// @Summary List OAuth clients
+// @Description List registered OAuth clients (admin endpoint)
+// @Tags oauth,authRequired
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=[]Client}
+// @Failure 403 {object} httpresp.StandardResponse "Not an admin"
+// @Router /oauth/clients [get]
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	clients, err := h.oauthService.ListClients(c)
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	resp := make([]Client, 0, len(clients))
+	for i := range clients {
+		resp = append(resp, newClient(&clients[i]))
+	}
+	httpresp.SendData(c, resp, http.StatusOK)
+}
+
+// @Summary Delete OAuth client
+// @Description Delete OAuth client and revoke all its tokens (admin endpoint)
+// @Tags oauth,authRequired
+// @Param clientId path string true "Client ID"
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Failure 404 {object} httpresp.StandardResponse "Client not found"
+// @Router /oauth/clients/{clientId} [delete]
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	if err := h.oauthService.DeleteClient(c, c.Param("clientId")); err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendSuccess(c)
+}
+
+// Authenticate client with HTTP basic auth or client credentials in request body
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*oauth.OAuthClient, error) {
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if ok {
+		// Credentials are form-encoded before basic auth encoding (RFC 6749 section 2.3.1)
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return nil, &oauth.Error{Code: oauth.ErrInvalidClient, StatusCode: http.StatusUnauthorized}
+		}
+		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+			return nil, &oauth.Error{Code: oauth.ErrInvalidClient, StatusCode: http.StatusUnauthorized}
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+	return h.oauthService.AuthenticateClient(c, clientID, clientSecret)
+}
+
+func (h *OAuthHandler) getSession(c *gin.Context) (*sessionsvc.Session, error) {
+	token, err := c.Cookie(session.CookieKey)
+	if err != nil {
+		return nil, err
+	}
+	return h.sessionService.GetSession(token)
+}
+
+// Send error in the format defined by RFC 6749
+func (h *OAuthHandler) sendOAuthError(c *gin.Context, err error) {
+	var oauthErr *oauth.Error
+	if !errors.As(err, &oauthErr) {
+		h.logger.WithField("err", err).Error("Unexpected error in OAuth endpoint")
+		oauthErr = &oauth.Error{Code: oauth.ErrServerError, StatusCode: http.StatusInternalServerError}
+	}
+	if oauthErr.Code == oauth.ErrInvalidClient && c.GetHeader("Authorization") != "" {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(oauthErr.StatusCode, ErrorResponse{
+		Error:            oauthErr.Code,
+		ErrorDescription: oauthErr.Description,
+	})
+}
+
+func newClient(client *oauth.OAuthClient) Client {
+	return Client{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Public:       client.Public,
+		SkipConsent:  client.SkipConsent,
+	}
+}
+
+func withQuery(rawURL string, key string, value string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	query.Set(key, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
@@ -1,16 +1,36 @@
 package session
 
 const (
-	daySeconds = 60 * 60 * 24
-	CookieKey  = "session"
+	daySeconds           = 60 * 60 * 24
+	CookieKey            = "session"
+	magicLinkBindingKey  = "magic_link_binding"
+	magicLinkBindingSecs = 60 * 15
+	oidcStateKey         = "oidc_state"
+	oidcStateSecs        = 60 * 10
 )
 
 type UserLoginRequest struct {
-	Email    string `json:"email" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 
 type UserLoginResponse struct {
-	Uuid   string `json:"uuid"`
-	Expiry int64  `json:"expiry"`
+	Uuid              string `json:"uuid,omitempty"`
+	Expiry            int64  `json:"expiry,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token,omitempty"` // Set if 2FA is required
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type MagicLinkRequest struct {
+	Email       string `json:"email" binding:"required,email"`
+	BindBrowser bool   `json:"bind_browser"` // Only allow link to be used from the requesting browser
+}
+
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers"`
 }
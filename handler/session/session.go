@@ -8,55 +8,244 @@ import (
 	"github.com/dominiclet/golang-base/init_server/logger"
 	"github.com/dominiclet/golang-base/lib/httpresp"
 	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/identity"
 	"github.com/dominiclet/golang-base/service/session"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 type SessionHandler struct {
-	sessionService *session.SessionService
-	config         *config.Config
-	logger         *logrus.Entry
-	envVars        *env.EnvVars
+	sessionService  *session.SessionService
+	identityService *identity.IdentityService
+	config          *config.Config
+	logger          *logrus.Entry
+	envVars         *env.EnvVars
 }
 
-func InitSessionHandler(sessionService *session.SessionService, config *config.Config, envVars *env.EnvVars) *SessionHandler {
+func InitSessionHandler(sessionService *session.SessionService, identityService *identity.IdentityService,
+	config *config.Config, envVars *env.EnvVars) *SessionHandler {
 	return &SessionHandler{
-		sessionService: sessionService,
-		config:         config,
-		logger:         logger.GetLogger().WithField("module", "session_handler"),
-		envVars:        envVars,
+		sessionService:  sessionService,
+		identityService: identityService,
+		config:          config,
+		logger:          logger.GetLogger().WithField("module", "session_handler"),
+		envVars:         envVars,
 	}
 }
 
 // @Summary User login
-// @Description Create login session for user
+// @Description Create login session for user. If user has 2FA enabled, a challenge token is returned instead (see /session/login/2fa)
 // @Tags session
 // @Accept json
 // @Param req body UserLoginRequest true "Email and password for authentication"
 // @Produce json
 // @Failure 403 {object} httpresp.StandardResponse "User is not verified"
-// @Failure 401 {object} httpresp.StandardResponse "Authentication failed"
+// @Failure 401 {object} httpresp.StandardResponse "Invalid email or password"
+// @Failure 429 {object} httpresp.StandardResponse{details=resperror.RetryDetails} "Too many failed login attempts (see Retry-After header)"
 // @Success 200 {object} httpresp.StandardDataResponse{data=UserLoginResponse}
 // @Router /session/login [post]
 func (s *SessionHandler) UserLogin(c *gin.Context) {
 	var req UserLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		httpresp.SendBindError(c, err)
 		return
 	}
 
-	user, token, expiry, err := s.sessionService.CreateUserSession(c, req.Email, req.Password)
+	result, err := s.sessionService.CreateUserSession(c, req.Email, req.Password, c.ClientIP())
 	if err != nil {
 		s.logger.WithField("err", err).Error("Error occurred while creating user session")
 		httpresp.SendErrorWithFallback(c, err, resperror.NewError(resperror.Unauthorized))
 		return
 	}
 
-	var secureCookie bool
-	if !s.envVars.IsDev() {
-		secureCookie = true
+	s.sendLoginResult(c, result)
+}
+
+// @Summary Two-factor login
+// @Description Exchange challenge token from login and TOTP code (or recovery code) for login session
+// @Tags session
+// @Accept json
+// @Param req body TwoFactorLoginRequest true "Challenge token and code"
+// @Produce json
+// @Failure 401 {object} httpresp.StandardResponse "Invalid challenge token or code"
+// @Failure 429 {object} httpresp.StandardResponse{details=resperror.RetryDetails} "Too many failed login attempts (see Retry-After header)"
+// @Failure 503 {object} httpresp.StandardResponse "2FA not configured"
+// @Success 200 {object} httpresp.StandardDataResponse{data=UserLoginResponse}
+// @Router /session/login/2fa [post]
+func (s *SessionHandler) TwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+
+	result, err := s.sessionService.CompleteTwoFactorLogin(c, req.ChallengeToken, req.Code, c.ClientIP())
+	if err != nil {
+		s.logger.WithField("err", err).Error("Error occurred while completing 2FA login")
+		httpresp.SendErrorWithFallback(c, err, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+
+	s.sendLoginResult(c, result)
+}
+
+// @Summary Unlock account
+// @Description Lifts lockout of account with the single-use link sent by email when the account was locked
+// @Tags session
+// @Param token query string true "Unlock token"
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Failure 400 {object} httpresp.StandardResponse "Invalid or expired unlock link"
+// @Router /session/unlock [get]
+func (s *SessionHandler) UnlockAccount(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		return
+	}
+
+	if err := s.sessionService.UnlockAccount(c, token, c.ClientIP()); err != nil {
+		s.logger.WithField("err", err).Error("Failed to unlock account")
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendSuccess(c)
+}
+
+// @Summary Request magic link
+// @Description Sends a single-use login link to the email if an account with the email exists
+// @Tags session
+// @Accept json
+// @Param req body MagicLinkRequest true "Email to send link to"
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Router /session/magic_link [post]
+func (s *SessionHandler) RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+
+	binding, err := s.sessionService.RequestMagicLink(c, req.Email, req.BindBrowser)
+	if err != nil {
+		s.logger.WithField("err", err).Error("Failed to send magic link")
+	}
+	if binding != "" {
+		c.SetCookie(magicLinkBindingKey, binding, magicLinkBindingSecs, "/api/session/magic_link",
+			s.config.Domain, s.secureCookie(), true)
+	}
+
+	// Handler always returns success to mask whether account exists
+	httpresp.SendSuccess(c)
+}
+
+// @Summary Consume magic link
+// @Description Exchanges magic link token for login session. If user has 2FA enabled, a challenge token is returned instead
+// @Tags session
+// @Param token query string true "Magic link token"
+// @Produce json
+// @Failure 401 {object} httpresp.StandardResponse "Invalid token"
+// @Success 200 {object} httpresp.StandardDataResponse{data=UserLoginResponse}
+// @Router /session/magic_link/consume [get]
+func (s *SessionHandler) ConsumeMagicLink(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		return
+	}
+	// Binding cookie is only present if link was requested with bind_browser
+	binding, _ := c.Cookie(magicLinkBindingKey)
+
+	result, err := s.sessionService.ConsumeMagicLink(c, token, binding)
+	if err != nil {
+		s.logger.WithField("err", err).Error("Failed to consume magic link")
+		httpresp.SendErrorWithFallback(c, err, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	c.SetCookie(magicLinkBindingKey, "", -1, "/api/session/magic_link", s.config.Domain, s.secureCookie(), true)
+
+	s.sendLoginResult(c, result)
+}
+
+// @Summary List external identity providers
+// @Description Get names of external identity providers that users can log in with
+// @Tags session
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=OIDCProvidersResponse}
+// @Router /session/oidc/providers [get]
+func (s *SessionHandler) GetOIDCProviders(c *gin.Context) {
+	httpresp.SendData(c, OIDCProvidersResponse{
+		Providers: s.identityService.GetProviderNames(),
+	}, http.StatusOK)
+}
+
+// @Summary Log in with external identity provider
+// @Description Redirects to the login page of the external identity provider
+// @Tags session
+// @Param provider path string true "Provider name"
+// @Failure 404 {object} httpresp.StandardResponse "Unknown provider"
+// @Success 302
+// @Router /session/oidc/{provider}/login [get]
+func (s *SessionHandler) OIDCLogin(c *gin.Context) {
+	providerName := c.Param("provider")
+	authURL, state, err := s.identityService.StartLogin(c, providerName)
+	if err != nil {
+		s.logger.WithField("err", err).Error("Failed to start OIDC login")
+		httpresp.SendError(c, err)
+		return
 	}
-	c.SetCookie(CookieKey, token, daySeconds*session.DefaultSessionDurationDay, "/", s.config.Domain, secureCookie, true)
-	httpresp.SendData(c, UserLoginResponse{Uuid: user.Uuid, Expiry: expiry}, http.StatusOK)
+	c.SetCookie(oidcStateKey, state, oidcStateSecs, "/api/session/oidc", s.config.Domain, s.secureCookie(), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary External identity provider callback
+// @Description Completes login after the external identity provider redirects back. If user has 2FA enabled, a challenge token is returned instead of a session
+// @Tags session
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Produce json
+// @Failure 401 {object} httpresp.StandardResponse "Login failed"
+// @Failure 403 {object} httpresp.StandardResponse "Email not verified by provider"
+// @Success 200 {object} httpresp.StandardDataResponse{data=UserLoginResponse}
+// @Router /session/oidc/{provider}/callback [get]
+func (s *SessionHandler) OIDCCallback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		s.logger.WithField("error", errCode).Error("Identity provider returned error")
+		httpresp.SendError(c, resperror.NewError(resperror.IdentityProviderLoginError))
+		return
+	}
+	browserState, _ := c.Cookie(oidcStateKey)
+	c.SetCookie(oidcStateKey, "", -1, "/api/session/oidc", s.config.Domain, s.secureCookie(), true)
+
+	result, err := s.identityService.CompleteLogin(c, c.Param("provider"), c.Query("state"), browserState, c.Query("code"))
+	if err != nil {
+		s.logger.WithField("err", err).Error("Failed to complete OIDC login")
+		httpresp.SendErrorWithFallback(c, err, resperror.NewError(resperror.IdentityProviderLoginError))
+		return
+	}
+	s.sendLoginResult(c, result)
+}
+
+// Set session cookie and send login response, or send 2FA challenge if 2FA is required
+func (s *SessionHandler) sendLoginResult(c *gin.Context, result *session.LoginResult) {
+	if result.TwoFactorRequired {
+		httpresp.SendData(c, UserLoginResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    result.ChallengeToken,
+		}, http.StatusOK)
+		return
+	}
+
+	s.setSessionCookie(c, result.Token)
+	httpresp.SendData(c, UserLoginResponse{Uuid: result.User.Uuid, Expiry: result.Expiry}, http.StatusOK)
+}
+
+func (s *SessionHandler) secureCookie() bool {
+	return !s.envVars.IsDev()
+}
+
+func (s *SessionHandler) setSessionCookie(c *gin.Context, token string) {
+	c.SetCookie(CookieKey, token, daySeconds*session.DefaultSessionDurationDay, "/", s.config.Domain, s.secureCookie(), true)
 }
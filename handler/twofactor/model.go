@@ -0,0 +1,14 @@
+package twofactor
+
+type BeginEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type CodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type ConfirmEnrollmentResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
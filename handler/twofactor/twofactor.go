@@ -0,0 +1,118 @@
+package twofactor
+
+import (
+	"net/http"
+
+	"github.com/dominiclet/golang-base/init_server/logger"
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/session"
+	"github.com/dominiclet/golang-base/service/twofactor"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *twofactor.TwoFactorService
+	sessionService   *session.SessionService
+	logger           *logrus.Entry
+}
+
+func InitTwoFactorHandler(twoFactorService *twofactor.TwoFactorService, sessionService *session.SessionService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		sessionService:   sessionService,
+		logger:           logger.GetLogger().WithField("module", "two_factor_handler"),
+	}
+}
+
+// @Summary Begin 2FA enrollment
+// @Description Generate TOTP secret for logged in user. 2FA is enabled only after enrollment is confirmed (protected endpoint)
+// @Tags user,authRequired
+// @Produce json
+// @Failure 409 {object} httpresp.StandardResponse "2FA already enabled"
+// @Failure 503 {object} httpresp.StandardResponse "2FA not configured"
+// @Success 200 {object} httpresp.StandardDataResponse{data=BeginEnrollmentResponse}
+// @Router /user/me/2fa/enroll [post]
+func (h *TwoFactorHandler) BeginEnrollment(c *gin.Context) {
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	secret, uri, err := h.twoFactorService.BeginEnrollment(c, user.ID)
+	if err != nil {
+		h.logger.WithField("err", err).Error("Failed to begin 2FA enrollment")
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendData(c, BeginEnrollmentResponse{
+		Secret:     secret,
+		OtpauthURI: uri,
+	}, http.StatusOK)
+}
+
+// @Summary Confirm 2FA enrollment
+// @Description Enable 2FA by verifying the first TOTP code. Returns recovery codes which are only shown once (protected endpoint)
+// @Tags user,authRequired
+// @Accept json
+// @Param req body CodeRequest true "TOTP code"
+// @Produce json
+// @Failure 401 {object} httpresp.StandardResponse "Invalid code"
+// @Failure 503 {object} httpresp.StandardResponse "2FA not configured"
+// @Success 200 {object} httpresp.StandardDataResponse{data=ConfirmEnrollmentResponse}
+// @Router /user/me/2fa/confirm [post]
+func (h *TwoFactorHandler) ConfirmEnrollment(c *gin.Context) {
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	recoveryCodes, err := h.twoFactorService.ConfirmEnrollment(c, user.ID, req.Code)
+	if err != nil {
+		h.logger.WithField("err", err).Error("Failed to confirm 2FA enrollment")
+		httpresp.SendError(c, err)
+		return
+	}
+	h.sessionService.InvalidateUserSessionCache(c, user.ID)
+	httpresp.SendData(c, ConfirmEnrollmentResponse{
+		RecoveryCodes: recoveryCodes,
+	}, http.StatusOK)
+}
+
+// @Summary Disable 2FA
+// @Description Disable 2FA after verifying a TOTP or recovery code (protected endpoint)
+// @Tags user,authRequired
+// @Accept json
+// @Param req body CodeRequest true "TOTP or recovery code"
+// @Produce json
+// @Failure 401 {object} httpresp.StandardResponse "Invalid code"
+// @Failure 503 {object} httpresp.StandardResponse "2FA not configured"
+// @Success 200 {object} httpresp.StandardResponse
+// @Router /user/me/2fa/disable [post]
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	err = h.twoFactorService.Disable(c, user.ID, req.Code)
+	if err != nil {
+		h.logger.WithField("err", err).Error("Failed to disable 2FA")
+		httpresp.SendError(c, err)
+		return
+	}
+	h.sessionService.InvalidateUserSessionCache(c, user.ID)
+	httpresp.SendSuccess(c)
+}
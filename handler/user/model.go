@@ -1,11 +1,16 @@
 package user
 
-import "github.com/dominiclet/golang-base/service/user"
+import (
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/metering"
+	"github.com/dominiclet/golang-base/service/user"
+)
 
 type CreateUserRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	Language string `json:"language" binding:"omitempty,bcp47_language_tag"` // Preferred language of emails (eg. en or de)
 }
 
 type CreateUserResponse struct {
@@ -18,7 +23,7 @@ type ResetPasswordRequest struct {
 
 type ResetPWAuthCodeExchangeRequest struct {
 	Email string `json:"email"`
-	Token string `json:"token"`
+	Token string `json:"token"` // Code from reset email, or token from reset link
 }
 
 type ResetPWAuthCodeExchangeResponse struct {
@@ -31,6 +36,10 @@ type SetNewPWRequest struct {
 	NewPassword string `json:"new_password"`
 }
 
+type SetLanguageRequest struct {
+	Language string `json:"language" binding:"omitempty,bcp47_language_tag"` // Empty for default language
+}
+
 type ResendVerificationEmailRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
@@ -42,6 +51,8 @@ type User struct {
 	Email       string `json:"email"`
 	AccountType int    `json:"account_type"`
 	IsVerified  bool   `json:"is_verified"`
+	TwoFactor   bool   `json:"two_factor_enabled"`
+	Language    string `json:"language,omitempty"`
 }
 
 func NewUserFromSvcUser(svcUser *user.User) User {
@@ -51,5 +62,63 @@ func NewUserFromSvcUser(svcUser *user.User) User {
 		Email:       svcUser.Email,
 		AccountType: int(svcUser.AccountType),
 		IsVerified:  svcUser.IsVerified,
+		TwoFactor:   svcUser.TOTPEnabled,
+		Language:    svcUser.Language,
+	}
+}
+
+type EntitlementsResponse struct {
+	Plan     string           `json:"plan"`
+	Features map[string]bool  `json:"features"`
+	Limits   map[string]int64 `json:"limits"` // -1 indicates no limit
+}
+
+func NewEntitlementsResponse(entitlements entitlement.Entitlements) EntitlementsResponse {
+	resp := EntitlementsResponse{
+		Plan:     entitlements.Plan.String(),
+		Features: make(map[string]bool),
+		Limits:   make(map[string]int64),
+	}
+	for feature, enabled := range entitlements.Features {
+		resp.Features[string(feature)] = enabled
+	}
+	for limit, value := range entitlements.Limits {
+		resp.Limits[string(limit)] = value
+	}
+	return resp
+}
+
+type Usage struct {
+	Metric    string `json:"metric"`
+	Used      int64  `json:"used"`
+	Limit     int64  `json:"limit"` // -1 indicates no limit
+	Remaining int64  `json:"remaining"`
+	ResetAt   int64  `json:"reset_at"`
+}
+
+// Data of the account, as downloaded by the user
+type ExportResponse struct {
+	User         User                 `json:"user"`
+	Entitlements EntitlementsResponse `json:"entitlements"`
+	Usage        UsageSummaryResponse `json:"usage"`
+}
+
+type UsageSummaryResponse struct {
+	Usages []Usage `json:"usages"`
+}
+
+func NewUsageSummaryResponse(usages []metering.Usage) UsageSummaryResponse {
+	resp := UsageSummaryResponse{
+		Usages: make([]Usage, 0, len(usages)),
+	}
+	for _, usage := range usages {
+		resp.Usages = append(resp.Usages, Usage{
+			Metric:    string(usage.Metric),
+			Used:      usage.Used,
+			Limit:     usage.Limit,
+			Remaining: usage.Remaining(),
+			ResetAt:   usage.ResetAt.Unix(),
+		})
 	}
+	return resp
 }
@@ -5,20 +5,33 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/dominiclet/golang-base/init_server/logger"
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
 	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/dominiclet/golang-base/lib/i18n"
 	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/metering"
 	"github.com/dominiclet/golang-base/service/user"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	userService *user.UserService
+	userService        *user.UserService
+	entitlementService *entitlement.EntitlementService
+	meteringService    *metering.MeteringService
+	logger             *logrus.Entry
 }
 
-func InitUserHandler(userService *user.UserService) *UserHandler {
+func InitUserHandler(userService *user.UserService, entitlementService *entitlement.EntitlementService,
+	meteringService *metering.MeteringService) *UserHandler {
 	return &UserHandler{
 		userService,
+		entitlementService,
+		meteringService,
+		logger.GetLogger().WithField("module", "user_handler"),
 	}
 }
 
@@ -28,6 +41,7 @@ func InitUserHandler(userService *user.UserService) *UserHandler {
 // @Accept json
 // @Param req body CreateUserRequest true "Create user data"
 // @Produce json
+// @Failure 400 {object} httpresp.StandardResponse{details=[]password.Violation} "Password does not meet requirements"
 // @Failure 409 {object} httpresp.StandardResponse "User with same email already exists"
 // @Success 200 {object} httpresp.StandardDataResponse{data=CreateUserResponse}
 // @Router /user [post]
@@ -35,12 +49,12 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	var userReq CreateUserRequest
 
 	if err := c.ShouldBindJSON(&userReq); err != nil {
-		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		httpresp.SendBindError(c, err)
 		return
 	}
 
 	newUser, err := h.userService.CreateUser(c, userReq.Name,
-		userReq.Email, userReq.Password)
+		userReq.Email, userReq.Password, userReq.Language)
 	if err != nil {
 		httpresp.SendError(c, err)
 		return
@@ -80,6 +94,98 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	httpresp.SendData(c, respUser, http.StatusOK)
 }
 
+// @Summary Get entitlements of current user
+// @Description Get features and limits included in the plan of the logged in user (protected endpoint)
+// @Tags user,authRequired
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=EntitlementsResponse}
+// @Failure 401 {object} httpresp.StandardResponse "Unauthorized"
+// @Router /user/me/entitlements [get]
+func (h *UserHandler) GetEntitlements(c *gin.Context) {
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	entitlements := h.entitlementService.GetEntitlements(&user)
+	httpresp.SendData(c, NewEntitlementsResponse(entitlements), http.StatusOK)
+}
+
+// @Summary Get usage of current user
+// @Description Get usage of metered resources and the limits of the logged in user for the current period (protected endpoint)
+// @Tags user,authRequired
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=UsageSummaryResponse}
+// @Failure 401 {object} httpresp.StandardResponse "Unauthorized"
+// @Router /user/me/usage [get]
+func (h *UserHandler) GetUsage(c *gin.Context) {
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	usages, err := h.meteringService.GetUsageSummary(c, &user)
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendData(c, NewUsageSummaryResponse(usages), http.StatusOK)
+}
+
+// @Summary Export data of current user
+// @Description Export account data of the logged in user (protected endpoint). Requires the export feature, and counts towards the export quota of the plan
+// @Tags user,authRequired
+// @Produce json
+// @Success 200 {object} httpresp.StandardDataResponse{data=ExportResponse}
+// @Failure 401 {object} httpresp.StandardResponse "Unauthorized"
+// @Failure 403 {object} httpresp.StandardResponse "Plan does not include exports"
+// @Failure 429 {object} httpresp.StandardResponse "Export quota exceeded (see Retry-After header)"
+// @Router /user/me/export [get]
+func (h *UserHandler) ExportData(c *gin.Context) {
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	usages, err := h.meteringService.GetUsageSummary(c, &user)
+	if err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendData(c, ExportResponse{
+		User:         NewUserFromSvcUser(&user),
+		Entitlements: NewEntitlementsResponse(h.entitlementService.GetEntitlements(&user)),
+		Usage:        NewUsageSummaryResponse(usages),
+	}, http.StatusOK)
+}
+
+// @Summary Set language of current user
+// @Description Set preferred language of emails sent to the logged in user (protected endpoint)
+// @Tags user,authRequired
+// @Accept json
+// @Param req body SetLanguageRequest true "BCP 47 language tag (eg. en or de)"
+// @Produce json
+// @Success 200 {object} httpresp.StandardResponse
+// @Failure 400 {object} httpresp.StandardResponse "Invalid language tag"
+// @Router /user/me/language [put]
+func (h *UserHandler) SetLanguage(c *gin.Context) {
+	user, err := ctxwrapper.GetUser(c)
+	if err != nil {
+		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		return
+	}
+	var req SetLanguageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httpresp.SendBindError(c, err)
+		return
+	}
+	if err := h.userService.SetLanguage(c, &user, req.Language); err != nil {
+		httpresp.SendError(c, err)
+		return
+	}
+	httpresp.SendSuccess(c)
+}
+
 // @Summary Verify email
 // @Description Handles verification link for email
 // @Tags user
@@ -90,17 +196,23 @@ func (h *UserHandler) VerifyEmail(c *gin.Context) {
 	userUUID := c.Param("userUuid")
 	verificationToken := c.Param("token")
 
+	lang := httpresp.RequestLanguage(c)
 	decodedUUID, err := url.QueryUnescape(userUUID)
 	if err != nil {
-		c.String(http.StatusBadRequest, "Verification failed")
+		c.String(http.StatusBadRequest, i18n.Translate(lang, "verify_email.failed"))
 		return
 	}
+	// Link is opened from the email, so the language of the user is known even though they are not logged in
+	if u, err := h.userService.GetUserByUuid(c, decodedUUID); err == nil {
+		lang = httpresp.NegotiateLanguage(c, u.Language)
+	}
 	err = h.userService.VerifyEmail(c, decodedUUID, verificationToken)
 	if err != nil {
-		c.String(http.StatusForbidden, "Verification failed")
+		h.logger.WithField("err", err).Info("Email verification failed")
+		c.String(http.StatusForbidden, i18n.Translate(lang, "verify_email.failed"))
 		return
 	}
-	c.String(http.StatusOK, "Verification successful. Please head back to the portal to login.")
+	c.String(http.StatusOK, i18n.Translate(lang, "verify_email.success"))
 }
 
 // @Summary Resned verification email
@@ -116,22 +228,11 @@ func (h *UserHandler) VerifyEmail(c *gin.Context) {
 func (h *UserHandler) ResendVerificationEmail(c *gin.Context) {
 	var req ResendVerificationEmailRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		httpresp.SendBindError(c, err)
 		return
 	}
 	err := h.userService.ResendVerificationEmail(c, req.Email, req.Password)
 	if err != nil {
-		if expectedErr, ok := err.(user.SendVerificationEmailError); ok {
-			switch expectedErr.ErrType() {
-			case user.ResendDisabled:
-				httpresp.SendError(c, resperror.NewError(resperror.TooManyRequests))
-				return
-			case user.UserVerified:
-				httpresp.SendError(c, resperror.NewError(resperror.UserAlreadyVerifiedError))
-				return
-			}
-		}
-		// Just return 500 internal server error by default
 		httpresp.SendError(c, err)
 		return
 	}
@@ -139,7 +240,7 @@ func (h *UserHandler) ResendVerificationEmail(c *gin.Context) {
 }
 
 // @Summary Reset password
-// @Description Starts reset password process by generating and sending 6-digit token to provided email if account exists
+// @Description Starts reset password process by sending 6-character code (and single-click reset link if configured) to provided email if account exists
 // @Tags user
 // @Accept json
 // @Param req body ResetPasswordRequest true "Specify email of account to reset"
@@ -150,7 +251,7 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 	var req ResetPasswordRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		httpresp.SendBindError(c, err)
 		return
 	}
 
@@ -162,7 +263,7 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 }
 
 // @Summary Reset password auth code exchange
-// @Description Verify 6-digit token and exchange token with auth code to set new password
+// @Description Verify 6-character code or token from reset link, and exchange it for auth code to set new password. Code is invalidated after too many failed attempts
 // @Tags user
 // @Accept json
 // @Param req body ResetPWAuthCodeExchangeRequest true "Specify both email and token"
@@ -174,13 +275,13 @@ func (h *UserHandler) ResetPasswordAuthCodeExchange(c *gin.Context) {
 	var req ResetPWAuthCodeExchangeRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		httpresp.SendBindError(c, err)
 		return
 	}
 
 	authCode, err := h.userService.ResetPwAuthCodeExchange(c, req.Token, req.Email)
 	if err != nil {
-		httpresp.SendError(c, resperror.NewError(resperror.InvalidTokenError))
+		httpresp.SendErrorWithFallback(c, err, resperror.NewError(resperror.InvalidTokenError))
 		return
 	}
 
@@ -196,19 +297,20 @@ func (h *UserHandler) ResetPasswordAuthCodeExchange(c *gin.Context) {
 // @Accept json
 // @Param req body SetNewPWRequest true "Specify new password for account"
 // @Produce json
+// @Failure 400 {object} httpresp.StandardResponse{details=[]password.Violation} "Password does not meet requirements"
 // @Failure 401 {object} httpresp.StandardResponse "Auth code rejected"
 // @Success 200 {object} httpresp.StandardResponse
 // @Router /user/reset_password/set_password [post]
 func (h *UserHandler) SetNewPassword(c *gin.Context) {
 	var req SetNewPWRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		httpresp.SendError(c, resperror.NewError(resperror.BadRequest))
+		httpresp.SendBindError(c, err)
 		return
 	}
 
 	err := h.userService.SetNewPassword(c, req.Email, req.AuthCode, req.NewPassword)
 	if err != nil {
-		httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+		httpresp.SendErrorWithFallback(c, err, resperror.NewError(resperror.Unauthorized))
 		return
 	}
 	httpresp.SendSuccess(c)
@@ -1,9 +1,22 @@
 package handler
 
 import (
+	"github.com/dominiclet/golang-base/handler/billing"
+	"github.com/dominiclet/golang-base/handler/email"
+	"github.com/dominiclet/golang-base/handler/notification"
+	"github.com/dominiclet/golang-base/handler/oauth"
 	"github.com/dominiclet/golang-base/handler/session"
+	"github.com/dominiclet/golang-base/handler/twofactor"
 	"github.com/dominiclet/golang-base/handler/user"
 	"github.com/google/wire"
 )
 
-var HandlerSet = wire.NewSet(user.InitUserHandler, session.InitSessionHandler)
+var HandlerSet = wire.NewSet(
+	user.InitUserHandler,
+	session.InitSessionHandler,
+	billing.InitBillingHandler,
+	twofactor.InitTwoFactorHandler,
+	oauth.InitOAuthHandler,
+	email.InitEmailHandler,
+	notification.InitNotificationHandler,
+)
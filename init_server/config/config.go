@@ -1,79 +1,269 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strings"
 
+	"github.com/dominiclet/golang-base/init_server/env"
 	"github.com/dominiclet/golang-base/init_server/logger"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	DB     string `yaml:"db"`
-	Domain string `yaml:"domain"`
-	Email  Email  `yaml:"email"`
+	DB         string     `yaml:"db"`
+	Migrations Migrations `yaml:"migrations"`
+	Domain     string     `yaml:"domain"`
+	Email      Email      `yaml:"email"`
+	Billing    Billing    `yaml:"billing"`
+	Security   Security   `yaml:"security"`
+	API        API        `yaml:"api"`
+
+	PasswordPolicy  PasswordPolicy  `yaml:"password_policy"`
+	PasswordHashing PasswordHashing `yaml:"password_hashing"`
+	PasswordReset   PasswordReset   `yaml:"password_reset"`
+
+	OIDCProviders []OIDCProvider `yaml:"oidc_providers"`
+	OAuth         OAuth          `yaml:"oauth"`
+	LDAP          LDAP           `yaml:"ldap"`
+}
+
+// Database schema migrations (in init_server/migrate/migrations)
+type Migrations struct {
+	RunOnStartup       bool `yaml:"run_on_startup"`       // Apply pending migrations when the server starts
+	LockTimeoutSeconds int  `yaml:"lock_timeout_seconds"` // How long to wait for another instance that is migrating. Defaults to 60
 }
 
 type Email struct {
+	Transport     string `yaml:"transport"` // smtp (default), file, log or memory
 	ServerAddress string `yaml:"server_address"`
 	ServerPort    int    `yaml:"server_port"`
 	EmailAddress  string `yaml:"email_address"`
 	AppPassword   string `yaml:"app_password"`
+	MailDir       string `yaml:"mail_dir"`     // Maildir that messages are written to by the file transport
+	DisplayName   string `yaml:"display_name"` // Sender name, also used in email templates. Defaults to domain
+	TemplateDir   string `yaml:"template_dir"` // Directory with templates overriding the built-in email templates
+	// Bearer token that bounce and complaint notifications must be sent with. Notifications are rejected if not set
+	BounceWebhookSecret string `yaml:"bounce_webhook_secret"`
+
+	Outbox EmailOutbox `yaml:"outbox"`
+	DKIM   DKIM        `yaml:"dkim"`
+}
+
+// DKIM signing of outgoing mail. Messages are not signed if not set.
+// The public key must be published in DNS at <selector>._domainkey.<domain>
+type DKIM struct {
+	Domain         string `yaml:"domain"`
+	Selector       string `yaml:"selector"`
+	PrivateKeyPath string `yaml:"private_key_path"` // PEM-encoded RSA or Ed25519 private key
+}
+
+// Delivery of queued emails. Defaults are used for unset values
+type EmailOutbox struct {
+	Workers             int `yaml:"workers"`
+	MaxAttempts         int `yaml:"max_attempts"`          // Messages are dead-lettered after this many failed attempts
+	PollIntervalSeconds int `yaml:"poll_interval_seconds"` // How often workers check for due messages
+	RetentionDays       int `yaml:"retention_days"`        // Sent, suppressed and dead messages are deleted after this long. Defaults to 30
+}
+
+// Billing is optional. Webhooks are rejected if provider is not set
+type Billing struct {
+	Provider      string `yaml:"provider"`
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+// Format of API responses
+type API struct {
+	// Send errors as RFC 9457 problem details (application/problem+json) by default. Otherwise only
+	// clients that accept application/problem+json get problem details, and others the standard response
+	ProblemDetails bool `yaml:"problem_details"`
+	// Problem type URIs are the base URI followed by the error code. Defaults to https://{domain}/problems/
+	ProblemTypeBaseURI string `yaml:"problem_type_base_uri"`
+}
+
+type Security struct {
+	EncryptionKey string `yaml:"encryption_key"` // Base64-encoded 32-byte key used to encrypt secrets at rest
+	TOTPIssuer    string `yaml:"totp_issuer"`    // Issuer shown in authenticator apps (defaults to domain)
+	// Base64-encoded key of at least 32 bytes used to sign links in emails (eg. unsubscribe links).
+	// Required outside dev mode. In dev mode, a random key is used if not set
+	LinkSigningKey string `yaml:"link_signing_key"`
+	// IPs or CIDRs of reverse proxies whose X-Forwarded-For headers are trusted for client IPs
+	// (eg. for login throttling). Forwarding headers are ignored if not set
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// Requirements for new passwords. Unset fields use defaults
+type PasswordPolicy struct {
+	MinLength        int    `yaml:"min_length"`         // Defaults to 8
+	MaxLength        int    `yaml:"max_length"`         // In bytes. Defaults to (and cannot exceed) bcrypt's limit of 72
+	MinCharClasses   int    `yaml:"min_char_classes"`   // Of lowercase, uppercase, digits and symbols. Defaults to 2
+	BreachedListPath string `yaml:"breached_list_path"` // File of SHA-1 hashes or plaintext passwords, one per line
+}
+
+// Algorithm and parameters for new password hashes. Existing hashes are upgraded on login
+type PasswordHashing struct {
+	Algorithm         string `yaml:"algorithm"`          // argon2id (default) or bcrypt
+	Argon2Memory      uint32 `yaml:"argon2_memory"`      // In KiB. Defaults to 19456
+	Argon2Iterations  uint32 `yaml:"argon2_iterations"`  // Defaults to 2
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism"` // Defaults to 1
+	BcryptCost        int    `yaml:"bcrypt_cost"`        // Defaults to 10
+	Pepper            string `yaml:"pepper"`             // Base64-encoded server-side secret (optional, cannot be removed once set)
+}
+
+type PasswordReset struct {
+	// Frontend page that reset links point to (receives email and token query parameters).
+	// Reset emails only contain a code if not set
+	LinkURL string `yaml:"link_url"`
+}
+
+// External OpenID Connect provider that users can log in with. A new identity is linked to the user
+// with the same email, so the provider must only set email_verified for emails it has verified
+type OIDCProvider struct {
+	Name         string   `yaml:"name"` // Used in login and callback URLs
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"` // Defaults to openid, email and profile
+}
+
+// Settings for acting as an OAuth2/OpenID Connect authorization server for other apps. Disabled if enabled is not set
+type OAuth struct {
+	Enabled        bool   `yaml:"enabled"`
+	SigningKeyPath string `yaml:"signing_key_path"` // PEM-encoded RSA private key for signing ID tokens. Ephemeral key is generated in dev mode if not set
+	LoginURL       string `yaml:"login_url"`        // Login page users without a session are sent to (receives return_to query parameter)
+	ConsentURL     string `yaml:"consent_url"`      // Consent page (receives request_id query parameter)
+}
+
+// LDAP directory users can log in with. Disabled if url is not set
+type LDAP struct {
+	URL            string         `yaml:"url"`              // eg. ldaps://ldap.example.com:636
+	BindDNTemplate string         `yaml:"bind_dn_template"` // eg. uid={username},ou=people,dc=example,dc=com
+	StartTLS       bool           `yaml:"start_tls"`
+	Attributes     LDAPAttributes `yaml:"attributes"`
+}
+
+// Directory attributes mapped to user fields
+type LDAPAttributes struct {
+	Email string `yaml:"email"` // Defaults to mail
+	Name  string `yaml:"name"`  // Defaults to cn
 }
 
 const (
-	confPathEnvVar  = "CONFIG_PATH"
+	PathEnvVar      = "CONFIG_PATH" // Env var with path of config file
 	defaultConfPath = "/opt/backend/config.yaml"
 )
 
 func InitConfig() *Config {
 	logger := logger.GetLogger()
 
-	var config Config
-	configPath := defaultConfPath
-	// Use provided config path if given in env var
-	tmpConfPath, ok := os.LookupEnv(confPathEnvVar)
-	if ok {
-		configPath = tmpConfPath
-	}
+	configPath := Path()
 	logger.WithField("configFile", configPath).
 		Info("Reading from config file")
-	confData, err := os.ReadFile(configPath)
-	if err != nil {
-		panic(fmt.Sprintf("Unable to read config file: %v", err))
-	}
-	err = yaml.Unmarshal(confData, &config)
+	config, err := LoadConfig(configPath)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to unmarshal config yaml file: %v", err))
+		panic(err.Error())
 	}
 
-	config.validateConfig()
-
 	logger.WithFields(logrus.Fields{
 		"DB": config.DB,
 	}).Info("Initialized configs")
 
-	return &config
+	return config
+}
+
+// Path of config file. Provided path is used if given in env var
+func Path() string {
+	if path, ok := os.LookupEnv(PathEnvVar); ok {
+		return path
+	}
+	return defaultConfPath
+}
+
+// Reads and validates config file
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	confData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+	err = yaml.Unmarshal(confData, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config yaml file: %w", err)
+	}
+	if err := config.validateConfig(); err != nil {
+		return nil, err
+	}
+	return &config, nil
 }
 
-func (c *Config) validateConfig() {
+func (c *Config) validateConfig() error {
 	if c.DB == "" {
-		panic("DB field not set")
+		return errors.New("DB field not set")
 	}
 	if c.Domain == "" {
-		panic("domain not set")
+		return errors.New("domain not set")
 	}
-	if c.Email.ServerAddress == "" {
-		panic("email.server_address not set")
+	if c.Email.EmailAddress == "" {
+		return errors.New("email.email_address not set")
 	}
-	if c.Email.ServerPort == 0 {
-		panic("email.server_port not set")
+	switch c.Email.Transport {
+	case "", "smtp":
+		if c.Email.ServerAddress == "" {
+			return errors.New("email.server_address not set")
+		}
+		if c.Email.ServerPort == 0 {
+			return errors.New("email.server_port not set")
+		}
+		if c.Email.AppPassword == "" {
+			return errors.New("email.app_password not set")
+		}
+	case "file":
+		if c.Email.MailDir == "" {
+			return errors.New("email.mail_dir not set")
+		}
+	case "log", "memory":
+	default:
+		return errors.New("email.transport must be smtp, file, log or memory")
 	}
-	if c.Email.EmailAddress == "" {
-		panic("email.email_address not set")
+	dkim := c.Email.DKIM
+	if (dkim.Domain != "" || dkim.Selector != "" || dkim.PrivateKeyPath != "") &&
+		(dkim.Domain == "" || dkim.Selector == "" || dkim.PrivateKeyPath == "") {
+		return errors.New("email.dkim: domain, selector and private_key_path must all be set")
+	}
+	// Links signed with a random key stop working on restart and on other instances
+	if c.Security.LinkSigningKey == "" && !env.IsDevDirect() {
+		return errors.New("security.link_signing_key must be set outside dev mode")
+	}
+	if c.Billing.Provider != "" && c.Billing.WebhookSecret == "" {
+		return errors.New("billing.webhook_secret not set")
+	}
+	for i, provider := range c.OIDCProviders {
+		if provider.Name == "" || provider.Issuer == "" || provider.ClientID == "" {
+			return fmt.Errorf("oidc_providers[%d]: name, issuer and client_id must be set", i)
+		}
+	}
+	for _, proxy := range c.Security.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("security.trusted_proxies: %s is not an IP or CIDR", proxy)
+			}
+		}
 	}
-	if c.Email.AppPassword == "" {
-		panic("email.app_password not set")
+	if c.LDAP.URL != "" {
+		ldapURL, err := url.Parse(c.LDAP.URL)
+		if err != nil || (ldapURL.Scheme != "ldap" && ldapURL.Scheme != "ldaps") || ldapURL.Hostname() == "" {
+			return errors.New("ldap.url must be an ldap:// or ldaps:// URL with a host")
+		}
+		if c.LDAP.StartTLS && ldapURL.Scheme == "ldaps" {
+			return errors.New("ldap.start_tls cannot be used with an ldaps:// URL")
+		}
+		if !strings.Contains(c.LDAP.BindDNTemplate, "{username}") {
+			return errors.New("ldap.bind_dn_template must contain {username}")
+		}
 	}
+	return nil
 }
@@ -1,15 +1,30 @@
 package initserver
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/migrate"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
+// Opens the DB and applies pending migrations if configured to
 func InitGormDB(config *config.Config) *gorm.DB {
+	db := OpenGormDB(config)
+	if config.Migrations.RunOnStartup {
+		if _, err := migrate.InitMigrator(db, config).Up(context.Background()); err != nil {
+			panic(fmt.Sprintf("Failed to migrate DB: %v", err))
+		}
+	}
+	return db
+}
+
+// Opens the DB without migrating it
+func OpenGormDB(config *config.Config) *gorm.DB {
 	var db *gorm.DB
 	var err error
 	for {
@@ -0,0 +1,278 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// Name of the MySQL advisory lock held while migrating, so that only one instance migrates at a time
+	lockName           = "schema_migrations"
+	defaultLockTimeout = 60 * time.Second
+)
+
+var ErrLockTimeout = errors.New("timed out waiting for another instance to finish migrating")
+
+const createMigrationsTable = "CREATE TABLE IF NOT EXISTS `schema_migrations` (" +
+	"`version` bigint PRIMARY KEY, " +
+	"`name` varchar(255) NOT NULL, " +
+	"`checksum` char(64) NOT NULL, " +
+	"`applied_at` timestamp DEFAULT CURRENT_TIMESTAMP)"
+
+type Migrator struct {
+	db          *gorm.DB
+	logger      *logrus.Entry
+	migrations  []Migration
+	lockTimeout time.Duration
+}
+
+func InitMigrator(db *gorm.DB, config *config.Config) *Migrator {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load migrations: %v", err))
+	}
+
+	lockTimeout := defaultLockTimeout
+	if config.Migrations.LockTimeoutSeconds > 0 {
+		lockTimeout = time.Duration(config.Migrations.LockTimeoutSeconds) * time.Second
+	}
+
+	return &Migrator{
+		db:          db,
+		logger:      logger.GetLogger().WithField("module", "migrate"),
+		migrations:  migrations,
+		lockTimeout: lockTimeout,
+	}
+}
+
+// Applies pending migrations in order of version. Returns the migrations that were applied.
+// Fails without applying anything if an applied migration has been modified
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		done, err := m.appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(done); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(conn, migration, migration.Up); err != nil {
+				return err
+			}
+			record := SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum,
+				AppliedAt: time.Now(),
+			}
+			if err := conn.Create(&record).Error; err != nil {
+				return err
+			}
+			m.logger.WithFields(logrus.Fields{
+				"version": migration.Version,
+				"name":    migration.Name,
+			}).Info("Applied migration")
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Rolls back the last n applied migrations. Returns the migrations that were rolled back
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		var records []SchemaMigration
+		if err := conn.Order("version DESC").Limit(n).Find(&records).Error; err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			migration, ok := m.find(record.Version)
+			if !ok {
+				return fmt.Errorf("migration %d_%s is not in this binary and cannot be rolled back", record.Version, record.Name)
+			}
+			if migration.Checksum != record.Checksum {
+				return fmt.Errorf("migration %d_%s was modified after it was applied", record.Version, record.Name)
+			}
+			if err := m.run(conn, migration, migration.Down); err != nil {
+				return err
+			}
+			if err := conn.Delete(&record).Error; err != nil {
+				return err
+			}
+			m.logger.WithFields(logrus.Fields{
+				"version": migration.Version,
+				"name":    migration.Name,
+			}).Info("Rolled back migration")
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Records migrations up to and including version as applied without running them.
+// Used to start tracking a database whose schema was created before migrations were introduced
+func (m *Migrator) Baseline(ctx context.Context, version uint) error {
+	if _, ok := m.find(version); !ok {
+		return fmt.Errorf("there is no migration %d", version)
+	}
+	return m.withLock(ctx, func(conn *gorm.DB) error {
+		done, err := m.appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if len(done) > 0 {
+			return errors.New("database already has applied migrations")
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			record := SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum,
+				AppliedAt: time.Now(),
+			}
+			if err := conn.Create(&record).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Lists embedded migrations and applied migrations that are not embedded, in order of version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done := map[uint]SchemaMigration{}
+	db := m.db.WithContext(ctx)
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		var err error
+		if done, err = m.appliedMigrations(db); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := done[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = record.Checksum != migration.Checksum
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range done {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, Status{
+			Version:   record.Version,
+			Name:      record.Name,
+			AppliedAt: &appliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Runs fn on a single connection while holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		// Start a new session so that statements run on conn do not share conditions
+		conn = conn.Session(&gorm.Session{NewDB: true})
+
+		var acquired sql.NullInt64
+		err := conn.Raw("SELECT GET_LOCK(?, ?)", lockName, int(m.lockTimeout.Seconds())).Scan(&acquired).Error
+		if err != nil {
+			return err
+		}
+		if !acquired.Valid || acquired.Int64 != 1 {
+			return ErrLockTimeout
+		}
+		defer func() {
+			var released sql.NullInt64
+			if err := conn.Raw("SELECT RELEASE_LOCK(?)", lockName).Scan(&released).Error; err != nil {
+				m.logger.WithError(err).Error("Failed to release migration lock")
+			}
+		}()
+
+		if err := conn.Exec(createMigrationsTable).Error; err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) appliedMigrations(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[uint]SchemaMigration, len(records))
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
+
+// Checks that applied migrations have not been modified. Applied migrations that are not
+// embedded are only logged, as they are expected while a newer version is being rolled out
+func (m *Migrator) verify(done map[uint]SchemaMigration) error {
+	for version, record := range done {
+		migration, ok := m.find(version)
+		if !ok {
+			m.logger.WithFields(logrus.Fields{
+				"version": version,
+				"name":    record.Name,
+			}).Warn("Database has a migration that is not in this binary")
+			continue
+		}
+		if migration.Checksum != record.Checksum {
+			return fmt.Errorf("migration %d_%s was modified after it was applied", version, record.Name)
+		}
+	}
+	return nil
+}
+
+// Runs the statements of script. MySQL commits schema changes immediately, so the statements
+// before a failed statement are not rolled back and must be reverted manually
+func (m *Migrator) run(conn *gorm.DB, migration Migration, script string) error {
+	for _, statement := range splitStatements(script) {
+		if err := conn.Exec(statement).Error; err != nil {
+			return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version uint) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
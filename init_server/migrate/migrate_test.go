@@ -0,0 +1,169 @@
+package migrate
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/lib/dbtest"
+	sqlite "github.com/glebarez/go-sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	// SQLite has no advisory locks. Tests use a single connection, so the lock always succeeds
+	lock := func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return int64(1), nil
+	}
+	sqlite.MustRegisterScalarFunction("GET_LOCK", 2, lock)
+	sqlite.MustRegisterScalarFunction("RELEASE_LOCK", 1, lock)
+}
+
+var (
+	addForeignKeyRegex = regexp.MustCompile("(?i)^ALTER TABLE `?\\w+`? ADD FOREIGN KEY")
+	afterColumnRegex   = regexp.MustCompile("(?i) AFTER `?\\w+`?")
+	dropIndexRegex     = regexp.MustCompile("(?i)^(DROP INDEX `?\\w+`?) ON `?\\w+`?$")
+	addColumnNowRegex  = regexp.MustCompile("(?i)^(ALTER TABLE .* ADD COLUMN .*) DEFAULT CURRENT_TIMESTAMP")
+)
+
+// Rewrites MySQL-only syntax of the migrations for SQLite, so that they can run on test databases.
+// Foreign keys cannot be added to existing SQLite tables and are left out
+func sqliteScript(script string) string {
+	var statements []string
+	for _, statement := range splitStatements(script) {
+		if addForeignKeyRegex.MatchString(statement) {
+			continue
+		}
+		statement = strings.ReplaceAll(statement, "AUTO_INCREMENT", "AUTOINCREMENT")
+		statement = afterColumnRegex.ReplaceAllString(statement, "")
+		statement = dropIndexRegex.ReplaceAllString(statement, "$1")
+		// Added columns cannot default to the current time
+		statement = addColumnNowRegex.ReplaceAllString(statement, "$1 DEFAULT '1970-01-01 00:00:00'")
+		statements = append(statements, statement)
+	}
+	return strings.Join(statements, ";\n")
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, *gorm.DB) {
+	db := dbtest.Open(t)
+	m := InitMigrator(db, &config.Config{})
+	for i := range m.migrations {
+		m.migrations[i].Up = sqliteScript(m.migrations[i].Up)
+		m.migrations[i].Down = sqliteScript(m.migrations[i].Down)
+	}
+	return m, db
+}
+
+// Definitions of all tables and indexes, except internal ones
+func schema(t *testing.T, db *gorm.DB) map[string]string {
+	t.Helper()
+	var rows []struct {
+		Name string
+		SQL  string `gorm:"column:sql"`
+	}
+	err := db.Raw("SELECT name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name != ? AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\'",
+		SchemaMigration{}.TableName()).Scan(&rows).Error
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	schema := map[string]string{}
+	for _, row := range rows {
+		schema[row.Name] = row.SQL
+	}
+	return schema
+}
+
+func TestUpAppliesAllMigrationsOnce(t *testing.T) {
+	m, db := newTestMigrator(t)
+	ctx := context.Background()
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != len(m.migrations) {
+		t.Fatalf("Applied %d migrations, want %d", len(applied), len(m.migrations))
+	}
+	for i, migration := range applied {
+		if migration.Version != m.migrations[i].Version {
+			t.Errorf("Migration %d applied as no. %d", migration.Version, i+1)
+		}
+	}
+	for _, table := range []string{"users", "sessions", "email_outbox", "oauth_consent_requests"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("Table %s not created", table)
+		}
+	}
+	migrated := schema(t, db)
+
+	applied, err = m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Second Up failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Second Up applied %d migrations, want none", len(applied))
+	}
+	if after := schema(t, db); len(after) != len(migrated) {
+		t.Errorf("Second Up changed schema from %d to %d tables and indexes", len(migrated), len(after))
+	} else {
+		for name, sql := range migrated {
+			if after[name] != sql {
+				t.Errorf("Second Up changed %s", name)
+			}
+		}
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil || status.Modified || status.Unknown {
+			t.Errorf("Unexpected status %+v", status)
+		}
+	}
+}
+
+func TestDownRevertsAllMigrations(t *testing.T) {
+	m, db := newTestMigrator(t)
+	ctx := context.Background()
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	rolledBack, err := m.Down(ctx, len(m.migrations))
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if len(rolledBack) != len(m.migrations) {
+		t.Fatalf("Rolled back %d migrations, want %d", len(rolledBack), len(m.migrations))
+	}
+	if remaining := schema(t, db); len(remaining) != 0 {
+		t.Errorf("Tables and indexes left after Down: %v", remaining)
+	}
+
+	// Migrations can be applied again after rolling back
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up after Down failed: %v", err)
+	}
+	if len(applied) != len(m.migrations) {
+		t.Errorf("Applied %d migrations after Down, want %d", len(applied), len(m.migrations))
+	}
+}
+
+func TestUpRejectsModifiedMigration(t *testing.T) {
+	m, _ := newTestMigrator(t)
+	ctx := context.Background()
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	m.migrations[0].Checksum = "modified"
+	if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Errorf("Got error %v, want modified migration error", err)
+	}
+}
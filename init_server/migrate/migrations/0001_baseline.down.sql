@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS `notification_preferences`;
+DROP TABLE IF EXISTS `email_suppressions`;
+DROP TABLE IF EXISTS `email_outbox`;
+DROP TABLE IF EXISTS `password_reset_tokens`;
+DROP TABLE IF EXISTS `audit_events`;
+DROP TABLE IF EXISTS `account_unlock_tokens`;
+DROP TABLE IF EXISTS `login_throttles`;
+DROP TABLE IF EXISTS `oauth_tokens`;
+DROP TABLE IF EXISTS `oauth_authorization_codes`;
+DROP TABLE IF EXISTS `oauth_consent_requests`;
+DROP TABLE IF EXISTS `oauth_consents`;
+DROP TABLE IF EXISTS `oauth_clients`;
+DROP TABLE IF EXISTS `oidc_auth_requests`;
+DROP TABLE IF EXISTS `external_identities`;
+DROP TABLE IF EXISTS `magic_link_tokens`;
+DROP TABLE IF EXISTS `two_factor_challenges`;
+DROP TABLE IF EXISTS `recovery_codes`;
+DROP TABLE IF EXISTS `billing_events`;
+DROP TABLE IF EXISTS `usage_counters`;
+DROP TABLE IF EXISTS `sessions`;
+DROP TABLE IF EXISTS `users`;
@@ -0,0 +1,274 @@
+CREATE TABLE `users` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `uuid` varchar(63) NOT NULL,
+    `name` varchar(255) NOT NULL,
+    `email` varchar(255) NOT NULL,
+    `password` varchar(255),
+    `created_at` timestamp,
+    `deleted_at` timestamp NULL,
+    `updated_at` timestamp,
+    `account_type` integer NOT NULL DEFAULT 0,
+    `license_expiry` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `is_verified` int(1) NOT NULL DEFAULT 0,
+    `verification_token` varchar(127),
+    `totp_secret` varchar(255),
+    `totp_enabled` int(1) NOT NULL DEFAULT 0,
+    `totp_last_step` bigint NOT NULL DEFAULT 0,
+    `is_admin` int(1) NOT NULL DEFAULT 0,
+    `language` varchar(35)
+);
+CREATE UNIQUE INDEX user_uuid ON users (uuid);
+
+CREATE TABLE `sessions` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer,
+    `token` varchar(255),
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `expires_at` timestamp
+);
+
+ALTER TABLE `sessions` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `usage_counters` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `metric` varchar(63) NOT NULL,
+    `bucket_start` timestamp NOT NULL,
+    `count` bigint NOT NULL DEFAULT 0,
+    `updated_at` timestamp
+);
+CREATE UNIQUE INDEX idx_usage_counter ON usage_counters (user_id, metric, bucket_start);
+
+ALTER TABLE `usage_counters` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `billing_events` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `provider` varchar(63) NOT NULL,
+    `event_id` varchar(255) NOT NULL,
+    `type` varchar(63) NOT NULL,
+    `user_id` integer,
+    `processed_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_billing_event ON billing_events (provider, event_id);
+
+ALTER TABLE `billing_events` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `recovery_codes` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `code_hash` varchar(127) NOT NULL,
+    `used_at` timestamp NULL,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX recovery_code_user ON recovery_codes (user_id, code_hash);
+
+ALTER TABLE `recovery_codes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `two_factor_challenges` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `token_hash` varchar(127) NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `expires_at` timestamp NOT NULL,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX two_factor_challenge_token ON two_factor_challenges (token_hash);
+
+ALTER TABLE `two_factor_challenges` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `magic_link_tokens` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `token_hash` varchar(127) NOT NULL,
+    `binding_hash` varchar(127),
+    `expires_at` timestamp NOT NULL,
+    `used_at` timestamp NULL,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX magic_link_token ON magic_link_tokens (token_hash);
+CREATE INDEX magic_link_user_created ON magic_link_tokens (user_id, created_at);
+
+ALTER TABLE `magic_link_tokens` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `external_identities` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `provider` varchar(63) NOT NULL,
+    `subject` varchar(255) NOT NULL,
+    `email` varchar(255),
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_external_identity ON external_identities (provider, subject);
+
+ALTER TABLE `external_identities` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `oidc_auth_requests` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `provider` varchar(63) NOT NULL,
+    `state_hash` varchar(127) NOT NULL,
+    `nonce` varchar(127) NOT NULL,
+    `code_verifier` varchar(127) NOT NULL,
+    `expires_at` timestamp NOT NULL,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX oidc_auth_request_state ON oidc_auth_requests (provider, state_hash);
+
+CREATE TABLE `oauth_clients` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `client_id` varchar(63) NOT NULL,
+    `secret_hash` varchar(127),
+    `name` varchar(255) NOT NULL,
+    `redirect_uris` text NOT NULL,
+    `public` int(1) NOT NULL DEFAULT 0,
+    `skip_consent` int(1) NOT NULL DEFAULT 0,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX oauth_client_id ON oauth_clients (client_id);
+
+CREATE TABLE `oauth_consents` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `client_id` varchar(63) NOT NULL,
+    `scopes` text NOT NULL,
+    `updated_at` timestamp
+);
+CREATE UNIQUE INDEX oauth_consent_user_client ON oauth_consents (user_id, client_id);
+
+ALTER TABLE `oauth_consents` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `oauth_consent_requests` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `request_id` varchar(127) NOT NULL,
+    `user_id` integer NOT NULL,
+    `client_id` varchar(63) NOT NULL,
+    `redirect_uri` varchar(2047) NOT NULL,
+    `scopes` text NOT NULL,
+    `state` varchar(1023),
+    `nonce` varchar(255),
+    `code_challenge` varchar(127) NOT NULL,
+    `expires_at` timestamp NOT NULL
+);
+CREATE UNIQUE INDEX oauth_consent_request_id ON oauth_consent_requests (request_id);
+
+ALTER TABLE `oauth_consent_requests` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `oauth_authorization_codes` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `code_hash` varchar(127) NOT NULL,
+    `grant_id` varchar(127) NOT NULL,
+    `client_id` varchar(63) NOT NULL,
+    `user_id` integer NOT NULL,
+    `redirect_uri` varchar(2047) NOT NULL,
+    `scopes` text NOT NULL,
+    `nonce` varchar(255),
+    `code_challenge` varchar(127) NOT NULL,
+    `auth_time` timestamp NOT NULL,
+    `expires_at` timestamp NOT NULL,
+    `used_at` timestamp NULL
+);
+CREATE UNIQUE INDEX oauth_authorization_code ON oauth_authorization_codes (code_hash);
+
+ALTER TABLE `oauth_authorization_codes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `oauth_tokens` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `token_hash` varchar(127) NOT NULL,
+    `type` varchar(31) NOT NULL,
+    `grant_id` varchar(127) NOT NULL,
+    `client_id` varchar(63) NOT NULL,
+    `user_id` integer NOT NULL,
+    `scopes` text NOT NULL,
+    `expires_at` timestamp NOT NULL,
+    `revoked_at` timestamp NULL,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX oauth_token ON oauth_tokens (token_hash);
+CREATE INDEX oauth_token_grant ON oauth_tokens (grant_id);
+CREATE INDEX oauth_token_user ON oauth_tokens (user_id);
+
+ALTER TABLE `oauth_tokens` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `login_throttles` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `scope` varchar(31) NOT NULL,
+    `throttle_key` varchar(255) NOT NULL,
+    `failures` bigint NOT NULL DEFAULT 0,
+    `window_start` timestamp NOT NULL,
+    `next_attempt_at` timestamp NULL,
+    `locked_until` timestamp NULL,
+    `updated_at` timestamp
+);
+CREATE UNIQUE INDEX login_throttle_key ON login_throttles (scope, throttle_key);
+
+CREATE TABLE `account_unlock_tokens` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `token_hash` varchar(127) NOT NULL,
+    `expires_at` timestamp NOT NULL,
+    `used_at` timestamp NULL,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX account_unlock_token ON account_unlock_tokens (token_hash);
+
+ALTER TABLE `account_unlock_tokens` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `audit_events` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `event` varchar(63) NOT NULL,
+    `user_id` integer,
+    `ip` varchar(63),
+    `details` text,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX audit_event_user ON audit_events (user_id, created_at);
+CREATE INDEX audit_event_created ON audit_events (event, created_at);
+
+CREATE TABLE `password_reset_tokens` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `purpose` varchar(15) NOT NULL,
+    `token_hash` varchar(127) NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `expires_at` timestamp NOT NULL,
+    `used_at` timestamp NULL,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX password_reset_token_user ON password_reset_tokens (user_id, purpose, created_at);
+
+ALTER TABLE `password_reset_tokens` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`id`);
+
+CREATE TABLE `email_outbox` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `recipient` varchar(255) NOT NULL,
+    `subject` varchar(255) NOT NULL,
+    `text_body` mediumtext NOT NULL,
+    `html_body` mediumtext NOT NULL,
+    `headers` text,
+    `status` varchar(15) NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `next_attempt_at` timestamp NOT NULL,
+    `last_error` text,
+    `sent_at` timestamp NULL,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX email_outbox_due ON email_outbox (status, next_attempt_at);
+
+CREATE TABLE `email_suppressions` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `email` varchar(255) NOT NULL,
+    `reason` varchar(15) NOT NULL,
+    `details` text,
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX email_suppression ON email_suppressions (email);
+
+CREATE TABLE `notification_preferences` (
+    `id` integer PRIMARY KEY AUTO_INCREMENT,
+    `user_id` integer NOT NULL,
+    `category` varchar(31) NOT NULL,
+    `enabled` boolean NOT NULL,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_notification_preference ON notification_preferences (user_id, category);
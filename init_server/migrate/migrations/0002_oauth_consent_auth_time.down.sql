@@ -0,0 +1 @@
+ALTER TABLE `oauth_consent_requests` DROP COLUMN `auth_time`;
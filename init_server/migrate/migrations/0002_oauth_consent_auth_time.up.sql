@@ -0,0 +1,3 @@
+-- Login time of the user, which consent requests carry over to the authorization code.
+-- Pending requests created before this migration use the time of the migration
+ALTER TABLE `oauth_consent_requests` ADD COLUMN `auth_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER `code_challenge`;
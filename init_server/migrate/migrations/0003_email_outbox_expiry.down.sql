@@ -0,0 +1,2 @@
+DROP INDEX email_outbox_finished ON email_outbox;
+ALTER TABLE `email_outbox` DROP COLUMN `expires_at`;
@@ -0,0 +1,5 @@
+-- Messages with tokens are not sent after the tokens expire
+ALTER TABLE `email_outbox` ADD COLUMN `expires_at` timestamp NULL AFTER `next_attempt_at`;
+-- Content of messages that are no longer queued is removed, as it may contain tokens
+UPDATE `email_outbox` SET `text_body` = '', `html_body` = '', `headers` = NULL WHERE `status` IN ('sent', 'suppressed', 'dead');
+CREATE INDEX email_outbox_finished ON email_outbox (status, updated_at);
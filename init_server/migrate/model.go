@@ -0,0 +1,32 @@
+package migrate
+
+import "time"
+
+// Migration embedded in the binary
+type Migration struct {
+	Version  uint
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of up migration, compared with the checksum recorded when it was applied
+}
+
+// Row of schema_migrations, recorded when a migration is applied
+type SchemaMigration struct {
+	Version   uint `gorm:"primarykey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Status struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time // Not set if migration is pending
+	Modified  bool       // Migration was edited after it was applied
+	Unknown   bool       // Applied migration that is not embedded in this binary (eg. applied by a newer version)
+}
@@ -3,36 +3,81 @@ package initserver
 import (
 	"net/http"
 
+	"github.com/dominiclet/golang-base/handler/billing"
+	"github.com/dominiclet/golang-base/handler/email"
+	"github.com/dominiclet/golang-base/handler/notification"
+	"github.com/dominiclet/golang-base/handler/oauth"
 	"github.com/dominiclet/golang-base/handler/session"
+	"github.com/dominiclet/golang-base/handler/twofactor"
 	"github.com/dominiclet/golang-base/handler/user"
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
 	"github.com/dominiclet/golang-base/lib/httpresp"
 	"github.com/dominiclet/golang-base/middleware"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/metering"
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 )
 
 type RouterService struct {
 	middleware *middleware.Middleware
-
-	userHandler    *user.UserHandler
-	sessionHandler *session.SessionHandler
+	envVars    *env.EnvVars
+	config     *config.Config
+
+	userHandler         *user.UserHandler
+	sessionHandler      *session.SessionHandler
+	billingHandler      *billing.BillingHandler
+	twoFactorHandler    *twofactor.TwoFactorHandler
+	oauthHandler        *oauth.OAuthHandler
+	emailHandler        *email.EmailHandler
+	notificationHandler *notification.NotificationHandler
+
+	meteringService *metering.MeteringService
 }
 
 type Injector struct {
 	middleware *middleware.Middleware
-
-	userHandler    *user.UserHandler
-	sessionHandler *session.SessionHandler
+	envVars    *env.EnvVars
+	config     *config.Config
+
+	userHandler         *user.UserHandler
+	sessionHandler      *session.SessionHandler
+	billingHandler      *billing.BillingHandler
+	twoFactorHandler    *twofactor.TwoFactorHandler
+	oauthHandler        *oauth.OAuthHandler
+	emailHandler        *email.EmailHandler
+	notificationHandler *notification.NotificationHandler
+
+	meteringService *metering.MeteringService
 }
 
 func InitRouterService(inj *Injector) *RouterService {
 	return &RouterService{
 		inj.middleware,
+		inj.envVars,
+		inj.config,
 		inj.userHandler,
 		inj.sessionHandler,
+		inj.billingHandler,
+		inj.twoFactorHandler,
+		inj.oauthHandler,
+		inj.emailHandler,
+		inj.notificationHandler,
+		inj.meteringService,
 	}
 }
 
+// Reverse proxies that are trusted to set the client IP in forwarding headers
+func (rs *RouterService) TrustedProxies() []string {
+	return rs.config.Security.TrustedProxies
+}
+
+// Releases resources of services (eg. writes buffered usage). To be called once the server has stopped
+func (rs *RouterService) Close() {
+	rs.meteringService.Close()
+}
+
 var RouterSet = wire.NewSet(
 	wire.Struct(new(Injector), "*"),
 	InitRouterService,
@@ -41,6 +86,7 @@ var RouterSet = wire.NewSet(
 func (rs *RouterService) RegisterRoutes(r *gin.Engine) {
 	// All routes must start with /api
 	apiGroup := r.Group("/api")
+	apiGroup.Use(rs.middleware.RequestID(), rs.middleware.ErrorFormat())
 
 	apiGroup.GET("/ping", func(c *gin.Context) {
 		httpresp.SendData(c, "pong", http.StatusOK)
@@ -48,6 +94,16 @@ func (rs *RouterService) RegisterRoutes(r *gin.Engine) {
 
 	rs.registerUsers(apiGroup)
 	rs.registerSessions(apiGroup)
+	rs.registerBilling(apiGroup)
+	if rs.config.OAuth.Enabled {
+		rs.registerOAuth(apiGroup)
+	}
+	rs.registerEmail(apiGroup)
+	rs.registerNotifications(apiGroup)
+	rs.registerAdmin(apiGroup)
+	if rs.envVars.IsDev() {
+		rs.registerDev(apiGroup)
+	}
 }
 
 func (rs *RouterService) registerUsers(r *gin.RouterGroup) {
@@ -67,11 +123,103 @@ func (rs *RouterService) registerUsers(r *gin.RouterGroup) {
 	// Protected user endpoints
 	protectedUserGroup := userGroup.Group("")
 	protectedUserGroup.Use(rs.middleware.AuthRequired())
-	protectedUserGroup.GET("/:uuid", rs.userHandler.GetUser)
+	protectedUserGroup.GET("/me/entitlements", rs.userHandler.GetEntitlements)
+	protectedUserGroup.GET("/me/usage", rs.userHandler.GetUsage)
+	protectedUserGroup.PUT("/me/language", rs.userHandler.SetLanguage)
+	protectedUserGroup.GET("/me/notifications", rs.notificationHandler.GetPreferences)
+	protectedUserGroup.PUT("/me/notifications", rs.notificationHandler.SetPreference)
+
+	twoFactorGroup := protectedUserGroup.Group("/me/2fa")
+	twoFactorGroup.POST("/enroll", rs.twoFactorHandler.BeginEnrollment)
+	twoFactorGroup.POST("/confirm", rs.twoFactorHandler.ConfirmEnrollment)
+	twoFactorGroup.POST("/disable", rs.twoFactorHandler.Disable)
+
+	// Protected user endpoints that require API access and count towards API call quota
+	meteredUserGroup := protectedUserGroup.Group("")
+	meteredUserGroup.Use(rs.middleware.RequireFeature(entitlement.FeatureAPIAccess), rs.middleware.EnforceQuota(metering.MetricAPICalls))
+	meteredUserGroup.GET("/:uuid", rs.userHandler.GetUser)
+
+	exportGroup := protectedUserGroup.Group("")
+	exportGroup.Use(rs.middleware.RequireFeature(entitlement.FeatureExport), rs.middleware.EnforceQuota(metering.MetricExports))
+	exportGroup.GET("/me/export", rs.userHandler.ExportData)
 }
 
 func (rs *RouterService) registerSessions(r *gin.RouterGroup) {
 	sessionGroup := r.Group("/session")
 
 	sessionGroup.POST("login", rs.sessionHandler.UserLogin)
+	sessionGroup.POST("login/2fa", rs.sessionHandler.TwoFactorLogin)
+	sessionGroup.GET("unlock", rs.sessionHandler.UnlockAccount)
+	sessionGroup.POST("magic_link", rs.sessionHandler.RequestMagicLink)
+	sessionGroup.GET("magic_link/consume", rs.sessionHandler.ConsumeMagicLink)
+
+	oidcGroup := sessionGroup.Group("/oidc")
+	oidcGroup.GET("/providers", rs.sessionHandler.GetOIDCProviders)
+	oidcGroup.GET("/:provider/login", rs.sessionHandler.OIDCLogin)
+	oidcGroup.GET("/:provider/callback", rs.sessionHandler.OIDCCallback)
+}
+
+func (rs *RouterService) registerBilling(r *gin.RouterGroup) {
+	billingGroup := r.Group("/billing")
+
+	billingGroup.POST("/webhook", rs.billingHandler.Webhook)
+}
+
+func (rs *RouterService) registerOAuth(r *gin.RouterGroup) {
+	oauthGroup := r.Group("/oauth")
+
+	oauthGroup.GET("/.well-known/openid-configuration", rs.oauthHandler.Discovery)
+	oauthGroup.GET("/jwks", rs.oauthHandler.JWKS)
+	oauthGroup.GET("/authorize", rs.oauthHandler.Authorize)
+	oauthGroup.POST("/token", rs.oauthHandler.Token)
+	oauthGroup.POST("/revoke", rs.oauthHandler.Revoke)
+	oauthGroup.POST("/introspect", rs.oauthHandler.Introspect)
+	oauthGroup.GET("/userinfo", rs.oauthHandler.UserInfo)
+	oauthGroup.POST("/userinfo", rs.oauthHandler.UserInfo)
+
+	consentGroup := oauthGroup.Group("/consent")
+	consentGroup.Use(rs.middleware.AuthRequired())
+	consentGroup.GET("/:requestId", rs.oauthHandler.GetConsentRequest)
+	consentGroup.POST("/:requestId", rs.oauthHandler.DecideConsent)
+
+	// Client management is restricted to admins
+	clientGroup := oauthGroup.Group("/clients")
+	clientGroup.Use(rs.middleware.AuthRequired(), rs.middleware.AdminRequired())
+	clientGroup.POST("", rs.oauthHandler.RegisterClient)
+	clientGroup.GET("", rs.oauthHandler.ListClients)
+	clientGroup.DELETE("/:clientId", rs.oauthHandler.DeleteClient)
+}
+
+func (rs *RouterService) registerEmail(r *gin.RouterGroup) {
+	emailGroup := r.Group("/email")
+
+	emailGroup.POST("/notifications", rs.emailHandler.BounceNotification)
+}
+
+func (rs *RouterService) registerNotifications(r *gin.RouterGroup) {
+	notificationGroup := r.Group("/notifications")
+
+	notificationGroup.GET("/unsubscribe", rs.notificationHandler.UnsubscribePage)
+	notificationGroup.POST("/unsubscribe", rs.notificationHandler.Unsubscribe)
+}
+
+func (rs *RouterService) registerAdmin(r *gin.RouterGroup) {
+	adminGroup := r.Group("/admin")
+	adminGroup.Use(rs.middleware.AuthRequired(), rs.middleware.AdminRequired())
+
+	adminGroup.GET("/email_outbox", rs.emailHandler.ListOutbox)
+	adminGroup.POST("/email_outbox/:id/retry", rs.emailHandler.RetryOutboxMessage)
+	adminGroup.GET("/email_suppressions", rs.emailHandler.ListSuppressions)
+	adminGroup.DELETE("/email_suppressions/:id", rs.emailHandler.DeleteSuppression)
+}
+
+// Tools for local development, only available in dev mode
+func (rs *RouterService) registerDev(r *gin.RouterGroup) {
+	devGroup := r.Group("/dev")
+
+	devGroup.GET("/mailbox", rs.emailHandler.ListMailbox)
+	devGroup.GET("/mailbox/:id", rs.emailHandler.GetMailboxMessage)
+	devGroup.DELETE("/mailbox", rs.emailHandler.ClearMailbox)
+	devGroup.GET("/email_preview", rs.emailHandler.ListTemplates)
+	devGroup.GET("/email_preview/:template", rs.emailHandler.PreviewTemplate)
 }
@@ -0,0 +1,19 @@
+package initserver
+
+import (
+	"github.com/dominiclet/golang-base/service/oauth"
+	"github.com/dominiclet/golang-base/service/session"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/google/wire"
+)
+
+// Services used by CLI commands
+type Services struct {
+	UserService    *user.UserService
+	SessionService *session.SessionService
+	OAuthService   *oauth.OAuthService // Also revokes OAuth tokens when the CLI sets a password (if enabled)
+}
+
+var ServicesSet = wire.NewSet(
+	wire.Struct(new(Services), "*"),
+)
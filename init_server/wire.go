@@ -7,6 +7,7 @@ import (
 	"github.com/dominiclet/golang-base/handler"
 	"github.com/dominiclet/golang-base/init_server/config"
 	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/init_server/migrate"
 	"github.com/dominiclet/golang-base/lib"
 	"github.com/dominiclet/golang-base/middleware"
 	"github.com/dominiclet/golang-base/service"
@@ -27,3 +28,26 @@ func InitDeps() *RouterService {
 	)
 	return &RouterService{}
 }
+
+// Builds services without migrating the DB or serving requests
+func InitServices() *Services {
+	wire.Build(
+		config.InitConfig,
+		OpenGormDB,
+		ServicesSet,
+		env.InitEnvVars,
+
+		service.ServiceSet,
+		lib.LibSet,
+	)
+	return &Services{}
+}
+
+func InitMigrator() *migrate.Migrator {
+	wire.Build(
+		config.InitConfig,
+		OpenGormDB,
+		migrate.InitMigrator,
+	)
+	return &migrate.Migrator{}
+}
@@ -7,13 +7,31 @@
 package initserver
 
 import (
+	billing2 "github.com/dominiclet/golang-base/handler/billing"
+	email2 "github.com/dominiclet/golang-base/handler/email"
+	notification2 "github.com/dominiclet/golang-base/handler/notification"
+	oauth2 "github.com/dominiclet/golang-base/handler/oauth"
 	session2 "github.com/dominiclet/golang-base/handler/session"
+	twofactor2 "github.com/dominiclet/golang-base/handler/twofactor"
 	user2 "github.com/dominiclet/golang-base/handler/user"
 	"github.com/dominiclet/golang-base/init_server/config"
 	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/init_server/migrate"
 	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/encryption"
+	"github.com/dominiclet/golang-base/lib/password"
 	"github.com/dominiclet/golang-base/middleware"
+	"github.com/dominiclet/golang-base/service/audit"
+	"github.com/dominiclet/golang-base/service/billing"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/identity"
+	"github.com/dominiclet/golang-base/service/metering"
+	"github.com/dominiclet/golang-base/service/notification"
+	"github.com/dominiclet/golang-base/service/oauth"
+	"github.com/dominiclet/golang-base/service/outbox"
 	"github.com/dominiclet/golang-base/service/session"
+	"github.com/dominiclet/golang-base/service/suppression"
+	"github.com/dominiclet/golang-base/service/twofactor"
 	"github.com/dominiclet/golang-base/service/user"
 )
 
@@ -23,17 +41,74 @@ func InitDeps() *RouterService {
 	configConfig := config.InitConfig()
 	db := InitGormDB(configConfig)
 	envVars := env.InitEnvVars()
-	emailService := email.InitEmailService(configConfig, envVars)
-	userService := user.InitUserService(db, emailService)
-	sessionService := session.InitSessionService(userService, db)
-	middlewareMiddleware := middleware.InitMiddleware(sessionService)
-	userHandler := user2.InitUserHandler(userService)
-	sessionHandler := session2.InitSessionHandler(sessionService, configConfig, envVars)
+	suppressionService := suppression.InitSuppressionService(db, configConfig)
+	emailService := email.InitEmailService(configConfig, envVars, suppressionService)
+	outboxService := outbox.InitOutboxService(db, emailService, configConfig)
+	policy := password.InitPolicy(configConfig)
+	hasher := password.InitHasher(configConfig)
+	userService := user.InitUserService(db, emailService, outboxService, policy, hasher)
+	encrypter := encryption.InitEncrypter(configConfig)
+	twoFactorService := twofactor.InitTwoFactorService(db, encrypter, configConfig)
+	auditService := audit.InitAuditService(db)
+	sessionService := session.InitSessionService(userService, twoFactorService, emailService, outboxService, auditService, configConfig, db)
+	entitlementService := entitlement.InitEntitlementService(userService)
+	meteringService := metering.InitMeteringService(db, entitlementService)
+	middlewareMiddleware := middleware.InitMiddleware(sessionService, entitlementService, meteringService, configConfig)
+	userHandler := user2.InitUserHandler(userService, entitlementService, meteringService)
+	identityService := identity.InitIdentityService(db, configConfig, envVars, userService, sessionService)
+	sessionHandler := session2.InitSessionHandler(sessionService, identityService, configConfig, envVars)
+	notificationService := notification.InitNotificationService(db, emailService, outboxService, configConfig, envVars)
+	billingService := billing.InitBillingService(db, configConfig, sessionService, notificationService)
+	billingHandler := billing2.InitBillingHandler(billingService)
+	twoFactorHandler := twofactor2.InitTwoFactorHandler(twoFactorService, sessionService)
+	oAuthService := oauth.InitOAuthService(db, configConfig, envVars, userService)
+	oAuthHandler := oauth2.InitOAuthHandler(oAuthService, sessionService, configConfig, envVars)
+	emailHandler := email2.InitEmailHandler(emailService, outboxService, suppressionService)
+	notificationHandler := notification2.InitNotificationHandler(notificationService)
 	injector := &Injector{
-		middleware:     middlewareMiddleware,
-		userHandler:    userHandler,
-		sessionHandler: sessionHandler,
+		middleware:          middlewareMiddleware,
+		envVars:             envVars,
+		config:              configConfig,
+		userHandler:         userHandler,
+		sessionHandler:      sessionHandler,
+		billingHandler:      billingHandler,
+		twoFactorHandler:    twoFactorHandler,
+		oauthHandler:        oAuthHandler,
+		emailHandler:        emailHandler,
+		notificationHandler: notificationHandler,
+		meteringService:     meteringService,
 	}
 	routerService := InitRouterService(injector)
 	return routerService
 }
+
+// Builds services without migrating the DB or serving requests
+func InitServices() *Services {
+	configConfig := config.InitConfig()
+	db := OpenGormDB(configConfig)
+	envVars := env.InitEnvVars()
+	suppressionService := suppression.InitSuppressionService(db, configConfig)
+	emailService := email.InitEmailService(configConfig, envVars, suppressionService)
+	outboxService := outbox.InitOutboxService(db, emailService, configConfig)
+	policy := password.InitPolicy(configConfig)
+	hasher := password.InitHasher(configConfig)
+	userService := user.InitUserService(db, emailService, outboxService, policy, hasher)
+	encrypter := encryption.InitEncrypter(configConfig)
+	twoFactorService := twofactor.InitTwoFactorService(db, encrypter, configConfig)
+	auditService := audit.InitAuditService(db)
+	sessionService := session.InitSessionService(userService, twoFactorService, emailService, outboxService, auditService, configConfig, db)
+	oAuthService := oauth.InitOAuthService(db, configConfig, envVars, userService)
+	services := &Services{
+		UserService:    userService,
+		SessionService: sessionService,
+		OAuthService:   oAuthService,
+	}
+	return services
+}
+
+func InitMigrator() *migrate.Migrator {
+	configConfig := config.InitConfig()
+	db := OpenGormDB(configConfig)
+	migrator := migrate.InitMigrator(db, configConfig)
+	return migrator
+}
@@ -0,0 +1,19 @@
+package ctxwrapper
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDKey = "request_id"
+
+func SetRequestID(c *gin.Context, requestID string) {
+	c.Set(requestIDKey, requestID)
+}
+
+// Gets ID of request from context. Returns empty string if request has no ID
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
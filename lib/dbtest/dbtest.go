@@ -0,0 +1,32 @@
+// Package dbtest provides in-memory SQLite databases for tests
+package dbtest
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Opens an empty in-memory database with tables of models. The database is only shared by
+// connections of the same test, and is dropped when the test ends
+func Open(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to open test DB: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("Failed to open test DB: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,106 @@
+package email
+
+import (
+	"strings"
+	"sync"
+)
+
+// CapturedMessage is a message kept by CaptureMailer
+type CapturedMessage struct {
+	ID int // Sequence no. of message, starting from 1
+	*OutgoingMessage
+}
+
+// CaptureMailer keeps sent messages in memory, to be inspected by tests or viewed in dev mode
+type CaptureMailer struct {
+	mu       sync.Mutex
+	messages []CapturedMessage
+	nextID   int
+	limit    int
+}
+
+// Create mailer that keeps the last limit messages (all messages if limit is 0)
+func NewCaptureMailer(limit int) *CaptureMailer {
+	return &CaptureMailer{limit: limit, nextID: 1}
+}
+
+func (c *CaptureMailer) Send(msg *OutgoingMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, CapturedMessage{ID: c.nextID, OutgoingMessage: msg})
+	c.nextID++
+	if c.limit > 0 && len(c.messages) > c.limit {
+		c.messages = c.messages[len(c.messages)-c.limit:]
+	}
+	return nil
+}
+
+// Captured messages, oldest first
+func (c *CaptureMailer) Messages() []CapturedMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CapturedMessage(nil), c.messages...)
+}
+
+// Get message by ID. Returns false if the message does not exist (anymore)
+func (c *CaptureMailer) Get(id int) (CapturedMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, msg := range c.messages {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+	return CapturedMessage{}, false
+}
+
+// Messages sent to recipient (case-insensitive), oldest first
+func (c *CaptureMailer) SentTo(to string) []CapturedMessage {
+	var sent []CapturedMessage
+	for _, msg := range c.Messages() {
+		if strings.EqualFold(msg.To, to) {
+			sent = append(sent, msg)
+		}
+	}
+	return sent
+}
+
+// Discard all captured messages
+func (c *CaptureMailer) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = nil
+}
+
+// TestingT is the subset of testing.TB used by the assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Assert that a message was sent to recipient, returning the last one
+func (c *CaptureMailer) AssertSentTo(t TestingT, to string) *CapturedMessage {
+	t.Helper()
+	sent := c.SentTo(to)
+	if len(sent) == 0 {
+		t.Errorf("Expected email to %s, but none was sent", to)
+		return nil
+	}
+	return &sent[len(sent)-1]
+}
+
+// Assert that no message was sent to recipient
+func (c *CaptureMailer) AssertNotSentTo(t TestingT, to string) {
+	t.Helper()
+	if sent := c.SentTo(to); len(sent) > 0 {
+		t.Errorf("Expected no email to %s, got %d", to, len(sent))
+	}
+}
+
+// Assert the total no. of captured messages
+func (c *CaptureMailer) AssertCount(t TestingT, count int) {
+	t.Helper()
+	if n := len(c.Messages()); n != count {
+		t.Errorf("Expected %d emails to be sent, got %d", count, n)
+	}
+}
@@ -0,0 +1,166 @@
+package email
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+const (
+	testDKIMDomain   = "example.com"
+	testDKIMSelector = "mail"
+)
+
+const testDKIMMessage = "From: App <noreply@example.com>\r\n" +
+	"To: user@example.org\r\n" +
+	"Subject: Reset your password\r\n" +
+	"Date: Mon, 19 Oct 2026 12:00:00 +0000\r\n" +
+	"Mime-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Your code is 123456.\r\n"
+
+func TestDKIMSignerSignsVerifiableMessages(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPKCS8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPKCS8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		keyPEM    *pem.Block
+		algorithm string
+		record    string // DNS TXT record with public key
+	}{
+		{
+			name:      "RSA PKCS #1",
+			keyPEM:    &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)},
+			algorithm: "rsa-sha256",
+			record:    "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPublic),
+		},
+		{
+			name:      "RSA PKCS #8",
+			keyPEM:    &pem.Block{Type: "PRIVATE KEY", Bytes: rsaPKCS8},
+			algorithm: "rsa-sha256",
+			record:    "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPublic),
+		},
+		{
+			name:      "Ed25519",
+			keyPEM:    &pem.Block{Type: "PRIVATE KEY", Bytes: edPKCS8},
+			algorithm: "ed25519-sha256",
+			record:    "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(edPublic),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewDKIMSigner(dkimConfig(t, pem.EncodeToMemory(tt.keyPEM)))
+			if err != nil {
+				t.Fatalf("NewDKIMSigner failed: %v", err)
+			}
+			signed, err := signer.Sign([]byte(testDKIMMessage))
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			if !bytes.HasPrefix(signed, []byte("DKIM-Signature:")) {
+				t.Fatal("Signed message does not start with DKIM-Signature header")
+			}
+			if header := string(signed[:bytes.Index(signed, []byte("\r\n\r\n"))]); !strings.Contains(header, "a="+tt.algorithm) {
+				t.Errorf("Signature does not use %s: %s", tt.algorithm, header)
+			}
+
+			verifications := verifyDKIM(t, signed, tt.record)
+			if len(verifications) != 1 {
+				t.Fatalf("%d signatures found, want 1", len(verifications))
+			}
+			if err := verifications[0].Err; err != nil {
+				t.Errorf("Signature is invalid: %v", err)
+			}
+			if verifications[0].Domain != testDKIMDomain {
+				t.Errorf("Signature is of domain %s, want %s", verifications[0].Domain, testDKIMDomain)
+			}
+
+			// Headers are signed, so that they cannot be changed
+			tampered := bytes.Replace(signed, []byte("Subject: Reset your password"), []byte("Subject: Urgent"), 1)
+			if verifications := verifyDKIM(t, tampered, tt.record); verifications[0].Err == nil {
+				t.Error("Signature of modified message is valid")
+			}
+		})
+	}
+}
+
+func TestNewDKIMSignerRejectsInvalidKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, keyPEM := range map[string][]byte{
+		"not PEM": []byte("not a key"),
+		"ECDSA":   pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8}),
+	} {
+		if _, err := NewDKIMSigner(dkimConfig(t, keyPEM)); err == nil {
+			t.Errorf("%s key was accepted", name)
+		}
+	}
+}
+
+// Writes key to a temporary file, returning DKIM config using it
+func dkimConfig(t *testing.T, keyPEM []byte) config.DKIM {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dkim.pem")
+	if err := os.WriteFile(path, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return config.DKIM{Domain: testDKIMDomain, Selector: testDKIMSelector, PrivateKeyPath: path}
+}
+
+// Verifies signatures of message, looking up record instead of the DNS
+func verifyDKIM(t *testing.T, message []byte, record string) []*dkim.Verification {
+	t.Helper()
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(message), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			if domain != testDKIMSelector+"._domainkey."+testDKIMDomain {
+				return nil, fmt.Errorf("unexpected lookup of %s", domain)
+			}
+			return []string{record}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	return verifications
+}
@@ -0,0 +1,121 @@
+package email
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// DSN actions (RFC 3464 section 2.3.3)
+const (
+	DSNActionFailed    = "failed"
+	DSNActionDelayed   = "delayed"
+	DSNActionDelivered = "delivered"
+	DSNActionRelayed   = "relayed"
+	DSNActionExpanded  = "expanded"
+)
+
+var ErrNotDSN = errors.New("Message is not a delivery status notification")
+
+// DSNRecipient is the delivery status of a recipient reported in a delivery status notification
+type DSNRecipient struct {
+	Recipient  string
+	Action     string
+	Status     string // Enhanced status code (RFC 3463), eg. 5.1.1
+	Diagnostic string
+}
+
+// Whether delivery failed permanently (as opposed to a temporary failure or successful delivery)
+func (r DSNRecipient) Permanent() bool {
+	return r.Action == DSNActionFailed && strings.HasPrefix(r.Status, "5")
+}
+
+// Parse delivery status notification (RFC 3464), either a complete message or a multipart/report body
+// with the given content type
+func ParseDSN(contentType string, body io.Reader) ([]DSNRecipient, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType == "message/rfc822" {
+		msg, err := mail.ReadMessage(body)
+		if err != nil {
+			return nil, err
+		}
+		mediaType, params, err = mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		if err != nil {
+			return nil, err
+		}
+		body = msg.Body
+	}
+	if mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, ErrNotDSN
+	}
+
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, ErrNotDSN
+		}
+		if err != nil {
+			return nil, err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType == "message/delivery-status" || partType == "message/global-delivery-status" {
+			return parseDeliveryStatus(part)
+		}
+	}
+}
+
+// Delivery status consists of a group of per-message fields followed by a group of fields for each recipient,
+// separated by blank lines
+func parseDeliveryStatus(r io.Reader) ([]DSNRecipient, error) {
+	reader := textproto.NewReader(bufio.NewReader(r))
+	if _, err := reader.ReadMIMEHeader(); err != nil {
+		if err == io.EOF {
+			return nil, ErrNotDSN
+		}
+		return nil, err
+	}
+
+	var recipients []DSNRecipient
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 {
+			recipient := fields.Get("Final-Recipient")
+			if recipient == "" {
+				recipient = fields.Get("Original-Recipient")
+			}
+			recipients = append(recipients, DSNRecipient{
+				Recipient:  typedValue(recipient),
+				Action:     strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+				Status:     strings.TrimSpace(fields.Get("Status")),
+				Diagnostic: typedValue(fields.Get("Diagnostic-Code")),
+			})
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, ErrNotDSN
+	}
+	return recipients, nil
+}
+
+// Strips type from typed fields (eg. "rfc822; user@example.com" or "smtp; 550 User unknown")
+func typedValue(field string) string {
+	if _, value, ok := strings.Cut(field, ";"); ok {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(field)
+}
@@ -1,8 +1,13 @@
 package email
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"net/mail"
 	"net/url"
+	"sort"
+	"time"
 
 	"github.com/dominiclet/golang-base/init_server/config"
 	"github.com/dominiclet/golang-base/init_server/env"
@@ -12,69 +17,254 @@ import (
 )
 
 const (
-	emailDisplayName = "Fund Analysis"
+	mailboxSize = 100 // No. of sent messages kept for the dev mailbox
 )
 
+// Built-in email templates
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplateResetPassword = "reset_password"
+	TemplateMagicLink     = "magic_link"
+	TemplateAccountUnlock = "account_unlock"
+	TemplatePaymentFailed = "payment_failed"
+)
+
+// Example unsubscribe link shown in previews of templates that are sent as notifications
+const previewUnsubscribeURL = "https://example.com/api/notifications/unsubscribe?user=uuid&category=billing&sig=sig"
+
+// Example data of each template for previews
+var previewData = map[string]map[string]string{
+	TemplateVerifyEmail:   {"Link": "https://example.com/api/user/verify/uuid/token"},
+	TemplateResetPassword: {"Code": "A1B2C3", "Link": "https://example.com/reset?email=user%40example.com&token=token"},
+	TemplateMagicLink:     {"Link": "https://example.com/api/session/magic_link/consume?token=token"},
+	TemplateAccountUnlock: {"Link": "https://example.com/api/session/unlock?token=token"},
+	TemplatePaymentFailed: {"LicenseExpiry": "2 January 2006"},
+}
+
+// Templates that are sent as notifications users can unsubscribe from
+var notificationTemplates = map[string]bool{
+	TemplatePaymentFailed: true,
+}
+
+// Returned when sending to an address on the suppression list
+var ErrSuppressed = errors.New("Recipient is on suppression list")
+
+// SuppressionList tells whether an address must not be emailed (eg. because mail to it bounced)
+type SuppressionList interface {
+	IsSuppressed(address string) (bool, error)
+}
+
 type EmailService struct {
-	config *config.Config
-	env    *env.EnvVars
-	dialer *gomail.Dialer
-	logger *logrus.Entry
-	from   string
+	config       *config.Config
+	env          *env.EnvVars
+	mailer       Mailer
+	mailbox      *CaptureMailer // Only set in dev mode
+	renderer     *Renderer
+	signer       *DKIMSigner // Only set if DKIM is configured
+	suppressions SuppressionList
+	logger       *logrus.Entry
+	from         string
 }
 
-func InitEmailService(config *config.Config, env *env.EnvVars) *EmailService {
-	return &EmailService{
+func InitEmailService(config *config.Config, env *env.EnvVars, suppressions SuppressionList) *EmailService {
+	mailer, err := NewMailer(config.Email)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize email transport: %v", err))
+	}
+	displayName := config.Email.DisplayName
+	if displayName == "" {
+		displayName = config.Domain
+	}
+	from := mail.Address{Name: displayName, Address: config.Email.EmailAddress}
+	e := &EmailService{
 		config: config,
-		dialer: gomail.NewDialer(config.Email.ServerAddress, config.Email.ServerPort,
-			config.Email.EmailAddress, config.Email.AppPassword),
-		logger: logger.GetLogger().WithField("module", "email_service"),
-		from:   fmt.Sprintf("%s <%s>", emailDisplayName, config.Email.EmailAddress),
-		env:    env,
+		mailer: mailer,
+		// Templates are reloaded on every use in dev mode, so that changes can be previewed right away
+		renderer:     NewRenderer(config.Email.TemplateDir, displayName, !env.IsDev()),
+		logger:       logger.GetLogger().WithField("module", "email_service"),
+		from:         from.String(),
+		env:          env,
+		suppressions: suppressions,
+	}
+	if config.Email.DKIM.PrivateKeyPath != "" {
+		e.signer, err = NewDKIMSigner(config.Email.DKIM)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load DKIM private key: %v", err))
+		}
+	}
+	// Keep sent messages in dev mode, so that they can be viewed in the dev mailbox
+	if env.IsDev() {
+		if capture, ok := mailer.(*CaptureMailer); ok {
+			e.mailbox = capture
+		} else {
+			e.mailbox = NewCaptureMailer(mailboxSize)
+			e.mailer = &teeMailer{mailer: mailer, capture: e.mailbox}
+		}
 	}
+	return e
 }
 
-func (e *EmailService) SendResetPasswordToken(to string, token string) error {
+// Messages sent in dev mode. Returns nil if not in dev mode
+func (e *EmailService) Mailbox() *CaptureMailer {
+	return e.mailbox
+}
+
+// Message is a composed email that is ready to be sent
+type Message struct {
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+	Headers  map[string]string // Additional headers, eg. List-Unsubscribe
+}
+
+// Whether reset password emails contain a single-click reset link
+func (e *EmailService) ResetPasswordLinkEnabled() bool {
+	return e.config.PasswordReset.LinkURL != ""
+}
+
+// Send message with the configured transport. Messages with both a text and an HTML body are sent
+// as multipart/alternative. Returns ErrSuppressed without sending if the recipient is on the suppression list
+func (e *EmailService) Send(msg Message) error {
+	suppressed, err := e.suppressions.IsSuppressed(msg.To)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		e.logger.WithField("to", msg.To).Warn("Not sending email to suppressed address")
+		return ErrSuppressed
+	}
+
 	e.logger.WithFields(logrus.Fields{
-		"to":    to,
-		"token": token,
-	}).Info("Sending reset password email")
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}).Info("Sending email")
 	m := gomail.NewMessage()
 	m.SetHeader("From", e.from)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", "Reset Password")
-
-	content := fmt.Sprintf("Your reset token is <b>%s</b>", token)
-
-	m.SetBody("text/html", content)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	for name, value := range msg.Headers {
+		m.SetHeader(name, value)
+	}
+	switch {
+	case msg.TextBody != "" && msg.HTMLBody != "":
+		m.SetBody("text/plain", msg.TextBody)
+		m.AddAlternative("text/html", msg.HTMLBody)
+	case msg.TextBody != "":
+		m.SetBody("text/plain", msg.TextBody)
+	default:
+		m.SetBody("text/html", msg.HTMLBody)
+	}
 
-	if err := e.dialer.DialAndSend(m); err != nil {
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return err
+	}
+	raw := buf.Bytes()
+	if e.signer != nil {
+		signed, err := e.signer.Sign(raw)
+		if err != nil {
+			e.logger.WithField("err", err).Error("Failed to sign email")
+			return err
+		}
+		raw = signed
+	}
+	outgoing := &OutgoingMessage{
+		Message: msg,
+		From:    e.from,
+		Raw:     raw,
+		SentAt:  time.Now(),
+	}
+	if err := e.mailer.Send(outgoing); err != nil {
 		e.logger.WithField("err", err).Error("Error occurred when sending email")
 		return err
 	}
 	return nil
 }
 
-func (e *EmailService) SendVerificationEmail(to string, userUUID string, verificationToken string) error {
-	e.logger.WithFields(logrus.Fields{
-		"to":                to,
-		"verificationToken": verificationToken,
-	}).Info("Sending verification email")
-	m := gomail.NewMessage()
-	m.SetHeader("From", e.from)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", "Verify Email")
+// Reset password email with reset code, and reset link if linkToken is set
+func (e *EmailService) ResetPasswordEmail(to string, locale string, code string, linkToken string) (Message, error) {
+	data := map[string]string{"Code": code}
+	if linkToken != "" {
+		query := url.Values{}
+		query.Set("email", to)
+		query.Set("token", linkToken)
+		data["Link"] = e.config.PasswordReset.LinkURL + "?" + query.Encode()
+	}
+	return e.render(to, locale, TemplateResetPassword, data, "")
+}
 
+func (e *EmailService) VerificationEmail(to string, locale string, userUUID string, verificationToken string) (Message, error) {
 	protocol := e.env.GetHttpProtocol()
 	escapedUUID := url.QueryEscape(userUUID)
 	verificationLink := fmt.Sprintf("%s://%s/api/user/verify/%s/%s",
 		protocol, e.config.Domain, escapedUUID, verificationToken)
-	content := fmt.Sprintf(`Please click <a href="%s">here</a> to verify your email`, verificationLink)
+	return e.render(to, locale, TemplateVerifyEmail, map[string]string{"Link": verificationLink}, "")
+}
 
-	m.SetBody("text/html", content)
-	if err := e.dialer.DialAndSend(m); err != nil {
-		e.logger.WithField("err", err).Error("Error occurred when sending email")
-		return err
+func (e *EmailService) MagicLinkEmail(to string, locale string, token string) (Message, error) {
+	protocol := e.env.GetHttpProtocol()
+	magicLink := fmt.Sprintf("%s://%s/api/session/magic_link/consume?token=%s",
+		protocol, e.config.Domain, url.QueryEscape(token))
+	return e.render(to, locale, TemplateMagicLink, map[string]string{"Link": magicLink}, "")
+}
+
+func (e *EmailService) AccountUnlockEmail(to string, locale string, token string) (Message, error) {
+	protocol := e.env.GetHttpProtocol()
+	unlockLink := fmt.Sprintf("%s://%s/api/session/unlock?token=%s",
+		protocol, e.config.Domain, url.QueryEscape(token))
+	return e.render(to, locale, TemplateAccountUnlock, map[string]string{"Link": unlockLink}, "")
+}
+
+// Notification email rendered from template with name. If unsubscribeURL is set, the email links to it
+// and has List-Unsubscribe headers for one-click unsubscribe (RFC 8058)
+func (e *EmailService) NotificationEmail(to string, locale string, name string, data interface{},
+	unsubscribeURL string) (Message, error) {
+	msg, err := e.render(to, locale, name, data, unsubscribeURL)
+	if err != nil {
+		return Message{}, err
 	}
-	return nil
+	if unsubscribeURL != "" {
+		msg.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+	return msg, nil
+}
+
+// Render template with example data
+func (e *EmailService) PreviewEmail(name string, locale string) (Message, error) {
+	data, ok := previewData[name]
+	if !ok {
+		return Message{}, ErrTemplateNotFound
+	}
+	if notificationTemplates[name] {
+		return e.NotificationEmail("user@example.com", locale, name, data, previewUnsubscribeURL)
+	}
+	return e.render("user@example.com", locale, name, data, "")
+}
+
+// Names of templates that can be previewed
+func (e *EmailService) TemplateNames() []string {
+	names := make([]string, 0, len(previewData))
+	for name := range previewData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e *EmailService) render(to string, locale string, name string, data interface{},
+	unsubscribeURL string) (Message, error) {
+	subject, text, html, err := e.renderer.Render(name, locale, data, unsubscribeURL)
+	if err != nil {
+		e.logger.WithFields(logrus.Fields{
+			"template": name,
+			"locale":   locale,
+			"err":      err,
+		}).Error("Failed to render email template")
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject, TextBody: text, HTMLBody: html}, nil
 }
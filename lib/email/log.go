@@ -0,0 +1,24 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LogMailer prints messages instead of delivering them
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogMailer(w io.Writer) *LogMailer {
+	return &LogMailer{w: w}
+}
+
+func (l *LogMailer) Send(msg *OutgoingMessage) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := fmt.Fprintf(l.w, "----- Email to %s -----\n%s\n----- End of email -----\n", msg.To, msg.Raw)
+	return err
+}
@@ -0,0 +1,44 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// MaildirMailer writes messages to a maildir (https://cr.yp.to/proto/maildir.html),
+// which can be opened with most mail clients
+type MaildirMailer struct {
+	dir      string
+	hostname string
+	counter  atomic.Uint64
+}
+
+// Create mailer writing to dir, creating the maildir if it does not exist
+func NewMaildirMailer(dir string) (*MaildirMailer, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("Maildir not set")
+	}
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	return &MaildirMailer{dir: dir, hostname: hostname}, nil
+}
+
+// Messages are written to tmp first, and then moved to new, so that readers never see partial messages
+func (m *MaildirMailer) Send(msg *OutgoingMessage) error {
+	name := fmt.Sprintf("%d.%d_%d.%s", time.Now().Unix(), os.Getpid(), m.counter.Add(1), m.hostname)
+	tmpPath := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, msg.Raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(m.dir, "new", name))
+}
@@ -0,0 +1,58 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+)
+
+// Transports that can be selected with email.transport
+const (
+	TransportSMTP   = "smtp"   // Deliver through SMTP server (default)
+	TransportFile   = "file"   // Write messages to a maildir
+	TransportLog    = "log"    // Print messages to stdout
+	TransportMemory = "memory" // Keep messages in memory
+)
+
+// Mailer delivers rendered messages
+type Mailer interface {
+	Send(msg *OutgoingMessage) error
+}
+
+// OutgoingMessage is a message together with its sender and its rendering in MIME format
+type OutgoingMessage struct {
+	Message
+	From   string // Value of From header
+	Raw    []byte
+	SentAt time.Time
+}
+
+// Create mailer for the configured transport
+func NewMailer(conf config.Email) (Mailer, error) {
+	switch conf.Transport {
+	case "", TransportSMTP:
+		return NewSMTPMailer(conf.ServerAddress, conf.ServerPort, conf.EmailAddress, conf.AppPassword), nil
+	case TransportFile:
+		return NewMaildirMailer(conf.MailDir)
+	case TransportLog:
+		return NewLogMailer(os.Stdout), nil
+	case TransportMemory:
+		return NewCaptureMailer(0), nil
+	}
+	return nil, fmt.Errorf("Unknown email transport %q", conf.Transport)
+}
+
+// teeMailer delivers messages with mailer, and also keeps a copy of delivered messages in capture
+type teeMailer struct {
+	mailer  Mailer
+	capture *CaptureMailer
+}
+
+func (t *teeMailer) Send(msg *OutgoingMessage) error {
+	if err := t.mailer.Send(msg); err != nil {
+		return err
+	}
+	return t.capture.Send(msg)
+}
@@ -0,0 +1,32 @@
+package email
+
+import (
+	"bytes"
+	"net/mail"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPMailer delivers messages through an SMTP server, opening a new connection for each message
+type SMTPMailer struct {
+	dialer *gomail.Dialer
+}
+
+func NewSMTPMailer(host string, port int, username string, password string) *SMTPMailer {
+	return &SMTPMailer{
+		dialer: gomail.NewDialer(host, port, username, password),
+	}
+}
+
+func (s *SMTPMailer) Send(msg *OutgoingMessage) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+	conn, err := s.dialer.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Send(from.Address, []string{msg.To}, bytes.NewReader(msg.Raw))
+}
@@ -0,0 +1,191 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/dominiclet/golang-base/lib/i18n"
+)
+
+// Built-in templates. Each email has a text (.txt) and an HTML (.html) template defining "content",
+// and the text template also defines "subject". They are rendered within the layout of the same format,
+// which includes the footer.
+// Templates for other locales are in subdirectories named after the locale (eg. de or pt-br).
+// Texts shared by templates (eg. of the footer) are in the message catalogs of package i18n instead
+//
+//go:embed templates
+var defaultTemplates embed.FS
+
+// Locale of the templates at the top level of the template directory
+const defaultLocale = "en"
+
+var ErrTemplateNotFound = errors.New("Email template not found")
+
+// Data passed to templates. Template-specific data is in Data
+type templateData struct {
+	AppName string
+	Locale  string
+	Subject string // Only available to HTML templates
+	Data    interface{}
+	// Link to unsubscribe from the category of the email. Not set for emails that cannot be unsubscribed from
+	UnsubscribeURL string
+}
+
+type parsedTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Renderer renders emails from templates. Templates in dir (if set) take precedence over the built-in ones,
+// so that single templates can be overridden. For each template, the most specific locale is used,
+// eg. for locale pt-BR the pt-br, pt and default templates are tried in turn
+type Renderer struct {
+	dir     string
+	appName string
+	cache   bool // Whether parsed templates are kept. Disable to see changes of templates without restarting
+
+	mu        sync.Mutex
+	templates map[string]*parsedTemplate
+}
+
+func NewRenderer(dir string, appName string, cache bool) *Renderer {
+	return &Renderer{
+		dir:       dir,
+		appName:   appName,
+		cache:     cache,
+		templates: make(map[string]*parsedTemplate),
+	}
+}
+
+// Render template with name in locale, returning the subject and the text and HTML bodies
+func (r *Renderer) Render(name string, locale string, data interface{}, unsubscribeURL string) (string, string, string, error) {
+	locales := localeChain(locale)
+	tmpl, err := r.get(name, locales)
+	if err != nil {
+		return "", "", "", err
+	}
+	tmplData := templateData{
+		AppName:        r.appName,
+		Locale:         locales[0],
+		Data:           data,
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", tmplData); err != nil {
+		return "", "", "", err
+	}
+	tmplData.Subject = strings.TrimSpace(subject.String())
+	if err := tmpl.text.ExecuteTemplate(&text, "layout", tmplData); err != nil {
+		return "", "", "", err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", tmplData); err != nil {
+		return "", "", "", err
+	}
+	return tmplData.Subject, strings.TrimSpace(text.String()), html.String(), nil
+}
+
+func (r *Renderer) get(name string, locales []string) (*parsedTemplate, error) {
+	key := name + "/" + locales[0]
+	if r.cache {
+		r.mu.Lock()
+		tmpl, ok := r.templates[key]
+		r.mu.Unlock()
+		if ok {
+			return tmpl, nil
+		}
+	}
+
+	tmpl, err := r.parse(name, locales)
+	if err != nil {
+		return nil, err
+	}
+	if r.cache {
+		r.mu.Lock()
+		r.templates[key] = tmpl
+		r.mu.Unlock()
+	}
+	return tmpl, nil
+}
+
+func (r *Renderer) parse(name string, locales []string) (*parsedTemplate, error) {
+	// Templates can use messages of the catalog in their language with t (eg. {{t "email.footer.unsubscribe"}})
+	lang := i18n.Negotiate("", locales[0])
+	funcs := map[string]interface{}{
+		"t": func(key string, args ...interface{}) string {
+			return i18n.Translate(lang, key, args...)
+		},
+	}
+	text := texttemplate.New(name).Funcs(funcs)
+	for _, file := range []string{"layout.txt", "footer.txt", name + ".txt"} {
+		content, err := r.read(file, locales)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := text.Parse(content); err != nil {
+			return nil, err
+		}
+	}
+	html := htmltemplate.New(name).Funcs(funcs)
+	for _, file := range []string{"layout.html", "footer.html", name + ".html"} {
+		content, err := r.read(file, locales)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := html.Parse(content); err != nil {
+			return nil, err
+		}
+	}
+	return &parsedTemplate{text: text, html: html}, nil
+}
+
+// Read most specific variant of template file
+func (r *Renderer) read(file string, locales []string) (string, error) {
+	for _, locale := range append(locales, "") {
+		if r.dir != "" {
+			content, err := os.ReadFile(filepath.Join(r.dir, locale, file))
+			if err == nil {
+				return string(content), nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+		content, err := defaultTemplates.ReadFile(path.Join("templates", locale, file))
+		if err == nil {
+			return string(content), nil
+		}
+	}
+	return "", ErrTemplateNotFound
+}
+
+// Locales to try for locale, from most to least specific (eg. pt-br and pt for pt_BR).
+// The default locale is always last
+func localeChain(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	// Locales are used in paths, so only letters, digits and hyphens are allowed
+	for _, c := range locale {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return []string{defaultLocale}
+		}
+	}
+	var chain []string
+	for locale != "" && locale != defaultLocale {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return append(chain, defaultLocale)
+}
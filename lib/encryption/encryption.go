@@ -0,0 +1,74 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+)
+
+const keySize = 32 // AES-256
+
+var ErrNoKey = errors.New("Encryption key not configured")
+
+// Encrypter encrypts secrets that are stored at rest with AES-GCM using the key from config
+type Encrypter struct {
+	aead cipher.AEAD // nil if no key is configured
+}
+
+func InitEncrypter(config *config.Config) *Encrypter {
+	if config.Security.EncryptionKey == "" {
+		return &Encrypter{}
+	}
+	key, err := base64.StdEncoding.DecodeString(config.Security.EncryptionKey)
+	if err != nil || len(key) != keySize {
+		panic(fmt.Sprintf("security.encryption_key must be a base64-encoded %d-byte key", keySize))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &Encrypter{aead: aead}
+}
+
+// Whether a key is configured. Encrypt and Decrypt return ErrNoKey otherwise
+func (e *Encrypter) HasKey() bool {
+	return e.aead != nil
+}
+
+// Encrypt plaintext, returning base64-encoded nonce and ciphertext
+func (e *Encrypter) Encrypt(plaintext []byte) (string, error) {
+	if e.aead == nil {
+		return "", ErrNoKey
+	}
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := e.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt value returned by Encrypt
+func (e *Encrypter) Decrypt(encrypted string) ([]byte, error) {
+	if e.aead == nil {
+		return nil, ErrNoKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < e.aead.NonceSize() {
+		return nil, errors.New("Encrypted value too short")
+	}
+	nonce, ciphertext := sealed[:e.aead.NonceSize()], sealed[e.aead.NonceSize():]
+	return e.aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,39 @@
+package httpresp
+
+import (
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
+	"github.com/dominiclet/golang-base/lib/i18n"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// Language of messages in the response to request, ie. the language of the user if logged in and
+// set, otherwise negotiated from the Accept-Language header
+func RequestLanguage(c *gin.Context) language.Tag {
+	userLanguage := ""
+	if user, err := ctxwrapper.GetUser(c); err == nil {
+		userLanguage = user.Language
+	}
+	return NegotiateLanguage(c, userLanguage)
+}
+
+// Language of userLanguage if set, otherwise negotiated from the Accept-Language header of request
+func NegotiateLanguage(c *gin.Context, userLanguage string) language.Tag {
+	if userLanguage != "" {
+		return i18n.Negotiate("", userLanguage)
+	}
+	return i18n.Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// Translate message of error code. Messages other than the message of the code (eg. of SendErrorMsg) are kept
+func localizeErrorMessage(tag language.Tag, code resperror.Code, message string) string {
+	registered, ok := resperror.ErrorMapping[code]
+	if !ok || registered.Message != message {
+		return message
+	}
+	if translated, ok := i18n.Lookup(tag, i18n.ErrorKey(int(code))); ok {
+		return translated
+	}
+	return message
+}
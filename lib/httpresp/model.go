@@ -1,5 +1,7 @@
 package httpresp
 
+import "github.com/dominiclet/golang-base/lib/resperror"
+
 type StandardDataResponse struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
@@ -7,6 +9,18 @@ type StandardDataResponse struct {
 }
 
 type StandardResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    resperror.Code `json:"code"`
+	Message string         `json:"message"`
+	Details interface{}    `json:"details,omitempty"` // Only set for some errors
+}
+
+// ProblemDetails is an error response in the RFC 9457 format (application/problem+json)
+type ProblemDetails struct {
+	Type     string         `json:"type"`               // URI identifying the error code
+	Title    string         `json:"title"`              // Summary of the error code
+	Status   int            `json:"status"`             // HTTP status code
+	Detail   string         `json:"detail,omitempty"`   // Explanation of this occurrence, if more specific than title
+	Instance string         `json:"instance,omitempty"` // ID of the request
+	Code     resperror.Code `json:"code"`               // Extension member: error code of the standard response
+	Details  interface{}    `json:"details,omitempty"`  // Extension member: only set for some errors
 }
@@ -0,0 +1,118 @@
+package httpresp
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	// Type of problems without an error code (RFC 9457 section 4.2.1)
+	blankProblemType = "about:blank"
+)
+
+const problemOptionsKey = "problem_options"
+
+// ProblemOptions configures problem details responses of a request
+type ProblemOptions struct {
+	Default     bool   // Send problem details even if the client does not ask for them
+	TypeBaseURI string // Problem types are this URI followed by the error code
+}
+
+// Set problem details options of request (see ProblemOptions)
+func SetProblemOptions(c *gin.Context, opts ProblemOptions) {
+	c.Set(problemOptionsKey, opts)
+}
+
+func getProblemOptions(c *gin.Context) ProblemOptions {
+	opts, _ := c.Value(problemOptionsKey).(ProblemOptions)
+	return opts
+}
+
+// Whether error response should be sent as problem details, ie. problem details are the default
+// or the client accepts application/problem+json
+func wantsProblem(c *gin.Context) bool {
+	if getProblemOptions(c).Default {
+		return true
+	}
+	for _, accept := range c.Request.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err == nil && mediaType == ProblemContentType && acceptable(params) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Whether media range with params is acceptable, ie. its quality value is not 0 (RFC 9110 section 12.4.2)
+func acceptable(params map[string]string) bool {
+	q, ok := params["q"]
+	if !ok {
+		return true
+	}
+	quality, err := strconv.ParseFloat(q, 64)
+	return err == nil && quality > 0
+}
+
+// Send error in the format requested by the client. code is ErrorCode for errors without an error code.
+// Messages of error codes are translated to the language of the request
+func sendError(c *gin.Context, statusCode int, code resperror.Code, message string, details interface{}) {
+	message = localizeErrorMessage(RequestLanguage(c), code, message)
+	if !wantsProblem(c) {
+		c.JSON(statusCode, StandardResponse{
+			Code:    code,
+			Message: message,
+			Details: details,
+		})
+		return
+	}
+	c.Render(statusCode, problemRender{newProblem(c, statusCode, code, message, details)})
+}
+
+func newProblem(c *gin.Context, statusCode int, code resperror.Code, message string, details interface{}) ProblemDetails {
+	problem := ProblemDetails{
+		Type:     blankProblemType,
+		Title:    http.StatusText(statusCode),
+		Status:   statusCode,
+		Instance: ctxwrapper.GetRequestID(c),
+		Code:     code,
+		Details:  details,
+	}
+	if registered, ok := resperror.ErrorMapping[code]; ok {
+		problem.Type = fmt.Sprintf("%s%d", getProblemOptions(c).TypeBaseURI, code)
+		problem.Title = localizeErrorMessage(RequestLanguage(c), code, registered.Message)
+	}
+	if message != problem.Title {
+		problem.Detail = message
+	}
+	return problem
+}
+
+// Renders problem details as JSON with the problem details content type
+type problemRender struct {
+	problem ProblemDetails
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	body, err := json.Marshal(r.problem)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
+}
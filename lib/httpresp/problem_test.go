@@ -0,0 +1,105 @@
+package httpresp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/gin-gonic/gin"
+)
+
+func sendTestError(opts *ProblemOptions, accept ...string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, value := range accept {
+		c.Request.Header.Add("Accept", value)
+	}
+	if opts != nil {
+		SetProblemOptions(c, *opts)
+	}
+	SendError(c, resperror.NewError(resperror.InvalidTokenError))
+	return w
+}
+
+func TestSendErrorNegotiatesProblemDetails(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      []string
+		opts        *ProblemOptions
+		wantProblem bool
+	}{
+		{"No Accept header", nil, nil, false},
+		{"JSON", []string{"application/json"}, nil, false},
+		{"Any type", []string{"*/*"}, nil, false},
+		{"Any application type", []string{"application/*"}, nil, false},
+		{"Problem details", []string{ProblemContentType}, nil, true},
+		{"Problem details with parameters", []string{"application/problem+json; charset=utf-8"}, nil, true},
+		{"Problem details in list", []string{"application/json, application/problem+json;q=0.9"}, nil, true},
+		{"Problem details in second header", []string{"application/json", ProblemContentType}, nil, true},
+		{"Media type is case-insensitive", []string{"Application/Problem+JSON"}, nil, true},
+		{"Quality 1", []string{"application/problem+json;q=1"}, nil, true},
+		{"Low quality", []string{"application/problem+json;q=0.001"}, nil, true},
+		{"Quality 0", []string{"application/problem+json;q=0"}, nil, false},
+		{"Quality 0.0", []string{"application/problem+json;q=0.0"}, nil, false},
+		{"Quality 0.000", []string{"application/json, application/problem+json; q=0.000"}, nil, false},
+		{"Invalid quality", []string{"application/problem+json;q=abc"}, nil, false},
+		{"Negative quality", []string{"application/problem+json;q=-1"}, nil, false},
+		{"Invalid media range", []string{"application/problem+json;;"}, nil, false},
+		{"Default", nil, &ProblemOptions{Default: true}, true},
+		{"Default with JSON", []string{"application/json"}, &ProblemOptions{Default: true}, true},
+		{"Options without default", []string{"application/json"}, &ProblemOptions{TypeBaseURI: "https://example.com/"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendTestError(tt.opts, tt.accept...)
+			if w.Code != resperror.ErrorMapping[resperror.InvalidTokenError].StatusCode {
+				t.Errorf("Got status %d", w.Code)
+			}
+
+			contentType := w.Header().Get("Content-Type")
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Invalid JSON body %q: %v", w.Body.String(), err)
+			}
+			if tt.wantProblem {
+				if contentType != ProblemContentType {
+					t.Errorf("Got content type %q, want %q", contentType, ProblemContentType)
+				}
+				if _, ok := body["title"]; !ok {
+					t.Errorf("Body %v is not problem details", body)
+				}
+			} else {
+				if !strings.HasPrefix(contentType, "application/json") {
+					t.Errorf("Got content type %q, want application/json", contentType)
+				}
+				if _, ok := body["message"]; !ok {
+					t.Errorf("Body %v is not a standard response", body)
+				}
+			}
+		})
+	}
+}
+
+func TestSendErrorProblemDetails(t *testing.T) {
+	w := sendTestError(&ProblemOptions{TypeBaseURI: "https://example.com/errors/"}, ProblemContentType)
+
+	mapping := resperror.ErrorMapping[resperror.InvalidTokenError]
+	var problem ProblemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Invalid problem details %q: %v", w.Body.String(), err)
+	}
+	want := ProblemDetails{
+		Type:   "https://example.com/errors/10202",
+		Title:  mapping.Message,
+		Status: mapping.StatusCode,
+		Code:   resperror.InvalidTokenError,
+	}
+	if problem != want || w.Code != mapping.StatusCode {
+		t.Errorf("Got status %d and problem %+v, want %+v", w.Code, problem, want)
+	}
+}
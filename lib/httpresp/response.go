@@ -1,10 +1,15 @@
 package httpresp
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/dominiclet/golang-base/init_server/logger"
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
 	"github.com/dominiclet/golang-base/lib/resperror"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -21,36 +26,43 @@ func SendData(c *gin.Context, data interface{}, statusCode int) {
 	})
 }
 
-// Send an error JSON response based on custom error (see error package)
+// Send an error JSON response based on custom error (see error package), which may be wrapped in err.
+// Errors are sent as problem details instead if the client asks for them (see ProblemOptions).
+// Errors without code are sent as unknown errors
 func SendError(c *gin.Context, err error) {
-	customErr, ok := err.(resperror.CustomErrWithCode)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, StandardResponse{
-			Code:    resperror.UnknownError,
-			Message: "",
-		})
-		return
-	}
-	c.JSON(customErr.StatusCode, StandardResponse{
-		Code:    customErr.Code,
-		Message: customErr.Error(),
-	})
+	SendErrorWithFallback(c, err, resperror.NewError(resperror.UnknownError))
 }
 
 // If err is not a recognized error, fallback to fallbackErr
 func SendErrorWithFallback(c *gin.Context, err error, fallbackErr resperror.CustomErrWithCode) {
-	customErr, ok := err.(resperror.CustomErrWithCode)
+	customErr, ok := resperror.As(err)
 	if !ok {
-		c.JSON(fallbackErr.StatusCode, StandardResponse{
-			Code:    fallbackErr.Code,
-			Message: fallbackErr.Message,
-		})
+		logError(c, err, fallbackErr.StatusCode)
+		sendError(c, fallbackErr.StatusCode, fallbackErr.Code, fallbackErr.Message, fallbackErr.Details)
 		return
 	}
-	c.JSON(customErr.StatusCode, StandardResponse{
-		Code:    customErr.Code,
-		Message: customErr.Error(),
+	if customErr.Internal != "" || errors.Unwrap(customErr) != nil {
+		logError(c, err, customErr.StatusCode)
+	}
+	if retry, ok := customErr.Details.(resperror.RetryDetails); ok {
+		c.Header("Retry-After", strconv.Itoa(retry.RetryAfter))
+	}
+	sendError(c, customErr.StatusCode, customErr.Code, customErr.Message, customErr.Details)
+}
+
+// Log error that is not fully sent to the client
+func logError(c *gin.Context, err error, statusCode int) {
+	entry := logger.GetLogger().WithFields(logrus.Fields{
+		"module":     "httpresp",
+		"err":        err,
+		"status":     statusCode,
+		"request_id": ctxwrapper.GetRequestID(c),
 	})
+	if statusCode >= http.StatusInternalServerError {
+		entry.Error("Request failed")
+	} else {
+		entry.Info("Request rejected")
+	}
 }
 
 // Avoid use (use SendError instead)
@@ -59,18 +71,12 @@ func SendErrorWithStatusCode(c *gin.Context, err error, statusCode int) {
 	if err != nil {
 		errMsg = err.Error()
 	}
-	c.JSON(statusCode, StandardResponse{
-		Code:    ErrorCode,
-		Message: errMsg,
-	})
+	sendError(c, statusCode, ErrorCode, errMsg, nil)
 }
 
 // Send error message (Avoid use, use SendError instead)
 func SendErrorMsg(c *gin.Context, errMsg string, statusCode int) {
-	c.JSON(statusCode, StandardResponse{
-		Code:    ErrorCode,
-		Message: errMsg,
-	})
+	sendError(c, statusCode, ErrorCode, errMsg, nil)
 }
 
 // Send normal success response
@@ -0,0 +1,123 @@
+package httpresp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/dominiclet/golang-base/lib/i18n"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+)
+
+// FieldError describes a request field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`   // JSON (or query) name of field. Nested fields are separated by dots
+	Rule    string `json:"rule"`    // Validation rule, eg. required or email
+	Message string `json:"message"` // Localized description of the rule
+}
+
+// Rules whose messages contain the rule parameter (eg. the minimum)
+var paramRules = map[string]bool{"oneof": true, "len": true, "min": true, "max": true}
+
+// Makes validation errors name fields by their JSON (or form) name instead of their Go name.
+// Must be called before requests are handled
+func RegisterValidatorFieldNames() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+}
+
+// Send error for request that failed to bind (eg. with ShouldBindJSON). If fields failed validation
+// or have the wrong JSON type, the BadRequest error has the failed fields as details
+func SendBindError(c *gin.Context, err error) {
+	lang := RequestLanguage(c)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		SendError(c, resperror.NewError(resperror.BadRequest).WithDetails([]FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: i18n.Translate(lang, "validation.type", jsonType(typeErr.Type)),
+		}}))
+		return
+	}
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		SendError(c, resperror.NewError(resperror.BadRequest))
+		return
+	}
+	fieldErrs := make([]FieldError, len(validationErrs))
+	for i, fieldErr := range validationErrs {
+		fieldErrs[i] = FieldError{
+			Field:   fieldPath(fieldErr),
+			Rule:    fieldErr.Tag(),
+			Message: validationMessage(lang, fieldErr),
+		}
+	}
+	SendError(c, resperror.NewError(resperror.BadRequest).WithDetails(fieldErrs))
+}
+
+// Namespace of field without the name of the request struct
+func fieldPath(fieldErr validator.FieldError) string {
+	_, path, ok := strings.Cut(fieldErr.Namespace(), ".")
+	if !ok {
+		return fieldErr.Field()
+	}
+	return path
+}
+
+// Message of rule that field failed. Rules without a message use the message with the empty rule
+func validationMessage(tag language.Tag, fieldErr validator.FieldError) string {
+	rule := fieldErr.Tag()
+	if _, ok := i18n.Lookup(i18n.DefaultLanguage, "validation."+rule); !ok {
+		return i18n.Translate(tag, "validation.")
+	}
+	if !paramRules[rule] {
+		return i18n.Translate(tag, "validation."+rule)
+	}
+	param := fieldErr.Param()
+	if rule == "oneof" {
+		param = strings.Join(strings.Fields(param), ", ")
+	}
+	// Length limits of strings are in characters
+	if (rule == "min" || rule == "max") && fieldErr.Kind() == reflect.String {
+		rule += "_string"
+	}
+	return i18n.Translate(tag, "validation."+rule, param)
+}
+
+// Name of JSON type that values of Go type are decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return "object"
+}
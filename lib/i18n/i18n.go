@@ -0,0 +1,130 @@
+// Package i18n translates messages shown to users with the message catalogs in locales/.
+// Each catalog is a JSON object mapping message keys to messages, which are formatted with fmt.
+// Messages missing from a catalog fall back to English
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+// Language of the reference catalog, which every other catalog falls back to
+var DefaultLanguage = language.English
+
+var (
+	catalogs  map[language.Tag]map[string]string
+	languages []language.Tag // Supported languages, DefaultLanguage first
+	matcher   language.Matcher
+)
+
+func init() {
+	var err error
+	catalogs, err = loadCatalogs()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load message catalogs: %v", err))
+	}
+	languages = []language.Tag{DefaultLanguage}
+	for tag := range catalogs {
+		if tag != DefaultLanguage {
+			languages = append(languages, tag)
+		}
+	}
+	sort.Slice(languages[1:], func(i, j int) bool {
+		return languages[i+1].String() < languages[j+1].String()
+	})
+	matcher = language.NewMatcher(languages)
+}
+
+func loadCatalogs() (map[language.Tag]map[string]string, error) {
+	entries, err := catalogFiles.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[language.Tag]map[string]string, len(entries))
+	for _, entry := range entries {
+		tag, err := language.Parse(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		content, err := catalogFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(content, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		loaded[tag] = messages
+	}
+	if _, ok := loaded[DefaultLanguage]; !ok {
+		return nil, fmt.Errorf("catalog of %s missing", DefaultLanguage)
+	}
+	return loaded, nil
+}
+
+// Languages that have a catalog, starting with the default language
+func Languages() []language.Tag {
+	return append([]language.Tag(nil), languages...)
+}
+
+// Keys of the messages in catalog of language, sorted
+func Keys(tag language.Tag) []string {
+	keys := make([]string, 0, len(catalogs[tag]))
+	for key := range catalogs[tag] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Best supported language for an Accept-Language header value and further preferred languages
+// (eg. the stored preference of the user), in order of preference. Invalid tags are ignored
+func Negotiate(acceptLanguage string, preferred ...string) language.Tag {
+	var tags []language.Tag
+	if acceptLanguage != "" {
+		tags, _, _ = language.ParseAcceptLanguage(acceptLanguage)
+	}
+	for _, lang := range preferred {
+		if tag, err := language.Parse(lang); err == nil && lang != "" {
+			tags = append(tags, tag)
+		}
+	}
+	_, i, _ := matcher.Match(tags...)
+	return languages[i]
+}
+
+// Message with key in catalog of language, without falling back to the default language
+func Lookup(tag language.Tag, key string) (string, bool) {
+	message, ok := catalogs[tag][key]
+	return message, ok
+}
+
+// Message with key in language formatted with args. Falls back to the default language,
+// and to the key itself if the default catalog has no such message either
+func Translate(tag language.Tag, key string, args ...interface{}) string {
+	message, ok := Lookup(tag, key)
+	if !ok {
+		message, ok = Lookup(DefaultLanguage, key)
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Key of translated message of error code. English messages of errors are in resperror.ErrorMapping
+func ErrorKey(code int) string {
+	return fmt.Sprintf("error.%d", code)
+}
@@ -0,0 +1,103 @@
+// Package jwt implements the subset of JWS (RFC 7515) and JWK (RFC 7517) needed for OpenID Connect.
+// Only RS256 and ES256 signatures are supported
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+var ErrInvalidSignature = errors.New("Invalid JWT signature")
+
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Token is a parsed but unverified compact JWS
+type Token struct {
+	Header       Header
+	Payload      []byte
+	signature    []byte
+	signingInput string
+}
+
+// Parse compact JWS. The signature must be checked with Verify before the payload is trusted
+func Parse(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Malformed JWT")
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("Malformed JWT header: %w", err)
+	}
+	var header Header
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("Malformed JWT header: %w", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("Malformed JWT payload: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("Malformed JWT signature: %w", err)
+	}
+	return &Token{
+		Header:       header,
+		Payload:      payload,
+		signature:    signature,
+		signingInput: parts[0] + "." + parts[1],
+	}, nil
+}
+
+// Verify signature of token with public key
+func (t *Token) Verify(key crypto.PublicKey) error {
+	digest := sha256.Sum256([]byte(t.signingInput))
+	switch t.Header.Alg {
+	case RS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("Key type does not match JWT algorithm")
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], t.signature); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case ES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("Key type does not match JWT algorithm")
+		}
+		if len(t.signature) != 64 {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(t.signature[:32])
+		s := new(big.Int).SetBytes(t.signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("Unsupported JWT algorithm: %s", t.Header.Alg)
+}
+
+// Unmarshal payload into claims
+func (t *Token) Claims(claims interface{}) error {
+	return json.Unmarshal(t.Payload, claims)
+}
@@ -0,0 +1,142 @@
+// Package ldap authenticates users against an LDAP directory with a simple bind
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+const (
+	UsernamePlaceholder   = "{username}"
+	defaultEmailAttribute = "mail"
+	defaultNameAttribute  = "cn"
+	defaultTimeout        = 10 * time.Second
+)
+
+var ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+
+type Config struct {
+	URL            string // eg. ldaps://ldap.example.com:636
+	BindDNTemplate string // eg. uid={username},ou=people,dc=example,dc=com
+	StartTLS       bool
+	EmailAttribute string // Defaults to mail
+	NameAttribute  string // Defaults to cn
+	Timeout        time.Duration
+	TLSConfig      *tls.Config
+}
+
+// Entry of an authenticated user in the directory
+type Entry struct {
+	DN    string
+	Email string
+	Name  string
+}
+
+type Client struct {
+	config Config
+}
+
+func NewClient(config Config) *Client {
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = defaultEmailAttribute
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = defaultNameAttribute
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Client{config: config}
+}
+
+// Binds as the user with the given password, then reads the user's own entry.
+// Returns ErrInvalidCredentials if the directory rejects the credentials
+func (c *Client) Authenticate(ctx context.Context, username string, password string) (*Entry, error) {
+	// Most directories treat a bind with an empty password as an anonymous bind that always succeeds
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	dn := strings.ReplaceAll(c.config.BindDNTemplate, UsernamePlaceholder, goldap.EscapeDN(username))
+	if err := conn.Bind(dn, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	result, err := conn.Search(goldap.NewSearchRequest(
+		dn, goldap.ScopeBaseObject, goldap.NeverDerefAliases, 1, int(c.config.Timeout.Seconds()), false,
+		"(objectClass=*)", []string{c.config.EmailAttribute, c.config.NameAttribute}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) != 1 {
+		return nil, errors.New("ldap: entry of bound user not found")
+	}
+	entry := result.Entries[0]
+	return &Entry{
+		DN:    entry.DN,
+		Email: entry.GetAttributeValue(c.config.EmailAttribute),
+		Name:  entry.GetAttributeValue(c.config.NameAttribute),
+	}, nil
+}
+
+func (c *Client) dial(ctx context.Context) (*goldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.config.Timeout}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+	opts := []goldap.DialOpt{goldap.DialWithDialer(dialer)}
+	if c.config.TLSConfig != nil {
+		opts = append(opts, goldap.DialWithTLSConfig(c.config.TLSConfig))
+	}
+	conn, err := goldap.DialURL(c.config.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(c.config.Timeout)
+
+	if c.config.StartTLS {
+		tlsConfig, err := c.startTLSConfig()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// TLS config for StartTLS. Server certificate is verified against the host of the URL unless a server name is configured
+func (c *Client) startTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if c.config.TLSConfig != nil {
+		tlsConfig = c.config.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		u, err := url.Parse(c.config.URL)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = u.Hostname()
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,161 @@
+package ldap
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dominiclet/golang-base/lib/ldap/ldaptest"
+)
+
+const testBindDNTemplate = "uid={username},ou=people,dc=example,dc=com"
+
+var testUser = ldaptest.User{
+	DN:       "uid=alice,ou=people,dc=example,dc=com",
+	Password: "secret",
+	Attributes: map[string][]string{
+		"mail": {"alice@example.com"},
+		"cn":   {"Alice"},
+	},
+}
+
+func newTestServer(t *testing.T) *ldaptest.Server {
+	server := ldaptest.NewServer(testUser)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthenticate(t *testing.T) {
+	server := newTestServer(t)
+	client := NewClient(Config{URL: server.URL(), BindDNTemplate: testBindDNTemplate})
+
+	entry, err := client.Authenticate(context.Background(), "alice", "secret")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if entry.DN != testUser.DN || entry.Email != "alice@example.com" || entry.Name != "Alice" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+}
+
+func TestAuthenticateRejectsInvalidCredentials(t *testing.T) {
+	server := newTestServer(t)
+	client := NewClient(Config{URL: server.URL(), BindDNTemplate: testBindDNTemplate})
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		binds    int // No. of binds sent to server
+	}{
+		{"wrong password", "alice", "wrong", 1},
+		{"user not found", "bob", "secret", 1},
+		// Would be an anonymous bind that succeeds
+		{"empty password", "alice", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binds := server.BindCount()
+			_, err := client.Authenticate(context.Background(), tt.username, tt.password)
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Got error %v, want ErrInvalidCredentials", err)
+			}
+			if got := server.BindCount() - binds; got != tt.binds {
+				t.Errorf("%d binds sent, want %d", got, tt.binds)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWithStartTLS(t *testing.T) {
+	server := newTestServer(t)
+	cert, roots := newCertificate(t, "localhost")
+	server.EnableStartTLS(&tls.Config{Certificates: []tls.Certificate{cert}})
+	port := server.URL()[strings.LastIndex(server.URL(), ":"):]
+
+	// Certificate is verified against the host of the URL
+	client := NewClient(Config{
+		URL:            "ldap://localhost" + port,
+		BindDNTemplate: testBindDNTemplate,
+		StartTLS:       true,
+		TLSConfig:      &tls.Config{RootCAs: roots},
+	})
+	if _, err := client.Authenticate(context.Background(), "alice", "secret"); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+
+	client = NewClient(Config{
+		URL:            "ldap://127.0.0.1" + port,
+		BindDNTemplate: testBindDNTemplate,
+		StartTLS:       true,
+		TLSConfig:      &tls.Config{RootCAs: roots},
+	})
+	if _, err := client.Authenticate(context.Background(), "alice", "secret"); err == nil {
+		t.Error("Certificate of another host was accepted")
+	}
+}
+
+func TestStartTLSServerName(t *testing.T) {
+	tests := []struct {
+		url        string
+		serverName string
+	}{
+		{"ldap://dc.example.com", "dc.example.com"},
+		{"ldap://dc.example.com:389", "dc.example.com"},
+		{"ldap://[::1]:389", "::1"},
+	}
+	for _, tt := range tests {
+		tlsConfig, err := NewClient(Config{URL: tt.url}).startTLSConfig()
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if tlsConfig.ServerName != tt.serverName {
+			t.Errorf("%s: server name is %q, want %q", tt.url, tlsConfig.ServerName, tt.serverName)
+		}
+	}
+
+	// Configured server name is kept
+	client := NewClient(Config{URL: "ldap://10.0.0.1", TLSConfig: &tls.Config{ServerName: "dc.example.com"}})
+	tlsConfig, err := client.startTLSConfig()
+	if err != nil || tlsConfig.ServerName != "dc.example.com" {
+		t.Errorf("Got server name %q and error %v, want dc.example.com", tlsConfig.ServerName, err)
+	}
+}
+
+// Generates self-signed certificate for host, returning it with a pool that trusts it
+func newCertificate(t *testing.T, host string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: privateKey}, roots
+}
@@ -0,0 +1,233 @@
+// Package ldaptest provides an in-process fake LDAP server for tests and local development.
+// Only simple bind, base-object search, StartTLS and unbind are supported
+package ldaptest
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LDAP protocol operations and result codes (RFC 4511)
+const (
+	appBindRequest      = 0
+	appBindResponse     = 1
+	appSearchRequest    = 3
+	appSearchResultItem = 4
+	appSearchResultDone = 5
+	appExtendedRequest  = 23
+	appExtendedResponse = 24
+
+	startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+	resultSuccess            = 0
+	resultProtocolError      = 2
+	resultNoSuchObject       = 32
+	resultInvalidCredentials = 49
+	resultInsufficientAccess = 50
+	scopeBaseObject          = 0
+)
+
+// User entry in the fake directory
+type User struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server is a fake LDAP directory listening on a random local port
+type Server struct {
+	listener net.Listener
+	mu       sync.Mutex
+	users    map[string]User // Maps lowercased DNs to users
+	binds    int
+	tls      *tls.Config // StartTLS is rejected if not set
+}
+
+// Start fake server with the given users
+func NewServer(users ...User) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{
+		listener: listener,
+		users:    make(map[string]User),
+	}
+	for _, u := range users {
+		s.users[strings.ToLower(u.DN)] = u
+	}
+	go s.serve()
+	return s
+}
+
+// URL of server, eg. ldap://127.0.0.1:12345
+func (s *Server) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// Accept StartTLS with config, which must have a certificate
+func (s *Server) EnableStartTLS(config *tls.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tls = config
+}
+
+// Number of bind requests received
+func (s *Server) BindCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.binds
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	var boundDN string
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID, ok := packet.Children[0].Value.(int64)
+		if !ok {
+			return
+		}
+		op := packet.Children[1]
+		switch op.Tag {
+		case appBindRequest:
+			var code int
+			boundDN, code = s.bind(op)
+			if err := writeResult(conn, messageID, appBindResponse, code); err != nil {
+				return
+			}
+		case appSearchRequest:
+			if err := s.search(conn, messageID, boundDN, op); err != nil {
+				return
+			}
+		case appExtendedRequest:
+			tlsConfig := s.startTLSConfig(op)
+			code := resultSuccess
+			if tlsConfig == nil {
+				code = resultProtocolError
+			}
+			if err := writeResult(conn, messageID, appExtendedResponse, code); err != nil || tlsConfig == nil {
+				return
+			}
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+		default:
+			// Unbind, or an unsupported operation
+			return
+		}
+	}
+}
+
+// Returns TLS config if op is a StartTLS request that is accepted
+func (s *Server) startTLSConfig(op *ber.Packet) *tls.Config {
+	if len(op.Children) < 1 || op.Children[0].Data.String() != startTLSOID {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tls
+}
+
+// Returns bound DN (empty if bind failed) and result code
+func (s *Server) bind(op *ber.Packet) (string, int) {
+	s.mu.Lock()
+	s.binds++
+	s.mu.Unlock()
+
+	if len(op.Children) < 3 || op.Children[2].Tag != 0 {
+		return "", resultProtocolError
+	}
+	dn, _ := op.Children[1].Value.(string)
+	password := op.Children[2].Data.String()
+
+	s.mu.Lock()
+	u, ok := s.users[strings.ToLower(dn)]
+	s.mu.Unlock()
+	if !ok || password == "" || u.Password != password {
+		return "", resultInvalidCredentials
+	}
+	return u.DN, resultSuccess
+}
+
+// Only supports reading the entry of the bound user
+func (s *Server) search(conn net.Conn, messageID int64, boundDN string, op *ber.Packet) error {
+	if len(op.Children) < 8 {
+		return writeResult(conn, messageID, appSearchResultDone, resultProtocolError)
+	}
+	if boundDN == "" {
+		return writeResult(conn, messageID, appSearchResultDone, resultInsufficientAccess)
+	}
+	baseDN, _ := op.Children[0].Value.(string)
+	scope, _ := op.Children[1].Value.(int64)
+	if scope != scopeBaseObject || !strings.EqualFold(baseDN, boundDN) {
+		return writeResult(conn, messageID, appSearchResultDone, resultNoSuchObject)
+	}
+
+	s.mu.Lock()
+	u := s.users[strings.ToLower(boundDN)]
+	s.mu.Unlock()
+
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, appSearchResultItem, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, u.DN, "Object Name"))
+	attributes := ber.NewSequence("Attributes")
+	for _, attrPacket := range op.Children[7].Children {
+		name, _ := attrPacket.Value.(string)
+		values, ok := u.Attributes[name]
+		if !ok {
+			continue
+		}
+		attribute := ber.NewSequence("Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		valueSet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			valueSet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(valueSet)
+		attributes.AppendChild(attribute)
+	}
+	entry.AppendChild(attributes)
+	if err := writeMessage(conn, messageID, entry); err != nil {
+		return err
+	}
+	return writeResult(conn, messageID, appSearchResultDone, resultSuccess)
+}
+
+func writeResult(conn net.Conn, messageID int64, op ber.Tag, code int) error {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, op, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return writeMessage(conn, messageID, result)
+}
+
+func writeMessage(conn net.Conn, messageID int64, op *ber.Packet) error {
+	message := ber.NewSequence("LDAP Message")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	message.AppendChild(op)
+	_, err := conn.Write(message.Bytes())
+	return err
+}
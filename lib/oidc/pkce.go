@@ -0,0 +1,34 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+const codeVerifierBytes = 32
+
+// Generate random PKCE code verifier (RFC 7636)
+func GenerateCodeVerifier() (string, error) {
+	return randomURLSafeString(codeVerifierBytes)
+}
+
+// Derive S256 code challenge from code verifier
+func CodeChallengeS256(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Generate random URL-safe string with n bytes of entropy (for state and nonce)
+func randomURLSafeString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Generate random state or nonce
+func GenerateRandomValue() (string, error) {
+	return randomURLSafeString(codeVerifierBytes)
+}
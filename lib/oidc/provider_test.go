@@ -0,0 +1,161 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dominiclet/golang-base/lib/jwt"
+	"github.com/dominiclet/golang-base/lib/oidc"
+	"github.com/dominiclet/golang-base/lib/oidc/oidctest"
+)
+
+const (
+	testClientID     = "client"
+	testClientSecret = "secret"
+	testRedirectURL  = "https://app.example.com/callback"
+)
+
+var testUser = oidctest.User{
+	Subject:       "subject-1",
+	Email:         "user@example.com",
+	EmailVerified: true,
+	Name:          "User",
+}
+
+func newTestProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	server := oidctest.NewServer(testClientID, testClientSecret, testUser)
+	t.Cleanup(server.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, nil)
+	return server, provider
+}
+
+// Logs in at stub provider with nonce, returning the ID token
+func login(t *testing.T, server *oidctest.Server, provider *oidc.Provider, nonce string) string {
+	t.Helper()
+	ctx := context.Background()
+	codeVerifier, err := oidc.GenerateCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "state", nonce, oidc.CodeChallengeS256(codeVerifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	redirect, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorization failed: %v", err)
+	}
+	if got := redirect.Query().Get("state"); got != "state" {
+		t.Fatalf("Provider returned state %q, want %q", got, "state")
+	}
+	tokenResp, err := provider.Exchange(ctx, redirect.Query().Get("code"), codeVerifier)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	return tokenResp.IDToken
+}
+
+func TestDiscovery(t *testing.T) {
+	server, provider := newTestProvider(t)
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	if !strings.HasPrefix(authURL, server.URL+"/authorize?") {
+		t.Errorf("Authorization URL %s does not use discovered endpoint", authURL)
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.Metadata{
+			Issuer:                "https://attacker.example.com",
+			AuthorizationEndpoint: "https://attacker.example.com/authorize",
+		})
+	}))
+	defer server.Close()
+	provider := oidc.NewProvider(oidc.Config{Issuer: server.URL, ClientID: testClientID}, nil)
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); err == nil {
+		t.Error("Discovery document with another issuer was accepted")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	server, provider := newTestProvider(t)
+	idToken := login(t, server, provider, "nonce")
+
+	claims, err := provider.VerifyIDToken(context.Background(), idToken, "nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+	if claims.Subject != testUser.Subject || claims.Email != testUser.Email || !bool(claims.EmailVerified) {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidSignature(t *testing.T) {
+	server, provider := newTestProvider(t)
+	idToken := login(t, server, provider, "nonce")
+	token, err := jwt.Parse(idToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(token.Payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	// Claims changed after signing
+	claims["sub"] = "subject-2"
+	payload, _ := json.Marshal(claims)
+	parts := strings.Split(idToken, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+	if _, err := provider.VerifyIDToken(context.Background(), tampered, "nonce"); err == nil {
+		t.Error("ID token with modified claims was accepted")
+	}
+
+	// Signed with another key under the key ID of the provider
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := jwt.SignRS256(token.Header.Kid, otherKey, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.VerifyIDToken(context.Background(), forged, "nonce"); err == nil {
+		t.Error("ID token signed with another key was accepted")
+	}
+}
+
+func TestVerifyIDTokenRejectsNonceMismatch(t *testing.T) {
+	server, provider := newTestProvider(t)
+	idToken := login(t, server, provider, "nonce")
+
+	if _, err := provider.VerifyIDToken(context.Background(), idToken, "other-nonce"); err == nil {
+		t.Error("ID token with another nonce was accepted")
+	}
+}
+
+func TestVerifyIDTokenRejectsOtherClient(t *testing.T) {
+	server, provider := newTestProvider(t)
+	idToken := login(t, server, provider, "nonce")
+	otherClient := oidc.NewProvider(oidc.Config{Issuer: server.URL, ClientID: "other-client"}, nil)
+
+	if _, err := otherClient.VerifyIDToken(context.Background(), idToken, "nonce"); err == nil {
+		t.Error("ID token issued for another client was accepted")
+	}
+}
@@ -0,0 +1,68 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"sort"
+	"strings"
+)
+
+// BreachedList holds SHA-1 hashes of passwords known from data breaches, sorted for binary search
+type BreachedList struct {
+	hashes [][sha1.Size]byte
+}
+
+// Load breached password list from file. Each line is either the hex SHA-1 hash of a password
+// (optionally followed by ":<count>", as in the Have I Been Pwned downloads) or a plaintext password
+func LoadBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedList{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		list.hashes = append(list.hashes, parseBreachedLine(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list.hashes, func(i, j int) bool {
+		return bytes.Compare(list.hashes[i][:], list.hashes[j][:]) < 0
+	})
+	return list, nil
+}
+
+func parseBreachedLine(line string) [sha1.Size]byte {
+	var hash [sha1.Size]byte
+	hexHash, _, _ := strings.Cut(line, ":")
+	if len(hexHash) == hex.EncodedLen(sha1.Size) {
+		if _, err := hex.Decode(hash[:], []byte(hexHash)); err == nil {
+			return hash
+		}
+	}
+	return sha1.Sum([]byte(line))
+}
+
+// Whether password is in the list
+func (b *BreachedList) Contains(password string) bool {
+	hash := sha1.Sum([]byte(password))
+	i := sort.Search(len(b.hashes), func(i int) bool {
+		return bytes.Compare(b.hashes[i][:], hash[:]) >= 0
+	})
+	return i < len(b.hashes) && b.hashes[i] == hash
+}
+
+func (b *BreachedList) Len() int {
+	return len(b.hashes)
+}
@@ -0,0 +1,207 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported hashing algorithms
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	// Defaults follow the OWASP password storage recommendations
+	defaultArgon2Memory      = 19 * 1024 // KiB
+	defaultArgon2Iterations  = 2
+	defaultArgon2Parallelism = 1
+	defaultBcryptCost        = 10
+	argon2SaltLength         = 16
+	argon2KeyLength          = 32
+
+	// Prefix of hashes of peppered passwords, followed by the hash in its usual format
+	pepperedPrefix = "$pepper"
+)
+
+var ErrUnknownHashFormat = errors.New("Unknown password hash format")
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// Hasher hashes passwords with the configured algorithm, and verifies hashes of all supported algorithms.
+// Argon2id hashes use the PHC string format ($argon2id$v=19$m=...,t=...,p=...$salt$hash),
+// bcrypt hashes the usual modular crypt format ($2a$...)
+type Hasher struct {
+	algorithm  string
+	argon2     argon2Params
+	bcryptCost int
+	pepper     []byte // Server-side secret mixed into passwords before hashing (optional)
+	dummyHash  string
+}
+
+func InitHasher(config *config.Config) *Hasher {
+	conf := config.PasswordHashing
+	h := &Hasher{
+		algorithm: conf.Algorithm,
+		argon2: argon2Params{
+			memory:      conf.Argon2Memory,
+			iterations:  conf.Argon2Iterations,
+			parallelism: conf.Argon2Parallelism,
+		},
+		bcryptCost: conf.BcryptCost,
+	}
+	if h.algorithm == "" {
+		h.algorithm = AlgorithmArgon2id
+	}
+	if h.algorithm != AlgorithmArgon2id && h.algorithm != AlgorithmBcrypt {
+		panic(fmt.Sprintf("password_hashing.algorithm must be %s or %s", AlgorithmArgon2id, AlgorithmBcrypt))
+	}
+	if h.argon2.memory == 0 {
+		h.argon2.memory = defaultArgon2Memory
+	}
+	if h.argon2.iterations == 0 {
+		h.argon2.iterations = defaultArgon2Iterations
+	}
+	if h.argon2.parallelism == 0 {
+		h.argon2.parallelism = defaultArgon2Parallelism
+	}
+	if h.bcryptCost == 0 {
+		h.bcryptCost = defaultBcryptCost
+	}
+	if conf.Pepper != "" {
+		pepper, err := base64.StdEncoding.DecodeString(conf.Pepper)
+		if err != nil || len(pepper) < 16 {
+			panic("password_hashing.pepper must be a base64-encoded key of at least 16 bytes")
+		}
+		h.pepper = pepper
+	}
+
+	dummyHash, err := h.Hash("dummy password")
+	if err != nil {
+		panic(err)
+	}
+	h.dummyHash = dummyHash
+	return h
+}
+
+// Hash password with the configured algorithm and parameters
+func (h *Hasher) Hash(password string) (string, error) {
+	prefix := ""
+	if h.pepper != nil {
+		password = h.applyPepper(password)
+		prefix = pepperedPrefix
+	}
+
+	if h.algorithm == AlgorithmBcrypt {
+		hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return prefix + string(hashedBytes), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.argon2.iterations, h.argon2.memory, h.argon2.parallelism, argon2KeyLength)
+	return fmt.Sprintf("%s$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", prefix, argon2.Version,
+		h.argon2.memory, h.argon2.iterations, h.argon2.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Check password against hash. needsRehash is set if the password is correct but the hash does not use
+// the current algorithm, parameters or pepper. An empty hash (eg. for a user that does not exist) is compared
+// against a dummy hash, so that response time does not reveal that there is no hash
+func (h *Hasher) Verify(password string, hash string) (ok bool, needsRehash bool, err error) {
+	if hash == "" {
+		h.verifyHash(password, h.dummyHash)
+		return false, false, nil
+	}
+	return h.verifyHash(password, hash)
+}
+
+func (h *Hasher) verifyHash(password string, hash string) (bool, bool, error) {
+	peppered := strings.HasPrefix(hash, pepperedPrefix)
+	if peppered {
+		if h.pepper == nil {
+			return false, false, errors.New("Password hash is peppered but no pepper is configured")
+		}
+		password = h.applyPepper(password)
+		hash = strings.TrimPrefix(hash, pepperedPrefix)
+	}
+	needsRehash := peppered != (h.pepper != nil)
+
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return false, false, err
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false, nil
+		}
+		return true, needsRehash || h.algorithm != AlgorithmArgon2id || params != h.argon2, nil
+	case strings.HasPrefix(hash, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, false, err
+		}
+		return true, needsRehash || h.algorithm != AlgorithmBcrypt || cost != h.bcryptCost, nil
+	}
+	return false, false, ErrUnknownHashFormat
+}
+
+// Passwords are replaced by their HMAC with the pepper. The base64-encoded HMAC is also
+// short enough for bcrypt, which ignores everything after 72 bytes
+func (h *Hasher) applyPepper(password string) string {
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func decodeArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	return params, salt, key, nil
+}
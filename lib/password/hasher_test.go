@@ -0,0 +1,207 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+)
+
+var (
+	testPepper  = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	otherPepper = base64.StdEncoding.EncodeToString([]byte("fedcba9876543210"))
+)
+
+// Cheap parameters to keep tests fast
+func testHashingConfig(algorithm string, pepper string) config.PasswordHashing {
+	return config.PasswordHashing{
+		Algorithm:         algorithm,
+		Argon2Memory:      64,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+		BcryptCost:        4,
+		Pepper:            pepper,
+	}
+}
+
+func newTestHasher(conf config.PasswordHashing) *Hasher {
+	return InitHasher(&config.Config{PasswordHashing: conf})
+}
+
+func mustHash(t *testing.T, h *Hasher, password string) string {
+	t.Helper()
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	return hash
+}
+
+func TestHashAndVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		conf       config.PasswordHashing
+		wantPrefix string
+	}{
+		{"Argon2id", testHashingConfig(AlgorithmArgon2id, ""), "$argon2id$v=19$m=64,t=1,p=1$"},
+		{"Default algorithm", testHashingConfig("", ""), "$argon2id$"},
+		{"Bcrypt", testHashingConfig(AlgorithmBcrypt, ""), "$2a$04$"},
+		{"Peppered argon2id", testHashingConfig(AlgorithmArgon2id, testPepper), "$pepper$argon2id$"},
+		{"Peppered bcrypt", testHashingConfig(AlgorithmBcrypt, testPepper), "$pepper$2a$04$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHasher(tt.conf)
+			hash := mustHash(t, h, "correct-horse7")
+			if !strings.HasPrefix(hash, tt.wantPrefix) {
+				t.Errorf("Hash %q does not start with %q", hash, tt.wantPrefix)
+			}
+			if other := mustHash(t, h, "correct-horse7"); other == hash {
+				t.Error("Hashes of the same password are equal, salt not used")
+			}
+
+			ok, needsRehash, err := h.Verify("correct-horse7", hash)
+			if err != nil || !ok || needsRehash {
+				t.Errorf("Verify correct password: ok %v, needsRehash %v, err %v", ok, needsRehash, err)
+			}
+			ok, needsRehash, err = h.Verify("wrong-horse7", hash)
+			if err != nil || ok || needsRehash {
+				t.Errorf("Verify wrong password: ok %v, needsRehash %v, err %v", ok, needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestVerifyEmptyHash(t *testing.T) {
+	h := newTestHasher(testHashingConfig(AlgorithmArgon2id, ""))
+	// The dummy hash must not match its own password
+	for _, password := range []string{"", "dummy password"} {
+		ok, needsRehash, err := h.Verify(password, "")
+		if err != nil || ok || needsRehash {
+			t.Errorf("Verify(%q) against empty hash: ok %v, needsRehash %v, err %v", password, ok, needsRehash, err)
+		}
+	}
+}
+
+func TestVerifyUnknownFormat(t *testing.T) {
+	h := newTestHasher(testHashingConfig(AlgorithmArgon2id, testPepper))
+	for _, hash := range []string{
+		"plaintext",
+		"$1$salt$md5hash",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$",
+		"$pepper$plaintext",
+	} {
+		if _, _, err := h.Verify("correct-horse7", hash); !errors.Is(err, ErrUnknownHashFormat) {
+			t.Errorf("Verify against %q returned %v, want ErrUnknownHashFormat", hash, err)
+		}
+	}
+}
+
+// Hashes from the old settings must still verify, and be flagged for rehashing on login.
+// The rehashed password must verify without needing another rehash
+func TestVerifyNeedsRehash(t *testing.T) {
+	argon2id := testHashingConfig(AlgorithmArgon2id, "")
+	moreMemory := argon2id
+	moreMemory.Argon2Memory = 128
+	moreIterations := argon2id
+	moreIterations.Argon2Iterations = 2
+	bcryptConf := testHashingConfig(AlgorithmBcrypt, "")
+	higherCost := bcryptConf
+	higherCost.BcryptCost = 5
+
+	tests := []struct {
+		name string
+		from config.PasswordHashing
+		to   config.PasswordHashing
+	}{
+		{"Bcrypt to argon2id", bcryptConf, argon2id},
+		{"Argon2id to bcrypt", argon2id, bcryptConf},
+		{"Argon2id memory", argon2id, moreMemory},
+		{"Argon2id iterations", argon2id, moreIterations},
+		{"Bcrypt cost", bcryptConf, higherCost},
+		{"Pepper added to argon2id", argon2id, testHashingConfig(AlgorithmArgon2id, testPepper)},
+		{"Pepper added to bcrypt", bcryptConf, testHashingConfig(AlgorithmArgon2id, testPepper)},
+		{"Peppered bcrypt to argon2id", testHashingConfig(AlgorithmBcrypt, testPepper),
+			testHashingConfig(AlgorithmArgon2id, testPepper)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldHash := mustHash(t, newTestHasher(tt.from), "correct-horse7")
+			h := newTestHasher(tt.to)
+
+			ok, needsRehash, err := h.Verify("correct-horse7", oldHash)
+			if err != nil || !ok || !needsRehash {
+				t.Fatalf("Verify old hash: ok %v, needsRehash %v, err %v", ok, needsRehash, err)
+			}
+			ok, needsRehash, err = h.Verify("wrong-horse7", oldHash)
+			if err != nil || ok || needsRehash {
+				t.Errorf("Verify wrong password against old hash: ok %v, needsRehash %v, err %v", ok, needsRehash, err)
+			}
+
+			newHash := mustHash(t, h, "correct-horse7")
+			ok, needsRehash, err = h.Verify("correct-horse7", newHash)
+			if err != nil || !ok || needsRehash {
+				t.Errorf("Verify rehashed password: ok %v, needsRehash %v, err %v", ok, needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestVerifyPepper(t *testing.T) {
+	peppered := mustHash(t, newTestHasher(testHashingConfig(AlgorithmArgon2id, testPepper)), "correct-horse7")
+
+	// Pepper cannot be removed once set
+	_, _, err := newTestHasher(testHashingConfig(AlgorithmArgon2id, "")).Verify("correct-horse7", peppered)
+	if err == nil {
+		t.Error("Expected error verifying peppered hash without pepper")
+	}
+
+	ok, _, err := newTestHasher(testHashingConfig(AlgorithmArgon2id, otherPepper)).Verify("correct-horse7", peppered)
+	if err != nil || ok {
+		t.Errorf("Verify with other pepper: ok %v, err %v", ok, err)
+	}
+}
+
+// bcrypt only uses the first 72 bytes, which the pepper's HMAC avoids
+func TestBcryptLongPasswords(t *testing.T) {
+	long := strings.Repeat("a", 72)
+	h := newTestHasher(testHashingConfig(AlgorithmBcrypt, ""))
+	if _, err := h.Hash(long + "1"); err == nil {
+		t.Error("Expected error hashing password over 72 bytes with bcrypt")
+	}
+
+	h = newTestHasher(testHashingConfig(AlgorithmBcrypt, testPepper))
+	hash := mustHash(t, h, long+"1")
+	if ok, _, err := h.Verify(long+"1", hash); err != nil || !ok {
+		t.Errorf("Verify long password: ok %v, err %v", ok, err)
+	}
+	if ok, _, err := h.Verify(long+"2", hash); err != nil || ok {
+		t.Errorf("Verify long password differing after 72 bytes: ok %v, err %v", ok, err)
+	}
+}
+
+func TestInitHasherInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config.PasswordHashing
+	}{
+		{"Unknown algorithm", testHashingConfig("scrypt", "")},
+		{"Pepper not base64", testHashingConfig(AlgorithmArgon2id, "not base64!")},
+		{"Pepper too short", testHashingConfig(AlgorithmArgon2id, base64.StdEncoding.EncodeToString([]byte("short")))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected panic")
+				}
+			}()
+			newTestHasher(tt.conf)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package password validates new passwords against the configured password policy
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/logger"
+)
+
+const (
+	// bcrypt ignores everything after the first 72 bytes
+	bcryptMaxBytes          = 72
+	defaultMinLength        = 8
+	defaultMinCharClasses   = 2
+	minPersonalInfoPartSize = 3 // Shorter parts of email or name are too common to reject
+)
+
+// Rules a password can violate
+const (
+	RuleMinLength      = "min_length"
+	RuleMaxLength      = "max_length"
+	RuleCharClasses    = "character_classes"
+	RuleNoPersonalInfo = "no_personal_info"
+	RuleNotBreached    = "not_breached"
+)
+
+// Violation of a password policy rule
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type Policy struct {
+	MinLength      int // No. of characters
+	MaxLength      int // No. of bytes
+	MinCharClasses int // No. of classes (lowercase, uppercase, digits, symbols) that must be used
+	breachedList   *BreachedList
+}
+
+func InitPolicy(config *config.Config) *Policy {
+	conf := config.PasswordPolicy
+	policy := &Policy{
+		MinLength:      conf.MinLength,
+		MaxLength:      conf.MaxLength,
+		MinCharClasses: conf.MinCharClasses,
+	}
+	if policy.MinLength == 0 {
+		policy.MinLength = defaultMinLength
+	}
+	if policy.MaxLength == 0 || policy.MaxLength > bcryptMaxBytes {
+		policy.MaxLength = bcryptMaxBytes
+	}
+	if policy.MinCharClasses == 0 {
+		policy.MinCharClasses = defaultMinCharClasses
+	}
+
+	if conf.BreachedListPath != "" {
+		breachedList, err := LoadBreachedList(conf.BreachedListPath)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load breached password list: %v", err))
+		}
+		policy.breachedList = breachedList
+		logger.GetLogger().WithField("count", breachedList.Len()).Info("Loaded breached password list")
+	}
+	return policy
+}
+
+// Check password against policy. personalInfo (eg. email and name of the user) must not be part of the password.
+// Returns all violated rules, or nil if the password is acceptable
+func (p *Policy) Validate(password string, personalInfo ...string) []Violation {
+	var violations []Violation
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.MinLength),
+		})
+	}
+	if len(password) > p.MaxLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", p.MaxLength),
+		})
+	}
+	if countCharClasses(password) < p.MinCharClasses {
+		violations = append(violations, Violation{
+			Rule: RuleCharClasses,
+			Message: fmt.Sprintf("Password must use at least %d of lowercase letters, uppercase letters, digits and symbols",
+				p.MinCharClasses),
+		})
+	}
+	if containsPersonalInfo(password, personalInfo) {
+		violations = append(violations, Violation{
+			Rule:    RuleNoPersonalInfo,
+			Message: "Password must not contain your email or name",
+		})
+	}
+	if p.breachedList != nil && p.breachedList.Contains(password) {
+		violations = append(violations, Violation{
+			Rule:    RuleNotBreached,
+			Message: "Password has appeared in a data breach and cannot be used",
+		})
+	}
+	return violations
+}
+
+func countCharClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	count := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
+
+// Checks for the email, its local part, and each word of the name (case-insensitive)
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	lowerPassword := strings.ToLower(password)
+	for _, info := range personalInfo {
+		info = strings.ToLower(info)
+		parts := strings.Fields(info)
+		if localPart, _, ok := strings.Cut(info, "@"); ok {
+			parts = append(parts, localPart)
+		}
+		for _, part := range parts {
+			if len([]rune(part)) >= minPersonalInfoPartSize && strings.Contains(lowerPassword, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+)
+
+func rules(violations []Violation) []string {
+	var rules []string
+	for _, violation := range violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func writeBreachedList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatalf("Failed to write breached list: %v", err)
+	}
+	return path
+}
+
+func sha1Hex(password string) string {
+	hash := sha1.Sum([]byte(password))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestInitPolicyDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config.PasswordPolicy
+		want Policy
+	}{
+		{"Defaults", config.PasswordPolicy{}, Policy{MinLength: 8, MaxLength: 72, MinCharClasses: 2}},
+		{"Configured", config.PasswordPolicy{MinLength: 12, MaxLength: 64, MinCharClasses: 3},
+			Policy{MinLength: 12, MaxLength: 64, MinCharClasses: 3}},
+		{"MaxLength capped at bcrypt limit", config.PasswordPolicy{MaxLength: 100},
+			Policy{MinLength: 8, MaxLength: 72, MinCharClasses: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := InitPolicy(&config.Config{PasswordPolicy: tt.conf})
+			if *policy != tt.want {
+				t.Errorf("Got policy %+v, want %+v", *policy, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	path := writeBreachedList(t, sha1Hex("Password1"))
+	policy := InitPolicy(&config.Config{PasswordPolicy: config.PasswordPolicy{BreachedListPath: path}})
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"Valid", "correct-horse7", nil},
+		{"Too short", "abc-12", []string{RuleMinLength}},
+		// 7 characters but 13 bytes
+		{"Length counts characters", "äöüßäö1", []string{RuleMinLength}},
+		{"Too long", strings.Repeat("a1", 36) + "b", []string{RuleMaxLength}},
+		{"Max length", strings.Repeat("a1", 36), nil},
+		{"Max length in bytes", strings.Repeat("ä", 35) + "1a", nil},
+		{"Multibyte too long", strings.Repeat("ä", 36) + "1", []string{RuleMaxLength}},
+		{"Single character class", "abcdefghij", []string{RuleCharClasses}},
+		{"Uppercase and symbols", "ABCDEFGH!", nil},
+		{"Contains email", "x-jane.doe@example.com-1", []string{RuleNoPersonalInfo}},
+		{"Contains local part", "JANE.DOE-2024", []string{RuleNoPersonalInfo}},
+		{"Contains name", "smith-2024!", []string{RuleNoPersonalInfo}},
+		{"Short name parts ignored", "xy-2024-al", nil},
+		{"Breached", "Password1", []string{RuleNotBreached}},
+		{"Multiple violations", "smith", []string{RuleMinLength, RuleCharClasses, RuleNoPersonalInfo}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(policy.Validate(tt.password, "jane.doe@example.com", "Al Xy Smith"))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%q) violated %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBreachedList(t *testing.T) {
+	var lines, breached []string
+	for i := 0; i < 500; i++ {
+		password := fmt.Sprintf("breached-%d", i)
+		breached = append(breached, password)
+		switch i % 3 {
+		case 0:
+			lines = append(lines, strings.ToUpper(sha1Hex(password))+fmt.Sprintf(":%d", i+1))
+		case 1:
+			lines = append(lines, sha1Hex(password)+"\r")
+		default:
+			lines = append(lines, password)
+		}
+	}
+	lines = append(lines, "")
+	list, err := LoadBreachedList(writeBreachedList(t, lines...))
+	if err != nil {
+		t.Fatalf("Failed to load breached list: %v", err)
+	}
+	if list.Len() != len(breached) {
+		t.Errorf("Loaded %d hashes, want %d", list.Len(), len(breached))
+	}
+
+	for _, password := range breached {
+		if !list.Contains(password) {
+			t.Errorf("Breached password %q not found", password)
+		}
+	}
+	for _, password := range []string{"", "breached-500", "breached", "BREACHED-1", "correct-horse7"} {
+		if list.Contains(password) {
+			t.Errorf("Password %q found but not in list", password)
+		}
+	}
+}
+
+func TestLoadBreachedListMissingFile(t *testing.T) {
+	if _, err := LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -3,17 +3,23 @@ package randgenerate
 import (
 	cryptoRand "crypto/rand"
 	"encoding/hex"
-	"math/rand"
+	"math/big"
 )
 
 var runes = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ123456789")
 
-func GenerateAlphaNumericString(n int) string {
+// Generates random string of uppercase letters and digits from a CSPRNG
+func GenerateAlphaNumericString(n int) (string, error) {
 	b := make([]rune, n)
+	max := big.NewInt(int64(len(runes)))
 	for i := range b {
-		b[i] = runes[rand.Intn(len(runes))]
+		idx, err := cryptoRand.Int(cryptoRand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = runes[idx.Int64()]
 	}
-	return string(b)
+	return string(b), nil
 }
 
 func GenerateSecureToken(n int) (string, error) {
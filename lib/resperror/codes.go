@@ -1,28 +1,100 @@
 package resperror
 
 // Error codes are in the format XYYZZ, where X represents the error code version,
-// YY represents the module where the error happened, and ZZ enumerates the error which occurred
+// YY represents the module where the error happened, and ZZ enumerates the error which occurred.
+// Every code must have an entry in ErrorMapping (checked by cmd/errcatalog, which also generates
+// the error catalog in docs/)
+
+// Code of error responses
+type Code int
 
 // General errors
 const (
-	UnknownError    = 10000
-	BadRequest      = 10001
-	TooManyRequests = 10002 // Rate limit
-	Unauthorized    = 10003
+	UnknownError    Code = 10000
+	BadRequest      Code = 10001
+	TooManyRequests Code = 10002 // Rate limit
+	Unauthorized    Code = 10003
+	Forbidden       Code = 10004
 )
 
 // User
 const (
-	UserAlreadyExistsError  = 10101
-	UserNotFound            = 10102
-	UserNotVerifiedError    = 10103
-	UserLicenseExpiredError = 10104
-	UserEmailNotFound       = 10105
-	UserIncorrectPassword   = 10106
+	UserAlreadyExistsError  Code = 10101
+	UserNotFound            Code = 10102
+	UserNotVerifiedError    Code = 10103
+	UserLicenseExpiredError Code = 10104
+	UserEmailNotFound       Code = 10105
+	UserIncorrectPassword   Code = 10106
+	InvalidCredentialsError Code = 10107 // Login failed (does not reveal whether account exists)
+	PasswordPolicyError     Code = 10108
 )
 
 // Email verification
 const (
-	UserAlreadyVerifiedError = 10201
-	InvalidTokenError        = 10202
+	UserAlreadyVerifiedError Code = 10201
+	InvalidTokenError        Code = 10202
+)
+
+// Entitlements
+const (
+	FeatureNotAvailableError Code = 10301
+)
+
+// Metering
+const (
+	QuotaExceededError Code = 10401
+)
+
+// Billing
+const (
+	BillingNotConfiguredError    Code = 10501
+	InvalidWebhookSignatureError Code = 10502
+	InvalidWebhookPayloadError   Code = 10503
+)
+
+// Two-factor authentication
+const (
+	TwoFactorAlreadyEnabledError       Code = 10601
+	TwoFactorNotEnabledError           Code = 10602
+	TwoFactorEnrollmentNotStartedError Code = 10603
+	InvalidTwoFactorCodeError          Code = 10604
+	TwoFactorNotConfiguredError        Code = 10605
+)
+
+// External identity providers
+const (
+	UnknownIdentityProviderError          Code = 10701
+	IdentityProviderLoginError            Code = 10702
+	IdentityProviderEmailNotVerifiedError Code = 10703
+)
+
+// OAuth authorization server
+const (
+	OAuthClientNotFoundError         Code = 10801
+	OAuthConsentRequestNotFoundError Code = 10802
+)
+
+// Login protection
+const (
+	LoginThrottledError     Code = 10901
+	AccountLockedError      Code = 10902
+	InvalidUnlockTokenError Code = 10903
+)
+
+// Email
+const (
+	OutboxMessageNotFoundError      Code = 11001
+	OutboxMessageAlreadySentError   Code = 11002
+	MailboxMessageNotFoundError     Code = 11003
+	EmailTemplateNotFoundError      Code = 11004
+	EmailSuppressionNotFoundError   Code = 11005
+	InvalidBounceNotificationError  Code = 11006
+	BounceWebhookNotConfiguredError Code = 11007
+	OutboxMessageNotPendingError    Code = 11008 // Content of sent, suppressed and dead emails is removed, so they cannot be retried
+)
+
+// Notifications
+const (
+	SecurityNotificationsRequiredError Code = 11101
+	InvalidUnsubscribeLinkError        Code = 11102
 )
@@ -1,18 +1,55 @@
 package resperror
 
-import "net/http"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
 
+// CustomErrWithCode is an error that is sent to clients with its code and message.
+// Internal detail and the wrapped cause are only logged, never sent to clients
 type CustomErrWithCode struct {
-	Code       int
+	Code       Code
 	Message    string
 	StatusCode int
+	Details    interface{} // Optional structured details sent to client (eg. failed validation rules)
+	Internal   string      // Optional detail for logs (eg. why a token was rejected)
+	cause      error
 }
 
+// RetryDetails are the details of errors for requests that can be retried later.
+// The Retry-After header is set for errors with these details
+type RetryDetails struct {
+	RetryAfter int `json:"retry_after"` // No. of seconds after which request can be retried
+}
+
+// Message with internal detail and cause, for logging
 func (c CustomErrWithCode) Error() string {
-	return c.Message
+	msg := c.Message
+	if msg == "" {
+		msg = fmt.Sprintf("Error %d", c.Code)
+	}
+	if c.Internal != "" {
+		msg += ": " + c.Internal
+	}
+	if c.cause != nil {
+		msg += ": " + c.cause.Error()
+	}
+	return msg
 }
 
-func NewError(code int) CustomErrWithCode {
+// Cause of error (if any)
+func (c CustomErrWithCode) Unwrap() error {
+	return c.cause
+}
+
+// Errors with the same code are equal, so that errors.Is(err, NewError(code)) checks the code of err
+func (c CustomErrWithCode) Is(target error) bool {
+	t, ok := target.(CustomErrWithCode)
+	return ok && t.Code == c.Code
+}
+
+func NewError(code Code) CustomErrWithCode {
 	customErr, ok := ErrorMapping[code]
 	if !ok {
 		return CustomErrWithCode{
@@ -23,3 +60,38 @@ func NewError(code int) CustomErrWithCode {
 	}
 	return customErr
 }
+
+// Error with code caused by err
+func Wrap(code Code, err error) CustomErrWithCode {
+	return NewError(code).WithCause(err)
+}
+
+// Returns copy of error with details attached
+func (c CustomErrWithCode) WithDetails(details interface{}) CustomErrWithCode {
+	c.Details = details
+	return c
+}
+
+// Returns copy of error with internal detail attached
+func (c CustomErrWithCode) WithInternal(format string, args ...interface{}) CustomErrWithCode {
+	c.Internal = fmt.Sprintf(format, args...)
+	return c
+}
+
+// Returns copy of error wrapping err
+func (c CustomErrWithCode) WithCause(err error) CustomErrWithCode {
+	c.cause = err
+	return c
+}
+
+// Finds first error with code in chain of err
+func As(err error) (CustomErrWithCode, bool) {
+	var customErr CustomErrWithCode
+	ok := errors.As(err, &customErr)
+	return customErr, ok
+}
+
+// Whether chain of err has an error with code
+func HasCode(err error, code Code) bool {
+	return errors.Is(err, NewError(code))
+}
@@ -2,8 +2,13 @@ package resperror
 
 import "net/http"
 
-var ErrorMapping map[int]CustomErrWithCode = map[int]CustomErrWithCode{
+var ErrorMapping map[Code]CustomErrWithCode = map[Code]CustomErrWithCode{
 	// General errors
+	UnknownError: {
+		StatusCode: http.StatusInternalServerError,
+		Code:       UnknownError,
+		Message:    "Internal server error",
+	},
 	BadRequest: {
 		StatusCode: http.StatusBadRequest,
 		Code:       BadRequest,
@@ -19,6 +24,11 @@ var ErrorMapping map[int]CustomErrWithCode = map[int]CustomErrWithCode{
 		Code:       Unauthorized,
 		Message:    "Unauthorized",
 	},
+	Forbidden: {
+		StatusCode: http.StatusForbidden,
+		Code:       Forbidden,
+		Message:    "Forbidden",
+	},
 	// User errors
 	UserAlreadyExistsError: {
 		StatusCode: http.StatusConflict,
@@ -50,6 +60,16 @@ var ErrorMapping map[int]CustomErrWithCode = map[int]CustomErrWithCode{
 		Code:       UserIncorrectPassword,
 		Message:    "Incorrect password",
 	},
+	InvalidCredentialsError: {
+		StatusCode: http.StatusUnauthorized,
+		Code:       InvalidCredentialsError,
+		Message:    "Invalid email or password",
+	},
+	PasswordPolicyError: {
+		StatusCode: http.StatusBadRequest,
+		Code:       PasswordPolicyError,
+		Message:    "Password does not meet requirements",
+	},
 	// Email verification errors
 	UserAlreadyVerifiedError: {
 		StatusCode: http.StatusMethodNotAllowed,
@@ -61,4 +81,151 @@ var ErrorMapping map[int]CustomErrWithCode = map[int]CustomErrWithCode{
 		Code:       InvalidTokenError,
 		Message:    "Invalid token",
 	},
+	// Entitlement errors
+	FeatureNotAvailableError: {
+		StatusCode: http.StatusForbidden,
+		Code:       FeatureNotAvailableError,
+		Message:    "Feature is not available on current plan",
+	},
+	// Metering errors
+	QuotaExceededError: {
+		StatusCode: http.StatusTooManyRequests,
+		Code:       QuotaExceededError,
+		Message:    "Usage quota of current plan exceeded",
+	},
+	// Billing errors
+	BillingNotConfiguredError: {
+		StatusCode: http.StatusServiceUnavailable,
+		Code:       BillingNotConfiguredError,
+		Message:    "Billing is not configured",
+	},
+	InvalidWebhookSignatureError: {
+		StatusCode: http.StatusUnauthorized,
+		Code:       InvalidWebhookSignatureError,
+		Message:    "Invalid webhook signature",
+	},
+	InvalidWebhookPayloadError: {
+		StatusCode: http.StatusBadRequest,
+		Code:       InvalidWebhookPayloadError,
+		Message:    "Invalid webhook payload",
+	},
+	// Two-factor authentication errors
+	TwoFactorAlreadyEnabledError: {
+		StatusCode: http.StatusConflict,
+		Code:       TwoFactorAlreadyEnabledError,
+		Message:    "Two-factor authentication is already enabled",
+	},
+	TwoFactorNotEnabledError: {
+		StatusCode: http.StatusBadRequest,
+		Code:       TwoFactorNotEnabledError,
+		Message:    "Two-factor authentication is not enabled",
+	},
+	TwoFactorEnrollmentNotStartedError: {
+		StatusCode: http.StatusBadRequest,
+		Code:       TwoFactorEnrollmentNotStartedError,
+		Message:    "Two-factor authentication enrollment has not been started",
+	},
+	InvalidTwoFactorCodeError: {
+		StatusCode: http.StatusUnauthorized,
+		Code:       InvalidTwoFactorCodeError,
+		Message:    "Invalid two-factor authentication code",
+	},
+	TwoFactorNotConfiguredError: {
+		StatusCode: http.StatusServiceUnavailable,
+		Code:       TwoFactorNotConfiguredError,
+		Message:    "Two-factor authentication is not configured",
+	},
+	// External identity provider errors
+	UnknownIdentityProviderError: {
+		StatusCode: http.StatusNotFound,
+		Code:       UnknownIdentityProviderError,
+		Message:    "Unknown identity provider",
+	},
+	IdentityProviderLoginError: {
+		StatusCode: http.StatusUnauthorized,
+		Code:       IdentityProviderLoginError,
+		Message:    "Failed to log in with identity provider",
+	},
+	IdentityProviderEmailNotVerifiedError: {
+		StatusCode: http.StatusForbidden,
+		Code:       IdentityProviderEmailNotVerifiedError,
+		Message:    "Email of identity provider account is not verified",
+	},
+	// OAuth authorization server errors
+	OAuthClientNotFoundError: {
+		StatusCode: http.StatusNotFound,
+		Code:       OAuthClientNotFoundError,
+		Message:    "OAuth client not found",
+	},
+	OAuthConsentRequestNotFoundError: {
+		StatusCode: http.StatusNotFound,
+		Code:       OAuthConsentRequestNotFoundError,
+		Message:    "Consent request not found or expired",
+	},
+	// Login protection errors
+	LoginThrottledError: {
+		StatusCode: http.StatusTooManyRequests,
+		Code:       LoginThrottledError,
+		Message:    "Too many failed login attempts, try again later",
+	},
+	AccountLockedError: {
+		StatusCode: http.StatusTooManyRequests,
+		Code:       AccountLockedError,
+		Message:    "Account temporarily locked due to too many failed login attempts",
+	},
+	InvalidUnlockTokenError: {
+		StatusCode: http.StatusBadRequest,
+		Code:       InvalidUnlockTokenError,
+		Message:    "Invalid or expired unlock link",
+	},
+	OutboxMessageNotFoundError: {
+		StatusCode: http.StatusNotFound,
+		Code:       OutboxMessageNotFoundError,
+		Message:    "Email not found",
+	},
+	OutboxMessageAlreadySentError: {
+		StatusCode: http.StatusConflict,
+		Code:       OutboxMessageAlreadySentError,
+		Message:    "Email has already been sent",
+	},
+	MailboxMessageNotFoundError: {
+		StatusCode: http.StatusNotFound,
+		Code:       MailboxMessageNotFoundError,
+		Message:    "Email not found in mailbox",
+	},
+	EmailTemplateNotFoundError: {
+		StatusCode: http.StatusNotFound,
+		Code:       EmailTemplateNotFoundError,
+		Message:    "Email template not found",
+	},
+	EmailSuppressionNotFoundError: {
+		StatusCode: http.StatusNotFound,
+		Code:       EmailSuppressionNotFoundError,
+		Message:    "Suppressed address not found",
+	},
+	InvalidBounceNotificationError: {
+		StatusCode: http.StatusBadRequest,
+		Code:       InvalidBounceNotificationError,
+		Message:    "Invalid bounce or complaint notification",
+	},
+	BounceWebhookNotConfiguredError: {
+		StatusCode: http.StatusServiceUnavailable,
+		Code:       BounceWebhookNotConfiguredError,
+		Message:    "Bounce notifications are not configured",
+	},
+	OutboxMessageNotPendingError: {
+		StatusCode: http.StatusConflict,
+		Code:       OutboxMessageNotPendingError,
+		Message:    "Email is no longer queued and cannot be retried",
+	},
+	SecurityNotificationsRequiredError: {
+		StatusCode: http.StatusBadRequest,
+		Code:       SecurityNotificationsRequiredError,
+		Message:    "Security notifications cannot be disabled",
+	},
+	InvalidUnsubscribeLinkError: {
+		StatusCode: http.StatusBadRequest,
+		Code:       InvalidUnsubscribeLinkError,
+		Message:    "Invalid unsubscribe link",
+	},
 }
@@ -0,0 +1,75 @@
+package resperror
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// Codes declared in codes.go by name
+func declaredCodes(t *testing.T) map[string]Code {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "codes.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse codes.go: %v", err)
+	}
+	codes := map[string]Code{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				lit, ok := valueSpec.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.INT {
+					t.Errorf("%s: value must be an integer literal", name.Name)
+					continue
+				}
+				value, err := strconv.Atoi(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				codes[name.Name] = Code(value)
+			}
+		}
+	}
+	if len(codes) == 0 {
+		t.Fatal("No codes declared in codes.go")
+	}
+	return codes
+}
+
+func TestErrorMappingCoversDeclaredCodes(t *testing.T) {
+	names := map[Code]string{}
+	for name, code := range declaredCodes(t) {
+		if other, ok := names[code]; ok {
+			t.Errorf("%s: code %d is also used by %s", name, code, other)
+		}
+		names[code] = name
+
+		mapping, ok := ErrorMapping[code]
+		if !ok {
+			t.Errorf("%s: code %d missing from ErrorMapping", name, code)
+			continue
+		}
+		if mapping.Code != code {
+			t.Errorf("%s: ErrorMapping entry has code %d", name, mapping.Code)
+		}
+		if mapping.Message == "" {
+			t.Errorf("%s: ErrorMapping entry has no message", name)
+		}
+		if http.StatusText(mapping.StatusCode) == "" {
+			t.Errorf("%s: invalid status code %d", name, mapping.StatusCode)
+		}
+	}
+	for code := range ErrorMapping {
+		if _, ok := names[code]; !ok {
+			t.Errorf("ErrorMapping has entry for undeclared code %d", code)
+		}
+	}
+}
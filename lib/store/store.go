@@ -3,9 +3,12 @@ package store
 import (
 	"errors"
 	"fmt"
+	"sync"
 )
 
+// Store is a map that is safe for concurrent use
 type Store[T comparable, U any] struct {
+	mu       sync.RWMutex
 	storeMap map[T]U
 }
 
@@ -16,10 +19,14 @@ func NewStore[T comparable, U any]() *Store[T, U] {
 }
 
 func (u *Store[T, U]) Set(key T, value U) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
 	u.storeMap[key] = value
 }
 
 func (u *Store[T, U]) Get(key T) (U, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
 	value, ok := u.storeMap[key]
 	if !ok {
 		var zero U
@@ -29,5 +36,7 @@ func (u *Store[T, U]) Get(key T) (U, error) {
 }
 
 func (u *Store[T, U]) Delete(key T) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
 	delete(u.storeMap, key)
 }
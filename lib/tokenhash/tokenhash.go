@@ -0,0 +1,13 @@
+package tokenhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Hash high-entropy secrets (eg. tokens and recovery codes) for storage.
+// Not suitable for passwords, which should be hashed with a slow hash
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
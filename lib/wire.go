@@ -2,7 +2,14 @@ package lib
 
 import (
 	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/encryption"
+	"github.com/dominiclet/golang-base/lib/password"
 	"github.com/google/wire"
 )
 
-var LibSet = wire.NewSet(email.InitEmailService)
+var LibSet = wire.NewSet(
+	email.InitEmailService,
+	encryption.InitEncrypter,
+	password.InitPolicy,
+	password.InitHasher,
+)
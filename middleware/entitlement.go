@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Check if user's plan includes the feature
+// NOTE: Must be used after AuthRequired as it relies on the user injected into context
+func (m *Middleware) RequireFeature(feature entitlement.Feature) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := ctxwrapper.GetUser(c)
+		if err != nil {
+			m.logger.WithField("err", err).Error("Failed to get user from context")
+			httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+			c.Abort()
+			return
+		}
+		if !m.entitlementService.HasFeature(&user, feature) {
+			m.logger.WithFields(logrus.Fields{
+				"user_uuid": user.Uuid,
+				"feature":   feature,
+			}).Info("Feature not available for user")
+			httpresp.SendError(c, resperror.NewError(resperror.FeatureNotAvailableError))
+			c.Abort()
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/metering"
+	"github.com/gin-gonic/gin"
+)
+
+// Record usage of metric for the user and reject request if user's quota is exceeded
+// NOTE: Must be used after AuthRequired as it relies on the user injected into context
+func (m *Middleware) EnforceQuota(metric metering.Metric) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := ctxwrapper.GetUser(c)
+		if err != nil {
+			m.logger.WithField("err", err).Error("Failed to get user from context")
+			httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+			c.Abort()
+			return
+		}
+		usage, err := m.meteringService.ConsumeQuota(c, &user, metric)
+		if usage != nil {
+			setQuotaHeaders(c, usage)
+		}
+		if err != nil {
+			if _, ok := resperror.As(err); !ok {
+				// Do not block requests if usage cannot be metered
+				m.logger.WithField("err", err).Error("Failed to meter usage")
+				return
+			}
+			c.Header("Retry-After", strconv.FormatInt(int64(time.Until(usage.ResetAt).Seconds()), 10))
+			httpresp.SendError(c, err)
+			c.Abort()
+			return
+		}
+	}
+}
+
+func setQuotaHeaders(c *gin.Context, usage *metering.Usage) {
+	if usage.Limit == entitlement.Unlimited {
+		return
+	}
+	c.Header("X-RateLimit-Limit", strconv.FormatInt(usage.Limit, 10))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(usage.Remaining(), 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(usage.ResetAt.Unix(), 10))
+}
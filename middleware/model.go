@@ -1,19 +1,29 @@
 package middleware
 
 import (
+	"github.com/dominiclet/golang-base/init_server/config"
 	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/metering"
 	"github.com/dominiclet/golang-base/service/session"
 	"github.com/sirupsen/logrus"
 )
 
 type Middleware struct {
-	sessionService *session.SessionService
-	logger         *logrus.Entry
+	sessionService     *session.SessionService
+	entitlementService *entitlement.EntitlementService
+	meteringService    *metering.MeteringService
+	config             *config.Config
+	logger             *logrus.Entry
 }
 
-func InitMiddleware(sessionService *session.SessionService) *Middleware {
+func InitMiddleware(sessionService *session.SessionService, entitlementService *entitlement.EntitlementService,
+	meteringService *metering.MeteringService, config *config.Config) *Middleware {
 	return &Middleware{
-		sessionService: sessionService,
-		logger:         logger.GetLogger().WithField("module", "middleware"),
+		sessionService:     sessionService,
+		entitlementService: entitlementService,
+		meteringService:    meteringService,
+		config:             config,
+		logger:             logger.GetLogger().WithField("module", "middleware"),
 	}
 }
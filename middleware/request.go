@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+
+	ctxwrapper "github.com/dominiclet/golang-base/lib/ctx_wrapper"
+	"github.com/dominiclet/golang-base/lib/httpresp"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Request IDs from clients or proxies are only used if they are short and contain no special characters
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Assign ID to request, which is returned in the X-Request-ID header and in problem details.
+// The ID of the X-Request-ID request header is kept if set (eg. by a proxy)
+func (m *Middleware) RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		ctxwrapper.SetRequestID(c, requestID)
+		c.Header(RequestIDHeader, requestID)
+	}
+}
+
+// Configure format of error responses (see httpresp.ProblemOptions)
+func (m *Middleware) ErrorFormat() gin.HandlerFunc {
+	opts := httpresp.ProblemOptions{
+		Default:     m.config.API.ProblemDetails,
+		TypeBaseURI: m.config.API.ProblemTypeBaseURI,
+	}
+	if opts.TypeBaseURI == "" {
+		opts.TypeBaseURI = fmt.Sprintf("https://%s/problems/", m.config.Domain)
+	}
+	return func(c *gin.Context) {
+		httpresp.SetProblemOptions(c, opts)
+	}
+}
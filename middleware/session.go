@@ -29,3 +29,23 @@ func (m *Middleware) AuthRequired() gin.HandlerFunc {
 		ctxwrapper.SetUser(c, *user)
 	}
 }
+
+// Check if user is an admin
+// NOTE: Must be used after AuthRequired as it relies on the user injected into context
+func (m *Middleware) AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := ctxwrapper.GetUser(c)
+		if err != nil {
+			m.logger.WithField("err", err).Error("Failed to get user from context")
+			httpresp.SendError(c, resperror.NewError(resperror.Unauthorized))
+			c.Abort()
+			return
+		}
+		if !user.IsAdmin {
+			m.logger.WithField("user_uuid", user.Uuid).Error("Non-admin user attempted to access admin endpoint")
+			httpresp.SendError(c, resperror.NewError(resperror.Forbidden))
+			c.Abort()
+			return
+		}
+	}
+}
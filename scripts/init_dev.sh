@@ -3,4 +3,4 @@
 export DEV=1
 export CONFIG_PATH=conf/config.yaml
 
-go run cmd/main.go
+go run ./cmd serve
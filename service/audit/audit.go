@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type AuditService struct {
+	db     *gorm.DB
+	logger *logrus.Entry
+}
+
+func InitAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{
+		db:     db,
+		logger: logger.GetLogger().WithField("module", "audit_service"),
+	}
+}
+
+// Record event in audit log. Events are also written to the application log,
+// so that they are not lost if the write to the audit log fails
+func (a *AuditService) Record(ctx context.Context, event AuditEvent) error {
+	fields := logrus.Fields{
+		"event":   event.Event,
+		"ip":      event.IP,
+		"details": event.Details,
+	}
+	if event.UserID != nil {
+		fields["user_id"] = *event.UserID
+	}
+	a.logger.WithFields(fields).Info("Audit event")
+
+	if err := a.db.WithContext(ctx).Create(&event).Error; err != nil {
+		a.logger.WithField("err", err).Error("Failed to write audit event")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package audit
+
+import "time"
+
+// Audited events
+const (
+	EventAccountLocked   = "account_locked"
+	EventAccountUnlocked = "account_unlocked"
+	EventIPBlocked       = "ip_blocked"
+)
+
+// AuditEvent is a security-relevant event, kept for later review
+type AuditEvent struct {
+	ID        uint `gorm:"primarykey"`
+	Event     string
+	UserID    *uint // Not set if event is not associated with a user
+	IP        string
+	Details   map[string]interface{} `gorm:"serializer:json"`
+	CreatedAt time.Time
+}
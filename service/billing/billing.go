@@ -0,0 +1,164 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/notification"
+	"github.com/dominiclet/golang-base/service/session"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BillingService struct {
+	db                  *gorm.DB
+	sessionService      *session.SessionService
+	notificationService *notification.NotificationService
+	provider            Provider // nil if billing is not configured
+	webhookSecret       string
+	logger              *logrus.Entry
+}
+
+func InitBillingService(db *gorm.DB, config *config.Config, sessionService *session.SessionService,
+	notificationService *notification.NotificationService) *BillingService {
+	b := &BillingService{
+		db:                  db,
+		sessionService:      sessionService,
+		notificationService: notificationService,
+		webhookSecret:       config.Billing.WebhookSecret,
+		logger:              logger.GetLogger().WithField("module", "billing_service"),
+	}
+	if config.Billing.Provider != "" {
+		provider, err := NewProvider(config.Billing.Provider)
+		if err != nil {
+			panic(err)
+		}
+		b.provider = provider
+	}
+	return b
+}
+
+// Verifies and processes a webhook payload from the configured payment provider.
+// Events that have already been processed are ignored
+func (b *BillingService) HandleWebhook(ctx context.Context, payload []byte, header http.Header) error {
+	if b.provider == nil {
+		return resperror.NewError(resperror.BillingNotConfiguredError)
+	}
+	if err := b.provider.VerifySignature(payload, header, b.webhookSecret); err != nil {
+		b.logger.WithField("err", err).Error("Webhook signature verification failed")
+		return resperror.NewError(resperror.InvalidWebhookSignatureError)
+	}
+	event, err := b.provider.ParseEvent(payload)
+	if err != nil {
+		b.logger.WithField("err", err).Error("Failed to parse webhook payload")
+		return resperror.NewError(resperror.InvalidWebhookPayloadError)
+	}
+	if event == nil {
+		b.logger.Info("Ignoring unhandled webhook event")
+		return nil
+	}
+	return b.processEvent(ctx, event)
+}
+
+func (b *BillingService) processEvent(ctx context.Context, event *Event) error {
+	logger := b.logger.WithFields(logrus.Fields{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+		"user_uuid":  event.UserUuid,
+	})
+
+	var u user.User
+	err := b.db.WithContext(ctx).Where("uuid = ?", event.UserUuid).First(&u).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Acknowledge event so that provider does not keep retrying
+			logger.Error("User of webhook event not found")
+			return nil
+		}
+		return err
+	}
+
+	processed := false
+	err = b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Skip event if it has already been processed. Concurrent deliveries of the same event
+		// conflict on the unique index, so only one of them records and applies it
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&BillingEvent{
+			Provider:    b.provider.Name(),
+			EventID:     event.ID,
+			Type:        event.Type,
+			UserID:      u.ID,
+			ProcessedAt: time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		applyEvent(&u, event)
+		err := tx.Model(&u).Select("account_type", "license_expiry").Updates(u).Error
+		if err != nil {
+			return err
+		}
+		if event.Type == PaymentFailed {
+			err = b.notificationService.Notify(ctx, tx, &u, notification.CategoryBilling, email.TemplatePaymentFailed,
+				map[string]string{"LicenseExpiry": u.LicenseExpiry.Format("2 January 2006")})
+			if err != nil {
+				return err
+			}
+		}
+		processed = true
+		return nil
+	})
+	if err != nil {
+		logger.WithField("err", err).Error("Failed to process webhook event")
+		return err
+	}
+	if !processed {
+		logger.Info("Webhook event already processed")
+		return nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"account_type":   u.AccountType,
+		"license_expiry": u.LicenseExpiry,
+	}).Info("Updated license from webhook event")
+	// Cached sessions hold a copy of the user, which is now outdated
+	b.sessionService.InvalidateUserSessionCache(ctx, u.ID)
+	return nil
+}
+
+// Apply subscription event onto user's account type and license expiry
+func applyEvent(u *user.User, event *Event) {
+	now := time.Now()
+	switch event.Type {
+	case SubscriptionCreated, SubscriptionRenewed:
+		u.AccountType = event.Plan
+		if !event.PeriodEnd.IsZero() {
+			u.LicenseExpiry = event.PeriodEnd
+		}
+	case SubscriptionCancelled:
+		// License remains valid until end of the paid period
+		expiry := now
+		if !event.PeriodEnd.IsZero() {
+			expiry = event.PeriodEnd
+		}
+		if expiry.Before(u.LicenseExpiry) {
+			u.LicenseExpiry = expiry
+		}
+	case PaymentFailed:
+		expiry := now.Add(time.Hour * 24 * paymentFailedGraceDays)
+		if expiry.Before(u.LicenseExpiry) {
+			u.LicenseExpiry = expiry
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package billing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/lib/dbtest"
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/notification"
+	"github.com/dominiclet/golang-base/service/outbox"
+	"github.com/dominiclet/golang-base/service/session"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+func newTestBillingService(t *testing.T) (*BillingService, *gorm.DB) {
+	db := dbtest.Open(t, &user.User{}, &BillingEvent{}, &session.Session{},
+		&outbox.OutboxMessage{}, &notification.NotificationPreference{})
+	conf := &config.Config{
+		Domain: "example.com",
+		Email: config.Email{
+			Transport:    email.TransportMemory,
+			EmailAddress: "noreply@example.com",
+			// Keep queued messages in the DB, so that tests can check them
+			Outbox: config.EmailOutbox{Workers: 1, PollIntervalSeconds: 3600},
+		},
+		Billing:  config.Billing{Provider: FakeProviderName, WebhookSecret: testWebhookSecret},
+		Security: config.Security{LinkSigningKey: base64.StdEncoding.EncodeToString(make([]byte, 32))},
+	}
+	envVars := &env.EnvVars{}
+	emailService := email.InitEmailService(conf, envVars, nil)
+	outboxService := outbox.InitOutboxService(db, emailService, conf)
+	notificationService := notification.InitNotificationService(db, emailService, outboxService, conf, envVars)
+	userService := user.InitUserService(db, emailService, outboxService, nil, nil)
+	sessionService := session.InitSessionService(userService, nil, emailService, outboxService, nil, conf, db)
+	return InitBillingService(db, conf, sessionService, notificationService), db
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, licenseExpiry time.Time) *user.User {
+	u := &user.User{
+		Name:          "Test",
+		Uuid:          uuid.NewString(),
+		Email:         "test@example.com",
+		AccountType:   user.TrialAccount,
+		IsVerified:    true,
+		LicenseExpiry: licenseExpiry,
+	}
+	if err := db.Create(u).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return u
+}
+
+func sendEvent(b *BillingService, payload FakeEventPayload) error {
+	body, _ := json.Marshal(payload)
+	header := (&FakeProvider{}).Sign(body, testWebhookSecret)
+	return b.HandleWebhook(context.Background(), body, header)
+}
+
+func reloadUser(t *testing.T, db *gorm.DB, u *user.User) *user.User {
+	var reloaded user.User
+	if err := db.First(&reloaded, u.ID).Error; err != nil {
+		t.Fatalf("Failed to reload user: %v", err)
+	}
+	return &reloaded
+}
+
+func countRows(t *testing.T, db *gorm.DB, model interface{}) int64 {
+	var count int64
+	if err := db.Model(model).Count(&count).Error; err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return count
+}
+
+func TestHandleWebhookRejectsInvalidSignature(t *testing.T) {
+	b, db := newTestBillingService(t)
+	u := createTestUser(t, db, time.Now())
+
+	body, _ := json.Marshal(FakeEventPayload{ID: "evt_1", Type: fakeEventCreated, UserUuid: u.Uuid, Plan: fakePlanBasic})
+	for name, header := range map[string]map[string][]string{
+		"missing":       {},
+		"wrong secret":  (&FakeProvider{}).Sign(body, "wrong-secret"),
+		"other payload": (&FakeProvider{}).Sign([]byte("{}"), testWebhookSecret),
+	} {
+		err := b.HandleWebhook(context.Background(), body, header)
+		if !resperror.HasCode(err, resperror.InvalidWebhookSignatureError) {
+			t.Errorf("%s signature: got error %v, want InvalidWebhookSignatureError", name, err)
+		}
+	}
+	if reloadUser(t, db, u).AccountType != user.TrialAccount {
+		t.Error("Account type changed by event with invalid signature")
+	}
+	if countRows(t, db, &BillingEvent{}) != 0 {
+		t.Error("Event with invalid signature was recorded")
+	}
+}
+
+func TestHandleWebhookEvents(t *testing.T) {
+	periodEnd := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name          string
+		eventType     string
+		plan          string
+		periodEnd     time.Time
+		licenseExpiry time.Time // Of user before event
+		wantType      user.AccountType
+		wantExpiry    time.Time
+		wantEmails    int64
+	}{
+		{
+			name:          "subscription created",
+			eventType:     fakeEventCreated,
+			plan:          fakePlanBasic,
+			periodEnd:     periodEnd,
+			licenseExpiry: time.Now(),
+			wantType:      user.BasicAccount,
+			wantExpiry:    periodEnd,
+		},
+		{
+			name:          "subscription renewed",
+			eventType:     fakeEventRenewed,
+			plan:          fakePlanBasic,
+			periodEnd:     periodEnd,
+			licenseExpiry: time.Now().Add(24 * time.Hour),
+			wantType:      user.BasicAccount,
+			wantExpiry:    periodEnd,
+		},
+		{
+			name:          "subscription cancelled",
+			eventType:     fakeEventCancelled,
+			periodEnd:     periodEnd,
+			licenseExpiry: periodEnd.Add(365 * 24 * time.Hour),
+			wantType:      user.TrialAccount,
+			wantExpiry:    periodEnd,
+		},
+		{
+			name:          "payment failed",
+			eventType:     fakeEventPaymentFailed,
+			licenseExpiry: periodEnd,
+			wantType:      user.TrialAccount,
+			wantExpiry:    time.Now().Add(paymentFailedGraceDays * 24 * time.Hour),
+			wantEmails:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, db := newTestBillingService(t)
+			u := createTestUser(t, db, tt.licenseExpiry)
+
+			payload := FakeEventPayload{ID: "evt_1", Type: tt.eventType, UserUuid: u.Uuid, Plan: tt.plan}
+			if !tt.periodEnd.IsZero() {
+				payload.PeriodEnd = tt.periodEnd.Unix()
+			}
+			if err := sendEvent(b, payload); err != nil {
+				t.Fatalf("HandleWebhook failed: %v", err)
+			}
+
+			got := reloadUser(t, db, u)
+			if got.AccountType != tt.wantType {
+				t.Errorf("Account type is %s, want %s", got.AccountType, tt.wantType)
+			}
+			if diff := got.LicenseExpiry.Sub(tt.wantExpiry); diff < -time.Minute || diff > time.Minute {
+				t.Errorf("License expiry is %v, want %v", got.LicenseExpiry, tt.wantExpiry)
+			}
+			if count := countRows(t, db, &outbox.OutboxMessage{}); count != tt.wantEmails {
+				t.Errorf("%d emails queued, want %d", count, tt.wantEmails)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookIgnoresDuplicateDelivery(t *testing.T) {
+	b, db := newTestBillingService(t)
+	u := createTestUser(t, db, time.Now().Add(30*24*time.Hour))
+
+	payload := FakeEventPayload{ID: "evt_1", Type: fakeEventPaymentFailed, UserUuid: u.Uuid}
+	for i := 0; i < 2; i++ {
+		if err := sendEvent(b, payload); err != nil {
+			t.Fatalf("Delivery %d failed: %v", i+1, err)
+		}
+	}
+
+	// Deliveries at the same time are also only applied once
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = sendEvent(b, FakeEventPayload{ID: "evt_2", Type: fakeEventPaymentFailed, UserUuid: u.Uuid})
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent delivery %d failed: %v", i+1, err)
+		}
+	}
+
+	if count := countRows(t, db, &BillingEvent{}); count != 2 {
+		t.Errorf("%d events recorded, want 2", count)
+	}
+	if count := countRows(t, db, &outbox.OutboxMessage{}); count != 2 {
+		t.Errorf("%d payment failed emails queued, want 2", count)
+	}
+}
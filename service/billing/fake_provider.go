@@ -0,0 +1,90 @@
+package billing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dominiclet/golang-base/service/user"
+)
+
+const (
+	FakeProviderName       = "fake"
+	FakeSignatureHeader    = "X-Fake-Signature"
+	fakePlanBasic          = "basic"
+	fakePlanTrial          = "trial"
+	fakeEventCreated       = "subscription_created"
+	fakeEventRenewed       = "subscription_renewed"
+	fakeEventCancelled     = "subscription_cancelled"
+	fakeEventPaymentFailed = "payment_failed"
+)
+
+// FakeProvider is a local payment provider for development and testing without a live service.
+// Payloads are signed with a hex-encoded HMAC-SHA256 in the X-Fake-Signature header
+type FakeProvider struct{}
+
+type FakeEventPayload struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	UserUuid  string `json:"user_uuid"`
+	Plan      string `json:"plan"`
+	PeriodEnd int64  `json:"period_end"` // Unix time
+}
+
+func (f *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (f *FakeProvider) VerifySignature(payload []byte, header http.Header, secret string) error {
+	return VerifyHMAC(payload, header.Get(FakeSignatureHeader), secret)
+}
+
+func (f *FakeProvider) ParseEvent(payload []byte) (*Event, error) {
+	var fakeEvent FakeEventPayload
+	if err := json.Unmarshal(payload, &fakeEvent); err != nil {
+		return nil, err
+	}
+	if fakeEvent.ID == "" || fakeEvent.UserUuid == "" {
+		return nil, errors.New("Missing event ID or user UUID")
+	}
+
+	event := &Event{
+		ID:       fakeEvent.ID,
+		UserUuid: fakeEvent.UserUuid,
+	}
+	if fakeEvent.PeriodEnd != 0 {
+		event.PeriodEnd = time.Unix(fakeEvent.PeriodEnd, 0)
+	}
+	switch fakeEvent.Type {
+	case fakeEventCreated:
+		event.Type = SubscriptionCreated
+	case fakeEventRenewed:
+		event.Type = SubscriptionRenewed
+	case fakeEventCancelled:
+		event.Type = SubscriptionCancelled
+	case fakeEventPaymentFailed:
+		event.Type = PaymentFailed
+	default:
+		// Event type is not handled
+		return nil, nil
+	}
+	if event.Type == SubscriptionCreated || event.Type == SubscriptionRenewed {
+		switch fakeEvent.Plan {
+		case fakePlanBasic:
+			event.Plan = user.BasicAccount
+		case fakePlanTrial:
+			event.Plan = user.TrialAccount
+		default:
+			return nil, errors.New("Unknown plan")
+		}
+	}
+	return event, nil
+}
+
+// Sign payload the way the fake provider would (for generating webhook requests locally)
+func (f *FakeProvider) Sign(payload []byte, secret string) http.Header {
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, SignHMAC(payload, secret))
+	return header
+}
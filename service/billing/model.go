@@ -0,0 +1,40 @@
+package billing
+
+import (
+	"time"
+
+	"github.com/dominiclet/golang-base/service/user"
+)
+
+type EventType string
+
+// Subscription events that are mapped onto user licenses
+const (
+	SubscriptionCreated   EventType = "subscription.created"
+	SubscriptionRenewed   EventType = "subscription.renewed"
+	SubscriptionCancelled EventType = "subscription.cancelled"
+	PaymentFailed         EventType = "payment.failed"
+)
+
+const (
+	paymentFailedGraceDays = 3 // No. of days license stays valid after a failed payment
+)
+
+// Event is a provider-agnostic subscription event parsed from a webhook payload
+type Event struct {
+	ID        string // Unique ID of event assigned by provider (used for deduplication)
+	Type      EventType
+	UserUuid  string           // UUID of user the subscription belongs to
+	Plan      user.AccountType // Plan subscribed to (only for created/renewed events)
+	PeriodEnd time.Time        // End of current billing period (zero if not given)
+}
+
+// BillingEvent records webhook events that have been processed
+type BillingEvent struct {
+	ID          uint   `gorm:"primarykey"`
+	Provider    string `gorm:"uniqueIndex:idx_billing_event;size:63"`
+	EventID     string `gorm:"uniqueIndex:idx_billing_event;size:255"`
+	Type        EventType
+	UserID      uint
+	ProcessedAt time.Time
+}
@@ -0,0 +1,56 @@
+package billing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Provider parses webhooks of a payment provider
+type Provider interface {
+	// Name of provider as used in config
+	Name() string
+	// Verify that the payload was signed by the provider using the webhook secret
+	VerifySignature(payload []byte, header http.Header, secret string) error
+	// Parse webhook payload into event. Returns a nil event for events that are not handled
+	ParseEvent(payload []byte) (*Event, error)
+}
+
+var ErrInvalidSignature = errors.New("Invalid webhook signature")
+
+// Maps provider names to constructors of providers
+var providers = map[string]func() Provider{
+	FakeProviderName: func() Provider { return &FakeProvider{} },
+}
+
+func NewProvider(name string) (Provider, error) {
+	newProvider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown billing provider: %s", name)
+	}
+	return newProvider(), nil
+}
+
+// Compute hex-encoded HMAC-SHA256 of payload
+func SignHMAC(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify hex-encoded HMAC-SHA256 signature of payload in constant time
+func VerifyHMAC(payload []byte, signature string, secret string) error {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(decoded, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
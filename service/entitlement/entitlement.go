@@ -0,0 +1,56 @@
+package entitlement
+
+import (
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+)
+
+type EntitlementService struct {
+	userService *user.UserService
+	logger      *logrus.Entry
+}
+
+func InitEntitlementService(userService *user.UserService) *EntitlementService {
+	return &EntitlementService{
+		userService: userService,
+		logger:      logger.GetLogger().WithField("module", "entitlement_service"),
+	}
+}
+
+// Get the entitlements of a user based on their plan
+// Users with an invalid license are not entitled to any feature
+func (e *EntitlementService) GetEntitlements(u *user.User) Entitlements {
+	entitlements := Entitlements{
+		Plan:     u.AccountType,
+		Features: make(map[Feature]bool),
+		Limits:   make(map[Limit]int64),
+	}
+	planEntitlements, ok := Registry[u.AccountType]
+	if !ok {
+		e.logger.WithField("account_type", u.AccountType).Warn("No entitlements registered for plan")
+		return entitlements
+	}
+	licenseValid := e.userService.CheckLicenseValid(u)
+	for feature, enabled := range planEntitlements.Features {
+		entitlements.Features[feature] = enabled && licenseValid
+	}
+	for limit, value := range planEntitlements.Limits {
+		if !licenseValid {
+			value = 0
+		}
+		entitlements.Limits[limit] = value
+	}
+	return entitlements
+}
+
+// Check if user has access to a feature
+func (e *EntitlementService) HasFeature(u *user.User, feature Feature) bool {
+	return e.GetEntitlements(u).Features[feature]
+}
+
+// Get numeric limit of user. Returns Unlimited only if the plan sets the limit to Unlimited,
+// and 0 if the plan does not list the limit or the license is invalid
+func (e *EntitlementService) GetLimit(u *user.User, limit Limit) int64 {
+	return e.GetEntitlements(u).Limits[limit]
+}
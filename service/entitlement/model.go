@@ -0,0 +1,62 @@
+package entitlement
+
+import "github.com/dominiclet/golang-base/service/user"
+
+type Feature string
+
+// Features that can be gated per plan
+const (
+	FeatureExport    Feature = "export"
+	FeatureAPIAccess Feature = "api_access"
+)
+
+type Limit string
+
+// Numeric limits that can be set per plan
+const (
+	LimitAPICallsPerMonth Limit = "api_calls_per_month"
+	LimitExportsPerMonth  Limit = "exports_per_month"
+)
+
+const Unlimited int64 = -1 // Limit value indicating that there is no limit
+
+type Entitlements struct {
+	Plan     user.AccountType
+	Features map[Feature]bool
+	Limits   map[Limit]int64
+}
+
+// Maps each plan (account type) to what it is entitled to
+// Features and limits not listed for a plan are treated as disabled/zero
+var Registry = map[user.AccountType]Entitlements{
+	user.TrialAccount: {
+		Features: map[Feature]bool{
+			FeatureExport:    false,
+			FeatureAPIAccess: true,
+		},
+		Limits: map[Limit]int64{
+			LimitAPICallsPerMonth: 1000,
+			LimitExportsPerMonth:  0,
+		},
+	},
+	user.BasicAccount: {
+		Features: map[Feature]bool{
+			FeatureExport:    true,
+			FeatureAPIAccess: true,
+		},
+		Limits: map[Limit]int64{
+			LimitAPICallsPerMonth: 100000,
+			LimitExportsPerMonth:  100,
+		},
+	},
+	user.TestAccount: {
+		Features: map[Feature]bool{
+			FeatureExport:    true,
+			FeatureAPIAccess: true,
+		},
+		Limits: map[Limit]int64{
+			LimitAPICallsPerMonth: Unlimited,
+			LimitExportsPerMonth:  Unlimited,
+		},
+	},
+}
@@ -0,0 +1,233 @@
+package identity
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/oidc"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"github.com/dominiclet/golang-base/service/session"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type IdentityService struct {
+	db             *gorm.DB
+	userService    *user.UserService
+	sessionService *session.SessionService
+	providers      map[string]*oidc.Provider // Maps provider names to clients
+	logger         *logrus.Entry
+}
+
+func InitIdentityService(db *gorm.DB, config *config.Config, env *env.EnvVars,
+	userService *user.UserService, sessionService *session.SessionService) *IdentityService {
+	providers := make(map[string]*oidc.Provider)
+	for _, providerConf := range config.OIDCProviders {
+		redirectURL := fmt.Sprintf("%s://%s/api/session/oidc/%s/callback",
+			env.GetHttpProtocol(), config.Domain, providerConf.Name)
+		providers[providerConf.Name] = oidc.NewProvider(oidc.Config{
+			Issuer:       providerConf.Issuer,
+			ClientID:     providerConf.ClientID,
+			ClientSecret: providerConf.ClientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       providerConf.Scopes,
+		}, nil)
+	}
+	return &IdentityService{
+		db:             db,
+		userService:    userService,
+		sessionService: sessionService,
+		providers:      providers,
+		logger:         logger.GetLogger().WithField("module", "identity_service"),
+	}
+}
+
+// Get names of configured providers
+func (i *IdentityService) GetProviderNames() []string {
+	names := make([]string, 0, len(i.providers))
+	for name := range i.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Starts login at provider. Returns the URL to redirect the user to, and the state
+// which must be stored in the user's browser and passed back to CompleteLogin
+func (i *IdentityService) StartLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := i.providers[providerName]
+	if !ok {
+		return "", "", resperror.NewError(resperror.UnknownIdentityProviderError)
+	}
+
+	state, err := oidc.GenerateRandomValue()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.GenerateRandomValue()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := oidc.GenerateCodeVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallengeS256(codeVerifier))
+	if err != nil {
+		i.logger.WithFields(logrus.Fields{
+			"err":      err,
+			"provider": providerName,
+		}).Error("Failed to build authorization URL")
+		return "", "", err
+	}
+
+	err = i.db.WithContext(ctx).Create(&OIDCAuthRequest{
+		Provider:     providerName,
+		StateHash:    tokenhash.Hash(state),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(time.Minute * authRequestValidity),
+	}).Error
+	if err != nil {
+		i.logger.WithField("err", err).Error("Failed to store auth request")
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// Completes login after provider redirects back with an authorization code.
+// browserState is the state stored in the user's browser by StartLogin, which must match the returned state
+func (i *IdentityService) CompleteLogin(ctx context.Context, providerName string, state string,
+	browserState string, code string) (*session.LoginResult, error) {
+	provider, ok := i.providers[providerName]
+	if !ok {
+		return nil, resperror.NewError(resperror.UnknownIdentityProviderError)
+	}
+	logger := i.logger.WithField("provider", providerName)
+
+	// State must match the one stored in browser to prevent login CSRF
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		logger.Error("OIDC state mismatch")
+		return nil, resperror.NewError(resperror.IdentityProviderLoginError)
+	}
+	authRequest, err := i.consumeAuthRequest(ctx, providerName, state)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenResp, err := provider.Exchange(ctx, code, authRequest.CodeVerifier)
+	if err != nil {
+		logger.WithField("err", err).Error("Failed to exchange authorization code")
+		return nil, resperror.NewError(resperror.IdentityProviderLoginError)
+	}
+	claims, err := provider.VerifyIDToken(ctx, tokenResp.IDToken, authRequest.Nonce)
+	if err != nil {
+		logger.WithField("err", err).Error("Failed to verify ID token")
+		return nil, resperror.NewError(resperror.IdentityProviderLoginError)
+	}
+
+	u, err := i.findOrCreateUser(ctx, providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+	return i.sessionService.LoginUser(ctx, u)
+}
+
+// Find user linked to external identity. If there is none, link the identity to the user with the same email,
+// or create a new user. Linking takes over the account with the email, so it is only done if the provider
+// asserts with the email_verified claim that the email belongs to the user
+func (i *IdentityService) findOrCreateUser(ctx context.Context, providerName string, claims *oidc.IDTokenClaims) (*user.User, error) {
+	logger := i.logger.WithFields(logrus.Fields{
+		"provider": providerName,
+		"subject":  claims.Subject,
+	})
+
+	var identity ExternalIdentity
+	err := i.db.WithContext(ctx).
+		Where("provider = ? AND subject = ?", providerName, claims.Subject).
+		First(&identity).Error
+	if err == nil {
+		return i.userService.GetUserById(ctx, identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Emails can only be trusted for linking if the provider has verified them
+	if claims.Email == "" || !bool(claims.EmailVerified) {
+		logger.Error("External identity has no verified email")
+		return nil, resperror.NewError(resperror.IdentityProviderEmailNotVerifiedError)
+	}
+
+	u, err := i.userService.GetUserByEmail(ctx, claims.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if u == nil {
+		name := claims.Name
+		if name == "" {
+			name = claims.Email
+		}
+		u, err = i.userService.CreateExternalUser(ctx, name, claims.Email)
+		if err != nil {
+			return nil, err
+		}
+	} else if !u.IsVerified {
+		// The unverified account may have been registered by someone else who does not own the email,
+		// so its password cannot be trusted once the owner of the email takes it over
+		logger.WithField("email", u.Email).Warn("Verifying unverified user and clearing password on identity link")
+		u.IsVerified = true
+		u.Password = ""
+		u.VerificationToken = ""
+		err = i.db.WithContext(ctx).Model(u).
+			Select("is_verified", "password", "verification_token").Updates(u).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = i.db.WithContext(ctx).Create(&ExternalIdentity{
+		UserID:   u.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}).Error
+	if err != nil {
+		logger.WithField("err", err).Error("Failed to link external identity")
+		return nil, err
+	}
+	logger.WithField("user_uuid", u.Uuid).Info("Linked external identity to user")
+	return u, nil
+}
+
+// Retrieve and delete auth request with state so that it can only be used once
+func (i *IdentityService) consumeAuthRequest(ctx context.Context, providerName string, state string) (*OIDCAuthRequest, error) {
+	var authRequest OIDCAuthRequest
+	err := i.db.WithContext(ctx).
+		Where("provider = ? AND state_hash = ?", providerName, tokenhash.Hash(state)).
+		First(&authRequest).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resperror.NewError(resperror.IdentityProviderLoginError)
+		}
+		return nil, err
+	}
+	result := i.db.WithContext(ctx).Delete(&authRequest)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(authRequest.ExpiresAt) {
+		return nil, resperror.NewError(resperror.IdentityProviderLoginError)
+	}
+	return &authRequest, nil
+}
@@ -0,0 +1,199 @@
+package identity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/lib/dbtest"
+	"github.com/dominiclet/golang-base/lib/oidc/oidctest"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/session"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const testProvider = "test"
+
+var testIdentity = oidctest.User{
+	Subject:       "subject-1",
+	Email:         "user@example.com",
+	EmailVerified: true,
+	Name:          "User",
+}
+
+func newTestIdentityService(t *testing.T) (*IdentityService, *oidctest.Server, *gorm.DB) {
+	server := oidctest.NewServer("client", "secret", testIdentity)
+	t.Cleanup(server.Close)
+
+	db := dbtest.Open(t, &user.User{}, &session.Session{}, &ExternalIdentity{}, &OIDCAuthRequest{})
+	conf := &config.Config{
+		Domain: "app.example.com",
+		OIDCProviders: []config.OIDCProvider{{
+			Name:         testProvider,
+			Issuer:       server.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+		}},
+	}
+	userService := user.InitUserService(db, nil, nil, nil, nil)
+	sessionService := session.InitSessionService(userService, nil, nil, nil, nil, conf, db)
+	return InitIdentityService(db, conf, &env.EnvVars{}, userService, sessionService), server, db
+}
+
+// Logs in at stub provider, returning the state returned by the provider, the state stored in
+// the browser and the authorization code
+func authorize(t *testing.T, i *IdentityService, server *oidctest.Server) (string, string, string) {
+	t.Helper()
+	authURL, browserState, err := i.StartLogin(context.Background(), testProvider)
+	if err != nil {
+		t.Fatalf("StartLogin failed: %v", err)
+	}
+	redirect, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorization failed: %v", err)
+	}
+	return redirect.Query().Get("state"), browserState, redirect.Query().Get("code")
+}
+
+func login(t *testing.T, i *IdentityService, server *oidctest.Server) (*session.LoginResult, error) {
+	t.Helper()
+	state, browserState, code := authorize(t, i, server)
+	return i.CompleteLogin(context.Background(), testProvider, state, browserState, code)
+}
+
+func createLocalUser(t *testing.T, db *gorm.DB, verified bool) *user.User {
+	u := &user.User{
+		Name:          "Local",
+		Uuid:          uuid.NewString(),
+		Email:         testIdentity.Email,
+		Password:      "password-hash",
+		AccountType:   user.TestAccount,
+		IsVerified:    verified,
+		LicenseExpiry: time.Now().Add(24 * time.Hour),
+	}
+	if err := db.Create(u).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return u
+}
+
+func TestAuthRequestTableName(t *testing.T) {
+	_, _, db := newTestIdentityService(t)
+	if !db.Migrator().HasTable("oidc_auth_requests") {
+		t.Error("Auth requests are not stored in table oidc_auth_requests")
+	}
+}
+
+func TestLoginCreatesUser(t *testing.T) {
+	i, server, db := newTestIdentityService(t)
+
+	result, err := login(t, i, server)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if result.Token == "" || result.User.Email != testIdentity.Email || !result.User.IsVerified {
+		t.Errorf("Unexpected login result %+v", result)
+	}
+
+	// Identity stays linked to the user when its email changes
+	server.SetUser(oidctest.User{Subject: testIdentity.Subject, Email: "changed@example.com", EmailVerified: true})
+	result2, err := login(t, i, server)
+	if err != nil {
+		t.Fatalf("Second login failed: %v", err)
+	}
+	if result2.User.ID != result.User.ID {
+		t.Errorf("Second login is of user %d, want %d", result2.User.ID, result.User.ID)
+	}
+	var count int64
+	db.Model(&user.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d users created, want 1", count)
+	}
+}
+
+func TestLoginLinksVerifiedUser(t *testing.T) {
+	i, server, db := newTestIdentityService(t)
+	local := createLocalUser(t, db, true)
+
+	result, err := login(t, i, server)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if result.User.ID != local.ID {
+		t.Errorf("Logged in as user %d, want %d", result.User.ID, local.ID)
+	}
+	var identity ExternalIdentity
+	if err := db.Where("provider = ? AND subject = ?", testProvider, testIdentity.Subject).First(&identity).Error; err != nil {
+		t.Fatalf("Identity not linked: %v", err)
+	}
+	if identity.UserID != local.ID {
+		t.Errorf("Identity linked to user %d, want %d", identity.UserID, local.ID)
+	}
+	var got user.User
+	db.First(&got, local.ID)
+	if got.Password != local.Password {
+		t.Error("Password of verified user was changed")
+	}
+}
+
+func TestLoginTakesOverUnverifiedUser(t *testing.T) {
+	i, server, db := newTestIdentityService(t)
+	local := createLocalUser(t, db, false)
+
+	if _, err := login(t, i, server); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	var got user.User
+	db.First(&got, local.ID)
+	if !got.IsVerified || got.Password != "" {
+		t.Errorf("Unverified user was not verified with password cleared: %+v", got)
+	}
+}
+
+func TestLoginRejectsUnverifiedEmail(t *testing.T) {
+	i, server, db := newTestIdentityService(t)
+	local := createLocalUser(t, db, true)
+	server.SetUser(oidctest.User{Subject: testIdentity.Subject, Email: testIdentity.Email, EmailVerified: false})
+
+	_, err := login(t, i, server)
+	if !resperror.HasCode(err, resperror.IdentityProviderEmailNotVerifiedError) {
+		t.Fatalf("Got error %v, want IdentityProviderEmailNotVerifiedError", err)
+	}
+	var count int64
+	db.Model(&ExternalIdentity{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Identity with unverified email was linked to user %d", local.ID)
+	}
+}
+
+func TestLoginRejectsInvalidState(t *testing.T) {
+	i, server, _ := newTestIdentityService(t)
+	ctx := context.Background()
+
+	// State returned by provider does not match the state in the browser
+	state, _, code := authorize(t, i, server)
+	_, err := i.CompleteLogin(ctx, testProvider, state, "other-state", code)
+	if !resperror.HasCode(err, resperror.IdentityProviderLoginError) {
+		t.Errorf("Mismatched state: got error %v, want IdentityProviderLoginError", err)
+	}
+
+	// State can only be used once
+	state, browserState, code := authorize(t, i, server)
+	if _, err := i.CompleteLogin(ctx, testProvider, state, browserState, code); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	_, err = i.CompleteLogin(ctx, testProvider, state, browserState, code)
+	if !resperror.HasCode(err, resperror.IdentityProviderLoginError) {
+		t.Errorf("Reused state: got error %v, want IdentityProviderLoginError", err)
+	}
+
+	// State of an unknown login
+	_, err = i.CompleteLogin(ctx, testProvider, "unknown", "unknown", code)
+	if !resperror.HasCode(err, resperror.IdentityProviderLoginError) {
+		t.Errorf("Unknown state: got error %v, want IdentityProviderLoginError", err)
+	}
+}
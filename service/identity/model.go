@@ -0,0 +1,33 @@
+package identity
+
+import "time"
+
+const (
+	authRequestValidity = 10 // No. of minutes user has to complete login at provider
+)
+
+// ExternalIdentity links an account at an external identity provider to a user
+type ExternalIdentity struct {
+	ID        uint `gorm:"primarykey"`
+	UserID    uint
+	Provider  string `gorm:"uniqueIndex:idx_external_identity;size:63"`
+	Subject   string `gorm:"uniqueIndex:idx_external_identity;size:255"`
+	Email     string
+	CreatedAt time.Time
+}
+
+// OIDCAuthRequest holds the state of a login at an external provider until the provider redirects back
+type OIDCAuthRequest struct {
+	ID           uint `gorm:"primarykey"`
+	Provider     string
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+// GORM would split the initialism and name the table o_id_c_auth_requests
+func (OIDCAuthRequest) TableName() string {
+	return "oidc_auth_requests"
+}
@@ -0,0 +1,218 @@
+package metering
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MeteringService struct {
+	db                 *gorm.DB
+	entitlementService *entitlement.EntitlementService
+	logger             *logrus.Entry
+
+	mu sync.Mutex
+	// counts holds the known usage count (persisted + pending) of each counter in the current bucket
+	counts map[counterKey]int64
+	// pending holds usage increments that have not been written to DB. Counters with pending
+	// increments (including those being flushed) always have a known count
+	pending map[counterKey]int64
+	// flushes is the number of completed flushes, so that counts read from DB while a flush was
+	// being written are discarded
+	flushes uint64
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func InitMeteringService(db *gorm.DB, entitlementService *entitlement.EntitlementService) *MeteringService {
+	m := &MeteringService{
+		db:                 db,
+		entitlementService: entitlementService,
+		logger:             logger.GetLogger().WithField("module", "metering_service"),
+		counts:             make(map[counterKey]int64),
+		pending:            make(map[counterKey]int64),
+		stop:               make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+	// Periodically write buffered usage to DB
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Flush()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+	return m
+}
+
+// Stops periodic flushing and writes pending usage to DB (to be called on shutdown)
+func (m *MeteringService) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		<-m.done
+		m.Flush()
+	})
+}
+
+// Records one usage of metric for the user if it is within the user's quota.
+// Returns the usage after recording. If quota is exceeded, usage is not recorded
+// and a TooManyRequests-style error is returned together with the current usage (and its reset time)
+func (m *MeteringService) ConsumeQuota(ctx context.Context, u *user.User, metric Metric) (*Usage, error) {
+	now := time.Now()
+	start := bucketStart(now)
+	key := counterKey{userID: u.ID, metric: metric, bucketStart: start.Unix()}
+	limit := m.getLimit(u, metric)
+
+	// Count is checked and incremented while holding the lock, so that a flush cannot drop it in between
+	if err := m.lockCount(ctx, key, start); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+	usage := &Usage{
+		Metric:  metric,
+		Used:    m.counts[key],
+		Limit:   limit,
+		ResetAt: bucketEnd(start),
+	}
+	if limit != entitlement.Unlimited && usage.Used >= limit {
+		m.logger.WithFields(logrus.Fields{
+			"user_uuid": u.Uuid,
+			"metric":    metric,
+			"limit":     limit,
+		}).Info("Usage quota exceeded")
+		return usage, resperror.NewError(resperror.QuotaExceededError)
+	}
+	m.counts[key]++
+	m.pending[key]++
+	usage.Used++
+	return usage, nil
+}
+
+// Get usage of all metrics of user in the current bucket
+func (m *MeteringService) GetUsageSummary(ctx context.Context, u *user.User) ([]Usage, error) {
+	start := bucketStart(time.Now())
+	var summary []Usage
+	for metric := range metricLimits {
+		key := counterKey{userID: u.ID, metric: metric, bucketStart: start.Unix()}
+		if err := m.lockCount(ctx, key, start); err != nil {
+			return nil, err
+		}
+		used := m.counts[key]
+		m.mu.Unlock()
+		summary = append(summary, Usage{
+			Metric:  metric,
+			Used:    used,
+			Limit:   m.getLimit(u, metric),
+			ResetAt: bucketEnd(start),
+		})
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].Metric < summary[j].Metric
+	})
+	return summary, nil
+}
+
+// Write all pending usage increments to DB. Known counts of the written counters are then dropped,
+// so that they are reloaded from DB with the usage of other instances included. Instances can
+// together exceed a quota by the usage recorded by the other instances within one flush interval
+func (m *MeteringService) Flush() {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[counterKey]int64)
+	m.mu.Unlock()
+
+	written := make([]counterKey, 0, len(pending))
+	for key, increment := range pending {
+		counter := UsageCounter{
+			UserID:      key.userID,
+			Metric:      key.metric,
+			BucketStart: time.Unix(key.bucketStart, 0).UTC(),
+			Count:       increment,
+			UpdatedAt:   time.Now(),
+		}
+		err := m.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "metric"}, {Name: "bucket_start"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"count":      gorm.Expr("count + ?", increment),
+				"updated_at": counter.UpdatedAt,
+			}),
+		}).Create(&counter).Error
+		if err != nil {
+			m.logger.WithFields(logrus.Fields{
+				"err":     err,
+				"user_id": key.userID,
+				"metric":  key.metric,
+			}).Error("Failed to write usage to DB")
+			// Add back increment so that it is retried on the next flush (its count is kept)
+			m.mu.Lock()
+			m.pending[key] += increment
+			m.mu.Unlock()
+			continue
+		}
+		written = append(written, key)
+	}
+
+	m.mu.Lock()
+	for _, key := range written {
+		// Usage recorded since the increment was taken is still pending, so the count has to be kept
+		if _, ok := m.pending[key]; !ok {
+			delete(m.counts, key)
+		}
+	}
+	m.flushes++
+	m.mu.Unlock()
+}
+
+// Locks m.mu once the count of counter is known, loading the persisted count from DB if needed.
+// m.mu is held on success
+func (m *MeteringService) lockCount(ctx context.Context, key counterKey, start time.Time) error {
+	m.mu.Lock()
+	for {
+		if _, ok := m.counts[key]; ok {
+			return nil
+		}
+		flushes := m.flushes
+		m.mu.Unlock()
+
+		var counter UsageCounter
+		err := m.db.WithContext(ctx).
+			Where("user_id = ? AND metric = ? AND bucket_start = ?", key.userID, key.metric, start).
+			Limit(1).Find(&counter).Error
+		if err != nil {
+			m.logger.WithField("err", err).Error("Failed to query usage counter")
+			return err
+		}
+
+		m.mu.Lock()
+		// Count may have been read before a flush of the counter was committed. Another request
+		// may also have loaded the counter in the meantime
+		if _, ok := m.counts[key]; !ok && m.flushes == flushes {
+			m.counts[key] = counter.Count + m.pending[key]
+		}
+	}
+}
+
+func (m *MeteringService) getLimit(u *user.User, metric Metric) int64 {
+	limit, ok := metricLimits[metric]
+	if !ok {
+		return entitlement.Unlimited
+	}
+	return m.entitlementService.GetLimit(u, limit)
+}
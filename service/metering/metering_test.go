@@ -0,0 +1,134 @@
+package metering
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dominiclet/golang-base/lib/dbtest"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func newTestMeteringService(t *testing.T) (*MeteringService, *gorm.DB) {
+	db := dbtest.Open(t, &user.User{}, &UsageCounter{})
+	m := InitMeteringService(db, entitlement.InitEntitlementService(user.InitUserService(db, nil, nil, nil, nil)))
+	t.Cleanup(m.Close)
+	return m, db
+}
+
+func createTestUser(t *testing.T, db *gorm.DB) *user.User {
+	u := &user.User{
+		Name:          "Test",
+		Uuid:          uuid.NewString(),
+		Email:         "test@example.com",
+		AccountType:   user.TrialAccount,
+		IsVerified:    true,
+		LicenseExpiry: time.Now().Add(24 * time.Hour),
+	}
+	if err := db.Create(u).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return u
+}
+
+func storedCount(t *testing.T, db *gorm.DB, u *user.User, metric Metric) int64 {
+	var counter UsageCounter
+	err := db.Where("user_id = ? AND metric = ? AND bucket_start = ?", u.ID, metric, bucketStart(time.Now())).
+		Limit(1).Find(&counter).Error
+	if err != nil {
+		t.Fatalf("Failed to query usage counter: %v", err)
+	}
+	return counter.Count
+}
+
+func TestConsumeQuota(t *testing.T) {
+	m, db := newTestMeteringService(t)
+	u := createTestUser(t, db)
+	limit := entitlement.Registry[user.TrialAccount].Limits[entitlement.LimitAPICallsPerMonth]
+
+	for i := int64(1); i <= limit; i++ {
+		usage, err := m.ConsumeQuota(context.Background(), u, MetricAPICalls)
+		if err != nil {
+			t.Fatalf("Usage %d rejected: %v", i, err)
+		}
+		if usage.Used != i || usage.Limit != limit {
+			t.Fatalf("Usage %d is %d of %d", i, usage.Used, usage.Limit)
+		}
+		// Counts are reloaded from DB after flushing
+		if i%100 == 0 {
+			m.Flush()
+		}
+	}
+	usage, err := m.ConsumeQuota(context.Background(), u, MetricAPICalls)
+	if !resperror.HasCode(err, resperror.QuotaExceededError) {
+		t.Fatalf("Got error %v, want QuotaExceededError", err)
+	}
+	if usage.Remaining() != 0 || !usage.ResetAt.Equal(bucketEnd(bucketStart(time.Now()))) {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+
+	m.Flush()
+	if count := storedCount(t, db, u, MetricAPICalls); count != limit {
+		t.Errorf("Stored usage is %d, want %d", count, limit)
+	}
+}
+
+// Run with -race
+func TestConsumeQuotaWhileFlushing(t *testing.T) {
+	m, db := newTestMeteringService(t)
+	u := createTestUser(t, db)
+	limit := entitlement.Registry[user.TrialAccount].Limits[entitlement.LimitAPICallsPerMonth]
+
+	stop := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				m.Flush()
+			}
+		}
+	}()
+
+	const workers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var accepted int64
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Together more than the limit
+			for j := int64(0); j < limit/workers+10; j++ {
+				_, err := m.ConsumeQuota(context.Background(), u, MetricAPICalls)
+				if err == nil {
+					mu.Lock()
+					accepted++
+					mu.Unlock()
+				} else if !resperror.HasCode(err, resperror.QuotaExceededError) {
+					t.Errorf("ConsumeQuota failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-flushed
+	m.Flush()
+
+	if accepted != limit {
+		t.Errorf("%d usages accepted, want %d", accepted, limit)
+	}
+	if count := storedCount(t, db, u, MetricAPICalls); count != limit {
+		t.Errorf("Stored usage is %d, want %d", count, limit)
+	}
+}
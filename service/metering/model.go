@@ -0,0 +1,72 @@
+package metering
+
+import (
+	"time"
+
+	"github.com/dominiclet/golang-base/service/entitlement"
+)
+
+type Metric string
+
+// Usage metrics that are metered per user
+const (
+	MetricAPICalls Metric = "api_calls"
+	MetricExports  Metric = "exports"
+)
+
+// Maps each metric to the entitlement limit that caps its usage per bucket
+var metricLimits = map[Metric]entitlement.Limit{
+	MetricAPICalls: entitlement.LimitAPICallsPerMonth,
+	MetricExports:  entitlement.LimitExportsPerMonth,
+}
+
+const (
+	flushInterval = 10 * time.Second // Interval at which buffered usage is written to DB
+)
+
+// UsageCounter holds the usage count of a metric for a user within a time bucket (calendar month in UTC).
+// Usage is only counted per user, as plans (and so quotas) belong to users
+type UsageCounter struct {
+	ID          uint      `gorm:"primarykey"`
+	UserID      uint      `gorm:"uniqueIndex:idx_usage_counter"`
+	Metric      Metric    `gorm:"uniqueIndex:idx_usage_counter;size:63"`
+	BucketStart time.Time `gorm:"uniqueIndex:idx_usage_counter"`
+	Count       int64
+	UpdatedAt   time.Time
+}
+
+// Usage of a metric in the current bucket
+type Usage struct {
+	Metric  Metric
+	Used    int64
+	Limit   int64 // entitlement.Unlimited if there is no limit
+	ResetAt time.Time
+}
+
+// Get number of remaining usages (-1 if unlimited)
+func (u Usage) Remaining() int64 {
+	if u.Limit == entitlement.Unlimited {
+		return entitlement.Unlimited
+	}
+	if u.Used >= u.Limit {
+		return 0
+	}
+	return u.Limit - u.Used
+}
+
+type counterKey struct {
+	userID      uint
+	metric      Metric
+	bucketStart int64 // Unix time of start of bucket
+}
+
+// Get the start of the bucket containing t
+func bucketStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Get the time at which the bucket starting at start ends
+func bucketEnd(start time.Time) time.Time {
+	return start.AddDate(0, 1, 0)
+}
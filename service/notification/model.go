@@ -0,0 +1,24 @@
+package notification
+
+import "time"
+
+// Category of notification emails. Users can unsubscribe from each category except security
+type Category string
+
+const (
+	CategorySecurity Category = "security" // Eg. login alerts. Always sent
+	CategoryBilling  Category = "billing"  // Eg. failed payments and license expiry reminders
+	CategoryProduct  Category = "product"  // Eg. product updates and marketing
+)
+
+var Categories = []Category{CategorySecurity, CategoryBilling, CategoryProduct}
+
+// NotificationPreference records whether a user receives emails of a category.
+// Categories without a preference are enabled
+type NotificationPreference struct {
+	ID        uint     `gorm:"primarykey"`
+	UserID    uint     `gorm:"uniqueIndex:idx_notification_preference"`
+	Category  Category `gorm:"uniqueIndex:idx_notification_preference;size:31"`
+	Enabled   bool
+	UpdatedAt time.Time
+}
@@ -0,0 +1,183 @@
+package notification
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/outbox"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const minSigningKeyLength = 32
+
+// NotificationService sends notification emails according to the notification preferences of users
+type NotificationService struct {
+	db            *gorm.DB
+	emailService  *email.EmailService
+	outboxService *outbox.OutboxService
+	config        *config.Config
+	env           *env.EnvVars
+	logger        *logrus.Entry
+	signingKey    []byte // Key for signing unsubscribe links
+}
+
+func InitNotificationService(db *gorm.DB, emailService *email.EmailService, outboxService *outbox.OutboxService,
+	config *config.Config, env *env.EnvVars) *NotificationService {
+	n := &NotificationService{
+		db:            db,
+		emailService:  emailService,
+		outboxService: outboxService,
+		config:        config,
+		env:           env,
+		logger:        logger.GetLogger().WithField("module", "notification_service"),
+	}
+	if config.Security.LinkSigningKey != "" {
+		key, err := base64.StdEncoding.DecodeString(config.Security.LinkSigningKey)
+		if err != nil || len(key) < minSigningKeyLength {
+			panic("security.link_signing_key must be a base64-encoded key of at least 32 bytes")
+		}
+		n.signingKey = key
+	} else if env.IsDev() {
+		n.logger.Warn("security.link_signing_key not set, unsubscribe links stop working when the server restarts")
+		n.signingKey = make([]byte, minSigningKeyLength)
+		if _, err := rand.Read(n.signingKey); err != nil {
+			panic(err)
+		}
+	} else {
+		// Links signed with a random key stop working on restart and on other instances
+		panic("security.link_signing_key must be set outside dev mode")
+	}
+	return n
+}
+
+func IsValidCategory(category Category) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether user receives emails of each category
+func (n *NotificationService) GetPreferences(ctx context.Context, userID uint) (map[Category]bool, error) {
+	var prefs []NotificationPreference
+	if err := n.db.WithContext(ctx).Where("user_id = ?", userID).Find(&prefs).Error; err != nil {
+		n.logger.WithField("err", err).Error("Failed to query notification preferences")
+		return nil, err
+	}
+	enabled := make(map[Category]bool, len(Categories))
+	for _, category := range Categories {
+		enabled[category] = true
+	}
+	for _, pref := range prefs {
+		if pref.Category != CategorySecurity {
+			enabled[pref.Category] = pref.Enabled
+		}
+	}
+	return enabled, nil
+}
+
+// Enable or disable emails of category for user. Security emails cannot be disabled
+func (n *NotificationService) SetPreference(ctx context.Context, userID uint, category Category, enabled bool) error {
+	if !IsValidCategory(category) {
+		return resperror.NewError(resperror.BadRequest)
+	}
+	if category == CategorySecurity && !enabled {
+		return resperror.NewError(resperror.SecurityNotificationsRequiredError)
+	}
+	n.logger.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"category": category,
+		"enabled":  enabled,
+	}).Info("Setting notification preference")
+	err := n.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(&NotificationPreference{
+		UserID:   userID,
+		Category: category,
+		Enabled:  enabled,
+	}).Error
+	if err != nil {
+		n.logger.WithField("err", err).Error("Failed to store notification preference")
+	}
+	return err
+}
+
+// Queue notification email rendered from template unless the user disabled its category.
+// Emails other than security emails link to a signed unsubscribe link. tx should be the transaction
+// of the change that triggered the notification (if any)
+func (n *NotificationService) Notify(ctx context.Context, tx *gorm.DB, u *user.User, category Category,
+	template string, data interface{}) error {
+	logger := n.logger.WithFields(logrus.Fields{
+		"user_id":  u.ID,
+		"category": category,
+		"template": template,
+	})
+	unsubscribeURL := ""
+	if category != CategorySecurity {
+		var pref NotificationPreference
+		err := tx.WithContext(ctx).Where("user_id = ? AND category = ?", u.ID, category).First(&pref).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && !pref.Enabled {
+			logger.Info("Not sending notification disabled by user")
+			return nil
+		}
+		unsubscribeURL = n.unsubscribeURL(u.Uuid, category)
+	}
+
+	msg, err := n.emailService.NotificationEmail(u.Email, u.Language, template, data, unsubscribeURL)
+	if err != nil {
+		return err
+	}
+	logger.Info("Sending notification")
+	return n.outboxService.Enqueue(ctx, tx, msg)
+}
+
+// Disable category for user with the signed link from a notification email
+func (n *NotificationService) Unsubscribe(ctx context.Context, userUUID string, category Category, signature string) error {
+	if !IsValidCategory(category) || category == CategorySecurity ||
+		!hmac.Equal([]byte(signature), []byte(n.sign(userUUID, category))) {
+		return resperror.NewError(resperror.InvalidUnsubscribeLinkError)
+	}
+	var u user.User
+	if err := n.db.WithContext(ctx).Where("uuid = ?", userUUID).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resperror.NewError(resperror.InvalidUnsubscribeLinkError)
+		}
+		return err
+	}
+	return n.SetPreference(ctx, u.ID, category, false)
+}
+
+func (n *NotificationService) unsubscribeURL(userUUID string, category Category) string {
+	query := url.Values{}
+	query.Set("user", userUUID)
+	query.Set("category", string(category))
+	query.Set("sig", n.sign(userUUID, category))
+	return fmt.Sprintf("%s://%s/api/notifications/unsubscribe?%s",
+		n.env.GetHttpProtocol(), n.config.Domain, query.Encode())
+}
+
+func (n *NotificationService) sign(userUUID string, category Category) string {
+	mac := hmac.New(sha256.New, n.signingKey)
+	mac.Write([]byte("unsubscribe:" + userUUID + ":" + string(category)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,232 @@
+package oauth
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	randgenerate "github.com/dominiclet/golang-base/lib/rand_generate"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+)
+
+// Handles authorization request of logged in user. Issues an authorization code if the user
+// has already granted consent for the requested scopes, otherwise creates a consent request.
+// authTime is when the user logged in, which is reported to the client in the ID token.
+// Errors that cannot be returned to the client's redirect URI (unknown client or redirect URI) are returned as *Error
+func (o *OAuthService) Authorize(ctx context.Context, u *user.User, authTime time.Time,
+	req AuthorizeRequest) (*AuthorizeResult, error) {
+	client, err := o.getClient(ctx, req.ClientID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, newError(ErrInvalidClient, "Unknown client")
+		}
+		return nil, err
+	}
+	if !containsScope(client.RedirectURIs, req.RedirectURI) {
+		return nil, newError(ErrInvalidRequest, "Redirect URI is not registered for client")
+	}
+
+	// From here on, errors are returned to the client through the redirect URI
+	if req.ResponseType != "code" {
+		return o.errorRedirect(req, newError(ErrUnsupportedResponseType, "Only the code response type is supported")), nil
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != codeChallengeMethodS256 {
+		return o.errorRedirect(req, newError(ErrInvalidRequest, "PKCE with S256 code challenge is required")), nil
+	}
+	scopes, err := parseScopes(req.Scope)
+	if err != nil {
+		return o.errorRedirect(req, err.(*Error)), nil
+	}
+
+	if !client.SkipConsent {
+		consented, err := o.hasConsent(ctx, u.ID, client.ClientID, scopes)
+		if err != nil {
+			return nil, err
+		}
+		if !consented {
+			requestID, err := o.createConsentRequest(ctx, u.ID, authTime, req, scopes)
+			if err != nil {
+				return nil, err
+			}
+			return &AuthorizeResult{ConsentRequestID: requestID}, nil
+		}
+	}
+
+	redirectURL, err := o.issueAuthorizationCode(ctx, u.ID, authTime, req.ClientID, req.RedirectURI, scopes,
+		req.State, req.Nonce, req.CodeChallenge)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthorizeResult{RedirectURL: redirectURL}, nil
+}
+
+// Get pending consent request of user together with the client requesting consent
+func (o *OAuthService) GetConsentRequest(ctx context.Context, userID uint, requestID string) (*OAuthConsentRequest, *OAuthClient, error) {
+	var consentReq OAuthConsentRequest
+	err := o.db.WithContext(ctx).Where("request_id = ? AND user_id = ?", requestID, userID).First(&consentReq).Error
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, resperror.NewError(resperror.OAuthConsentRequestNotFoundError)
+		}
+		return nil, nil, err
+	}
+	if time.Now().After(consentReq.ExpiresAt) {
+		return nil, nil, resperror.NewError(resperror.OAuthConsentRequestNotFoundError)
+	}
+	client, err := o.getClient(ctx, consentReq.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &consentReq, client, nil
+}
+
+// Grant or deny consent request. Returns the URL of the client to redirect the user back to
+func (o *OAuthService) DecideConsent(ctx context.Context, userID uint, requestID string, approve bool) (string, error) {
+	consentReq, _, err := o.GetConsentRequest(ctx, userID, requestID)
+	if err != nil {
+		return "", err
+	}
+	if err := o.db.WithContext(ctx).Delete(consentReq).Error; err != nil {
+		return "", err
+	}
+	req := AuthorizeRequest{
+		ClientID:    consentReq.ClientID,
+		RedirectURI: consentReq.RedirectURI,
+		State:       consentReq.State,
+	}
+	if !approve {
+		return o.errorRedirect(req, newError(ErrAccessDenied, "User denied consent")).RedirectURL, nil
+	}
+
+	if err := o.saveConsent(ctx, userID, consentReq.ClientID, consentReq.Scopes); err != nil {
+		return "", err
+	}
+	return o.issueAuthorizationCode(ctx, userID, consentReq.AuthTime, consentReq.ClientID, consentReq.RedirectURI, consentReq.Scopes,
+		consentReq.State, consentReq.Nonce, consentReq.CodeChallenge)
+}
+
+func (o *OAuthService) hasConsent(ctx context.Context, userID uint, clientID string, scopes []string) (bool, error) {
+	var consent OAuthConsent
+	err := o.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return containsAllScopes(consent.Scopes, scopes), nil
+}
+
+// Add scopes to consent of user for client
+func (o *OAuthService) saveConsent(ctx context.Context, userID uint, clientID string, scopes []string) error {
+	var consent OAuthConsent
+	err := o.db.WithContext(ctx).Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	consent.UserID = userID
+	consent.ClientID = clientID
+	for _, scope := range scopes {
+		if !containsScope(consent.Scopes, scope) {
+			consent.Scopes = append(consent.Scopes, scope)
+		}
+	}
+	return o.db.WithContext(ctx).Save(&consent).Error
+}
+
+func (o *OAuthService) createConsentRequest(ctx context.Context, userID uint, authTime time.Time,
+	req AuthorizeRequest, scopes []string) (string, error) {
+	requestID, err := randgenerate.GenerateSecureToken(tokenLength)
+	if err != nil {
+		return "", err
+	}
+	err = o.db.WithContext(ctx).Create(&OAuthConsentRequest{
+		RequestID:     requestID,
+		UserID:        userID,
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		State:         req.State,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     time.Now().Add(consentRequestValidity),
+	}).Error
+	if err != nil {
+		o.logger.WithField("err", err).Error("Failed to store consent request")
+		return "", err
+	}
+	return requestID, nil
+}
+
+// Issue authorization code, returning the redirect URL of the client with the code
+func (o *OAuthService) issueAuthorizationCode(ctx context.Context, userID uint, authTime time.Time, clientID string, redirectURI string,
+	scopes []string, state string, nonce string, codeChallenge string) (string, error) {
+	code, err := randgenerate.GenerateSecureToken(tokenLength)
+	if err != nil {
+		return "", err
+	}
+	grantID, err := randgenerate.GenerateSecureToken(tokenLength)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = o.db.WithContext(ctx).Create(&OAuthAuthorizationCode{
+		CodeHash:      tokenhash.Hash(code),
+		GrantID:       grantID,
+		ClientID:      clientID,
+		UserID:        userID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		Nonce:         nonce,
+		CodeChallenge: codeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     now.Add(authCodeValidity),
+	}).Error
+	if err != nil {
+		o.logger.WithField("err", err).Error("Failed to store authorization code")
+		return "", err
+	}
+	o.logger.WithFields(logrus.Fields{
+		"client_id": clientID,
+		"user_id":   userID,
+	}).Info("Issued authorization code")
+
+	params := url.Values{}
+	params.Set("code", code)
+	if state != "" {
+		params.Set("state", state)
+	}
+	params.Set("iss", o.issuer)
+	return appendQuery(redirectURI, params), nil
+}
+
+// Build redirect back to client with error
+func (o *OAuthService) errorRedirect(req AuthorizeRequest, oauthErr *Error) *AuthorizeResult {
+	params := url.Values{}
+	params.Set("error", oauthErr.Code)
+	if oauthErr.Description != "" {
+		params.Set("error_description", oauthErr.Description)
+	}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", o.issuer)
+	return &AuthorizeResult{RedirectURL: appendQuery(req.RedirectURI, params)}
+}
+
+func appendQuery(rawURL string, params url.Values) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
@@ -0,0 +1,47 @@
+package oauth
+
+import "net/http"
+
+// Error codes defined by RFC 6749
+const (
+	ErrInvalidRequest          = "invalid_request"
+	ErrInvalidClient           = "invalid_client"
+	ErrInvalidGrant            = "invalid_grant"
+	ErrUnauthorizedClient      = "unauthorized_client"
+	ErrUnsupportedGrantType    = "unsupported_grant_type"
+	ErrUnsupportedResponseType = "unsupported_response_type"
+	ErrInvalidScope            = "invalid_scope"
+	ErrAccessDenied            = "access_denied"
+	ErrServerError             = "server_error"
+	ErrInvalidToken            = "invalid_token"
+)
+
+// Error is an OAuth protocol error, which is returned to clients in the format defined by RFC 6749
+// rather than as a resperror
+type Error struct {
+	Code        string
+	Description string
+	StatusCode  int
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func newError(code string, description string) *Error {
+	statusCode := http.StatusBadRequest
+	switch code {
+	case ErrInvalidClient, ErrInvalidToken:
+		statusCode = http.StatusUnauthorized
+	case ErrServerError:
+		statusCode = http.StatusInternalServerError
+	}
+	return &Error{
+		Code:        code,
+		Description: description,
+		StatusCode:  statusCode,
+	}
+}
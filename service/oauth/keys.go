@@ -0,0 +1,72 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+)
+
+const ephemeralKeyBits = 2048
+
+type signingKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+// Load RSA signing key from PEM file (PKCS #1 or PKCS #8)
+func loadSigningKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("No PEM block found in signing key file")
+	}
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		var parsed interface{}
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			var ok bool
+			if key, ok = parsed.(*rsa.PrivateKey); !ok {
+				err = errors.New("Signing key is not an RSA key")
+			}
+		}
+	default:
+		err = errors.New("Unsupported PEM block type: " + block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(key)
+}
+
+func generateSigningKey() (*signingKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, ephemeralKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(key)
+}
+
+// Key ID is derived from the public key so that it changes when the key is rotated
+func newSigningKey(key *rsa.PrivateKey) (*signingKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return &signingKey{
+		kid: base64.RawURLEncoding.EncodeToString(sum[:12]),
+		key: key,
+	}, nil
+}
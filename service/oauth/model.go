@@ -0,0 +1,184 @@
+package oauth
+
+import "time"
+
+// Supported scopes
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access" // Required for refresh tokens to be issued
+)
+
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+const (
+	clientIDLength          = 16
+	clientSecretLength      = 32
+	tokenLength             = 32
+	authCodeValidity        = time.Minute
+	consentRequestValidity  = 10 * time.Minute
+	accessTokenValidity     = time.Hour
+	refreshTokenValidity    = 30 * 24 * time.Hour
+	idTokenValidity         = time.Hour
+	tokenTypeAccess         = "access_token"
+	tokenTypeRefresh        = "refresh_token"
+	grantTypeAuthCode       = "authorization_code"
+	grantTypeRefreshToken   = "refresh_token"
+	codeChallengeMethodS256 = "S256"
+)
+
+// OAuthClient is an application registered to authenticate users against this server
+type OAuthClient struct {
+	ID           uint   `gorm:"primarykey"`
+	ClientID     string `gorm:"uniqueIndex;size:63"`
+	SecretHash   string // Empty for public clients
+	Name         string
+	RedirectURIs []string `gorm:"serializer:json"`
+	Public       bool     // Public clients (eg. SPAs, mobile apps) cannot keep a secret
+	SkipConsent  bool     // First-party clients that users do not need to grant consent to
+	CreatedAt    time.Time
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// OAuthConsent records the scopes a user has granted to a client
+type OAuthConsent struct {
+	ID        uint `gorm:"primarykey"`
+	UserID    uint
+	ClientID  string
+	Scopes    []string `gorm:"serializer:json"`
+	UpdatedAt time.Time
+}
+
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
+
+// OAuthConsentRequest is an authorization request waiting for the user to grant consent
+type OAuthConsentRequest struct {
+	ID            uint `gorm:"primarykey"`
+	RequestID     string
+	UserID        uint
+	ClientID      string
+	RedirectURI   string
+	Scopes        []string `gorm:"serializer:json"`
+	State         string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time // When the user logged in
+	ExpiresAt     time.Time
+}
+
+func (OAuthConsentRequest) TableName() string {
+	return "oauth_consent_requests"
+}
+
+// OAuthAuthorizationCode is issued to the client after the user authorizes it
+type OAuthAuthorizationCode struct {
+	ID            uint `gorm:"primarykey"`
+	CodeHash      string
+	GrantID       string // Shared by all tokens issued from the code
+	ClientID      string
+	UserID        uint
+	RedirectURI   string
+	Scopes        []string `gorm:"serializer:json"`
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+func (OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// OAuthToken is an opaque access or refresh token
+type OAuthToken struct {
+	ID        uint `gorm:"primarykey"`
+	TokenHash string
+	Type      string
+	GrantID   string // Used to revoke all tokens of a grant (eg. on refresh token reuse)
+	ClientID  string
+	UserID    uint
+	Scopes    []string `gorm:"serializer:json"`
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (OAuthToken) TableName() string {
+	return "oauth_tokens"
+}
+
+// Parameters of a request to the authorization endpoint
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// Result of an authorization request. Either RedirectURL is set (authorization complete
+// or failed), or ConsentRequestID is set (user has to grant consent first)
+type AuthorizeResult struct {
+	RedirectURL      string
+	ConsentRequestID string
+}
+
+// Parameters of a request to the token endpoint
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// Response of token introspection endpoint (RFC 7662)
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+}
+
+// Discovery document (OpenID Connect Discovery 1.0)
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
@@ -0,0 +1,262 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/jwt"
+	randgenerate "github.com/dominiclet/golang-base/lib/rand_generate"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type OAuthService struct {
+	db          *gorm.DB
+	userService *user.UserService
+	issuer      string
+	signingKey  *signingKey // nil if the OAuth server is disabled
+	logger      *logrus.Entry
+}
+
+func InitOAuthService(db *gorm.DB, config *config.Config, env *env.EnvVars, userService *user.UserService) *OAuthService {
+	logger := logger.GetLogger().WithField("module", "oauth_service")
+	o := &OAuthService{
+		db:          db,
+		userService: userService,
+		issuer:      fmt.Sprintf("%s://%s/api/oauth", env.GetHttpProtocol(), config.Domain),
+		logger:      logger,
+	}
+	if !config.OAuth.Enabled {
+		logger.Info("OAuth server disabled")
+		return o
+	}
+
+	var key *signingKey
+	var err error
+	if config.OAuth.SigningKeyPath != "" {
+		key, err = loadSigningKey(config.OAuth.SigningKeyPath)
+	} else if env.IsDev() {
+		logger.Warn("oauth.signing_key_path not set, generating ephemeral signing key")
+		key, err = generateSigningKey()
+	} else {
+		// Tokens signed with an ephemeral key stop validating on restart and differ between instances
+		panic("oauth.signing_key_path must be set outside dev mode")
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load OAuth signing key: %v", err))
+	}
+	o.signingKey = key
+	userService.OnPasswordChange(o.RevokeUserTokens)
+	return o
+}
+
+// Whether the OAuth server is enabled (see config.OAuth)
+func (o *OAuthService) Enabled() bool {
+	return o.signingKey != nil
+}
+
+// Registers a new client. Returns the client and its secret, which is only available at registration.
+// Public clients are not issued a secret
+func (o *OAuthService) RegisterClient(ctx context.Context, name string, redirectURIs []string,
+	public bool, skipConsent bool) (*OAuthClient, string, error) {
+	if len(redirectURIs) == 0 {
+		return nil, "", resperror.NewError(resperror.BadRequest)
+	}
+	for _, redirectURI := range redirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return nil, "", resperror.NewError(resperror.BadRequest)
+		}
+	}
+
+	clientID, err := randgenerate.GenerateSecureToken(clientIDLength)
+	if err != nil {
+		return nil, "", err
+	}
+	client := &OAuthClient{
+		ClientID:     clientID,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Public:       public,
+		SkipConsent:  skipConsent,
+	}
+	var secret string
+	if !public {
+		secret, err = randgenerate.GenerateSecureToken(clientSecretLength)
+		if err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = tokenhash.Hash(secret)
+	}
+	if err := o.db.WithContext(ctx).Create(client).Error; err != nil {
+		o.logger.WithField("err", err).Error("Failed to store OAuth client")
+		return nil, "", err
+	}
+	o.logger.WithFields(logrus.Fields{
+		"client_id": clientID,
+		"name":      name,
+	}).Info("Registered OAuth client")
+	return client, secret, nil
+}
+
+func (o *OAuthService) ListClients(ctx context.Context) ([]OAuthClient, error) {
+	var clients []OAuthClient
+	err := o.db.WithContext(ctx).Order("id").Find(&clients).Error
+	return clients, err
+}
+
+// Deletes client and revokes all tokens issued to it
+func (o *OAuthService) DeleteClient(ctx context.Context, clientID string) error {
+	return o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("client_id = ?", clientID).Delete(&OAuthClient{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return resperror.NewError(resperror.OAuthClientNotFoundError)
+		}
+		for _, model := range []interface{}{&OAuthToken{}, &OAuthAuthorizationCode{}, &OAuthConsent{}, &OAuthConsentRequest{}} {
+			if err := tx.Where("client_id = ?", clientID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Authenticate client at token, revocation and introspection endpoints.
+// Public clients are identified by client ID only
+func (o *OAuthService) AuthenticateClient(ctx context.Context, clientID string, clientSecret string) (*OAuthClient, error) {
+	client, err := o.getClient(ctx, clientID)
+	if err != nil {
+		return nil, newError(ErrInvalidClient, "Client authentication failed")
+	}
+	if client.Public {
+		if clientSecret != "" {
+			return nil, newError(ErrInvalidClient, "Public clients must not use a client secret")
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(tokenhash.Hash(clientSecret))) != 1 {
+		return nil, newError(ErrInvalidClient, "Client authentication failed")
+	}
+	return client, nil
+}
+
+// Get OpenID Connect discovery document
+func (o *OAuthService) GetDiscoveryDocument() DiscoveryDocument {
+	return DiscoveryDocument{
+		Issuer:                            o.issuer,
+		AuthorizationEndpoint:             o.issuer + "/authorize",
+		TokenEndpoint:                     o.issuer + "/token",
+		UserinfoEndpoint:                  o.issuer + "/userinfo",
+		JWKSURI:                           o.issuer + "/jwks",
+		RevocationEndpoint:                o.issuer + "/revoke",
+		IntrospectionEndpoint:             o.issuer + "/introspect",
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{grantTypeAuthCode, grantTypeRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{jwt.RS256},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "email", "email_verified"},
+	}
+}
+
+// Get JWKS containing public key used to sign ID tokens
+func (o *OAuthService) GetJWKS() jwt.JSONWebKeySet {
+	return jwt.JSONWebKeySet{
+		Keys: []jwt.JSONWebKey{jwt.NewRSAJSONWebKey(o.signingKey.kid, &o.signingKey.key.PublicKey)},
+	}
+}
+
+// Get claims about the user that the access token was issued for (OpenID Connect userinfo)
+func (o *OAuthService) GetUserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	token, err := o.getActiveToken(ctx, accessToken, tokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+	if !containsScope(token.Scopes, ScopeOpenID) {
+		return nil, newError(ErrInvalidToken, "Token was not issued with openid scope")
+	}
+	u, err := o.userService.GetUserById(ctx, token.UserID)
+	if err != nil {
+		return nil, newError(ErrInvalidToken, "User no longer exists")
+	}
+	return userClaims(u, token.Scopes), nil
+}
+
+func (o *OAuthService) getClient(ctx context.Context, clientID string) (*OAuthClient, error) {
+	var client OAuthClient
+	err := o.db.WithContext(ctx).Where("client_id = ?", clientID).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Get claims about user that are released for the granted scopes
+func userClaims(u *user.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": u.Uuid,
+	}
+	if containsScope(scopes, ScopeProfile) {
+		claims["name"] = u.Name
+	}
+	if containsScope(scopes, ScopeEmail) {
+		claims["email"] = u.Email
+		claims["email_verified"] = u.IsVerified
+	}
+	return claims
+}
+
+// Parse space-delimited scope parameter, rejecting unsupported scopes
+func parseScopes(scope string) ([]string, error) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !containsScope(supportedScopes, s) {
+			return nil, newError(ErrInvalidScope, "Unsupported scope: "+s)
+		}
+		if !containsScope(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+func joinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAllScopes(scopes []string, required []string) bool {
+	for _, s := range required {
+		if !containsScope(scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
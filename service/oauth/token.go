@@ -0,0 +1,285 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/dominiclet/golang-base/lib/jwt"
+	"github.com/dominiclet/golang-base/lib/oidc"
+	randgenerate "github.com/dominiclet/golang-base/lib/rand_generate"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"github.com/sirupsen/logrus"
+)
+
+// Handle request to token endpoint from authenticated client
+func (o *OAuthService) Exchange(ctx context.Context, client *OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	switch req.GrantType {
+	case grantTypeAuthCode:
+		return o.exchangeAuthorizationCode(ctx, client, req)
+	case grantTypeRefreshToken:
+		return o.exchangeRefreshToken(ctx, client, req)
+	}
+	return nil, newError(ErrUnsupportedGrantType, "")
+}
+
+func (o *OAuthService) exchangeAuthorizationCode(ctx context.Context, client *OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	var code OAuthAuthorizationCode
+	err := o.db.WithContext(ctx).Where("code_hash = ?", tokenhash.Hash(req.Code)).First(&code).Error
+	if err != nil {
+		if isNotFound(err) {
+			return nil, newError(ErrInvalidGrant, "Invalid authorization code")
+		}
+		return nil, err
+	}
+	if code.UsedAt != nil {
+		// Code reuse indicates that the code may have been stolen, so revoke tokens issued from it (RFC 6749 section 4.1.2)
+		o.logger.WithField("client_id", code.ClientID).Warn("Authorization code reused, revoking grant")
+		o.revokeGrant(ctx, code.GrantID)
+		return nil, newError(ErrInvalidGrant, "Authorization code already used")
+	}
+	if time.Now().After(code.ExpiresAt) {
+		return nil, newError(ErrInvalidGrant, "Authorization code expired")
+	}
+	if code.ClientID != client.ClientID {
+		return nil, newError(ErrInvalidGrant, "Authorization code was issued to another client")
+	}
+	if code.RedirectURI != req.RedirectURI {
+		return nil, newError(ErrInvalidGrant, "Redirect URI mismatch")
+	}
+	if subtle.ConstantTimeCompare([]byte(code.CodeChallenge), []byte(oidc.CodeChallengeS256(req.CodeVerifier))) != 1 {
+		return nil, newError(ErrInvalidGrant, "Invalid code verifier")
+	}
+
+	now := time.Now()
+	result := o.db.WithContext(ctx).Model(&code).Where("used_at IS NULL").Update("used_at", &now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, newError(ErrInvalidGrant, "Authorization code already used")
+	}
+
+	resp, err := o.issueTokens(ctx, client.ClientID, code.UserID, code.GrantID, code.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if containsScope(code.Scopes, ScopeOpenID) {
+		resp.IDToken, err = o.issueIDToken(ctx, client.ClientID, code.UserID, code.Scopes, code.Nonce, code.AuthTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// Rotates refresh token, issuing new access and refresh tokens.
+// Reuse of a rotated refresh token revokes all tokens of the grant
+func (o *OAuthService) exchangeRefreshToken(ctx context.Context, client *OAuthClient, req TokenRequest) (*TokenResponse, error) {
+	var token OAuthToken
+	err := o.db.WithContext(ctx).
+		Where("token_hash = ? AND type = ?", tokenhash.Hash(req.RefreshToken), tokenTypeRefresh).
+		First(&token).Error
+	if err != nil {
+		if isNotFound(err) {
+			return nil, newError(ErrInvalidGrant, "Invalid refresh token")
+		}
+		return nil, err
+	}
+	if token.ClientID != client.ClientID {
+		return nil, newError(ErrInvalidGrant, "Refresh token was issued to another client")
+	}
+	if token.RevokedAt != nil {
+		o.logger.WithField("client_id", token.ClientID).Warn("Revoked refresh token reused, revoking grant")
+		o.revokeGrant(ctx, token.GrantID)
+		return nil, newError(ErrInvalidGrant, "Refresh token revoked")
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, newError(ErrInvalidGrant, "Refresh token expired")
+	}
+
+	// Scope of refreshed tokens can only be narrowed
+	scopes := token.Scopes
+	if req.Scope != "" {
+		scopes, err = parseScopes(req.Scope)
+		if err != nil {
+			return nil, err
+		}
+		if !containsAllScopes(token.Scopes, scopes) {
+			return nil, newError(ErrInvalidScope, "Requested scope exceeds granted scope")
+		}
+	}
+
+	now := time.Now()
+	result := o.db.WithContext(ctx).Model(&token).Where("revoked_at IS NULL").Update("revoked_at", &now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, newError(ErrInvalidGrant, "Refresh token revoked")
+	}
+	return o.issueTokens(ctx, client.ClientID, token.UserID, token.GrantID, scopes)
+}
+
+// Revoke token issued to client (RFC 7009). Revoking a refresh token revokes all tokens of its grant.
+// Unknown tokens are ignored
+func (o *OAuthService) Revoke(ctx context.Context, client *OAuthClient, rawToken string) error {
+	var token OAuthToken
+	err := o.db.WithContext(ctx).Where("token_hash = ?", tokenhash.Hash(rawToken)).First(&token).Error
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if token.ClientID != client.ClientID {
+		return nil
+	}
+	if token.Type == tokenTypeRefresh {
+		return o.revokeGrant(ctx, token.GrantID)
+	}
+	now := time.Now()
+	return o.db.WithContext(ctx).Model(&token).Where("revoked_at IS NULL").Update("revoked_at", &now).Error
+}
+
+// Get state of token (RFC 7662). Inactive tokens only return active=false
+func (o *OAuthService) Introspect(ctx context.Context, rawToken string) (*IntrospectionResponse, error) {
+	var token OAuthToken
+	err := o.db.WithContext(ctx).Where("token_hash = ?", tokenhash.Hash(rawToken)).First(&token).Error
+	if err != nil {
+		if isNotFound(err) {
+			return &IntrospectionResponse{Active: false}, nil
+		}
+		return nil, err
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+	u, err := o.userService.GetUserById(ctx, token.UserID)
+	if err != nil {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     joinScopes(token.Scopes),
+		ClientID:  token.ClientID,
+		Subject:   u.Uuid,
+		TokenType: token.Type,
+		ExpiresAt: token.ExpiresAt.Unix(),
+		IssuedAt:  token.CreatedAt.Unix(),
+		Issuer:    o.issuer,
+	}, nil
+}
+
+// Get token if it is of the expected type and has not expired or been revoked
+func (o *OAuthService) getActiveToken(ctx context.Context, rawToken string, tokenType string) (*OAuthToken, error) {
+	var token OAuthToken
+	err := o.db.WithContext(ctx).
+		Where("token_hash = ? AND type = ?", tokenhash.Hash(rawToken), tokenType).
+		First(&token).Error
+	if err != nil {
+		if isNotFound(err) {
+			return nil, newError(ErrInvalidToken, "")
+		}
+		return nil, err
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, newError(ErrInvalidToken, "")
+	}
+	return &token, nil
+}
+
+// Issue access token, and refresh token if offline_access was granted
+func (o *OAuthService) issueTokens(ctx context.Context, clientID string, userID uint, grantID string, scopes []string) (*TokenResponse, error) {
+	accessToken, err := randgenerate.GenerateSecureToken(tokenLength)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tokens := []OAuthToken{{
+		TokenHash: tokenhash.Hash(accessToken),
+		Type:      tokenTypeAccess,
+		GrantID:   grantID,
+		ClientID:  clientID,
+		UserID:    userID,
+		Scopes:    scopes,
+		ExpiresAt: now.Add(accessTokenValidity),
+	}}
+	resp := &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(accessTokenValidity.Seconds()),
+		Scope:       joinScopes(scopes),
+	}
+	if containsScope(scopes, ScopeOfflineAccess) {
+		resp.RefreshToken, err = randgenerate.GenerateSecureToken(tokenLength)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, OAuthToken{
+			TokenHash: tokenhash.Hash(resp.RefreshToken),
+			Type:      tokenTypeRefresh,
+			GrantID:   grantID,
+			ClientID:  clientID,
+			UserID:    userID,
+			Scopes:    scopes,
+			ExpiresAt: now.Add(refreshTokenValidity),
+		})
+	}
+	if err := o.db.WithContext(ctx).Create(&tokens).Error; err != nil {
+		o.logger.WithField("err", err).Error("Failed to store tokens")
+		return nil, err
+	}
+	o.logger.WithFields(logrus.Fields{
+		"client_id": clientID,
+		"user_id":   userID,
+	}).Info("Issued tokens")
+	return resp, nil
+}
+
+func (o *OAuthService) issueIDToken(ctx context.Context, clientID string, userID uint, scopes []string,
+	nonce string, authTime time.Time) (string, error) {
+	u, err := o.userService.GetUserById(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := userClaims(u, scopes)
+	claims["iss"] = o.issuer
+	claims["aud"] = clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(idTokenValidity).Unix()
+	claims["auth_time"] = authTime.Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return jwt.SignRS256(o.signingKey.kid, o.signingKey.key, claims)
+}
+
+func (o *OAuthService) revokeGrant(ctx context.Context, grantID string) error {
+	now := time.Now()
+	err := o.db.WithContext(ctx).Model(&OAuthToken{}).
+		Where("grant_id = ? AND revoked_at IS NULL", grantID).
+		Update("revoked_at", &now).Error
+	if err != nil {
+		o.logger.WithField("err", err).Error("Failed to revoke grant")
+	}
+	return err
+}
+
+// Revoke all tokens of user (eg. after password change)
+func (o *OAuthService) RevokeUserTokens(ctx context.Context, userID uint) error {
+	now := time.Now()
+	result := o.db.WithContext(ctx).Model(&OAuthToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", &now)
+	if result.Error != nil {
+		o.logger.WithField("err", result.Error).Error("Failed to revoke tokens of user")
+		return result.Error
+	}
+	o.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"count":   result.RowsAffected,
+	}).Info("Revoked tokens of user")
+	return nil
+}
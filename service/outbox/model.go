@@ -0,0 +1,34 @@
+package outbox
+
+import "time"
+
+// Delivery states of outbox messages
+const (
+	StatusPending    = "pending"
+	StatusSent       = "sent"
+	StatusDead       = "dead"       // Delivery was given up after too many failed attempts
+	StatusSuppressed = "suppressed" // Not sent as recipient is on suppression list
+)
+
+// OutboxMessage is an email queued for delivery. Content is removed once the message is sent,
+// suppressed or dead, as it may contain tokens
+type OutboxMessage struct {
+	ID            uint `gorm:"primarykey"`
+	Recipient     string
+	Subject       string
+	TextBody      string
+	HTMLBody      string            `gorm:"column:html_body"`
+	Headers       map[string]string `gorm:"serializer:json"`
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	ExpiresAt     *time.Time // Message is dead-lettered instead of sent after this (eg. when tokens in it expire)
+	LastError     string
+	SentAt        *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (OutboxMessage) TableName() string {
+	return "email_outbox"
+}
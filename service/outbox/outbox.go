@@ -0,0 +1,308 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultWorkers      = 2
+	defaultMaxAttempts  = 8
+	defaultPollInterval = 5  // Seconds
+	defaultRetention    = 30 // Days
+	purgeInterval       = time.Hour
+	claimBatchSize      = 10
+	// Claimed messages are not picked up by other workers for this long.
+	// If a worker dies while sending, the message is retried once the claim expires
+	claimDuration  = time.Minute * 5
+	initialBackoff = time.Second * 30
+	maxBackoff     = time.Hour
+)
+
+// OutboxService stores outgoing emails in DB and delivers them from background workers,
+// retrying failed deliveries with exponential backoff
+type OutboxService struct {
+	db           *gorm.DB
+	emailService *email.EmailService
+	logger       *logrus.Entry
+	maxAttempts  int
+	retention    time.Duration
+}
+
+func InitOutboxService(db *gorm.DB, emailService *email.EmailService, config *config.Config) *OutboxService {
+	conf := config.Email.Outbox
+	o := &OutboxService{
+		db:           db,
+		emailService: emailService,
+		logger:       logger.GetLogger().WithField("module", "outbox_service"),
+		maxAttempts:  conf.MaxAttempts,
+		retention:    time.Hour * 24 * time.Duration(conf.RetentionDays),
+	}
+	if o.maxAttempts == 0 {
+		o.maxAttempts = defaultMaxAttempts
+	}
+	if o.retention == 0 {
+		o.retention = time.Hour * 24 * defaultRetention
+	}
+	workers := conf.Workers
+	if workers == 0 {
+		workers = defaultWorkers
+	}
+	pollInterval := time.Second * time.Duration(conf.PollIntervalSeconds)
+	if pollInterval == 0 {
+		pollInterval = time.Second * defaultPollInterval
+	}
+
+	for i := 0; i < workers; i++ {
+		go o.work(pollInterval)
+	}
+	go o.purgePeriodically()
+	return o
+}
+
+// Queue message for delivery. tx should be the transaction of the change that triggered the email
+// (if any), so that the email is only sent if the change is committed
+func (o *OutboxService) Enqueue(ctx context.Context, tx *gorm.DB, msg email.Message) error {
+	return o.enqueue(ctx, tx, msg, nil)
+}
+
+// Queue message that must not be delivered after expiresAt, ie. when the tokens in it expire (see Enqueue)
+func (o *OutboxService) EnqueueUntil(ctx context.Context, tx *gorm.DB, msg email.Message, expiresAt time.Time) error {
+	return o.enqueue(ctx, tx, msg, &expiresAt)
+}
+
+func (o *OutboxService) enqueue(ctx context.Context, tx *gorm.DB, msg email.Message, expiresAt *time.Time) error {
+	err := tx.WithContext(ctx).Create(&OutboxMessage{
+		Recipient:     msg.To,
+		Subject:       msg.Subject,
+		TextBody:      msg.TextBody,
+		HTMLBody:      msg.HTMLBody,
+		Headers:       msg.Headers,
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+		ExpiresAt:     expiresAt,
+	}).Error
+	if err != nil {
+		o.logger.WithField("err", err).Error("Failed to queue email")
+	}
+	return err
+}
+
+// List messages with status (all messages if empty), most recent first
+func (o *OutboxService) ListMessages(ctx context.Context, status string, limit int, offset int) ([]OutboxMessage, error) {
+	query := o.db.WithContext(ctx).Order("id DESC").Limit(limit).Offset(offset)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var messages []OutboxMessage
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (o *OutboxService) GetMessage(ctx context.Context, id uint) (*OutboxMessage, error) {
+	var message OutboxMessage
+	err := o.db.WithContext(ctx).First(&message, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resperror.NewError(resperror.OutboxMessageNotFoundError)
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+// Queue pending message for immediate delivery with a fresh set of attempts. Messages that are
+// suppressed or dead cannot be retried, as their content has been removed
+func (o *OutboxService) RetryMessage(ctx context.Context, id uint) (*OutboxMessage, error) {
+	message, err := o.GetMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if message.Status == StatusSent {
+		return nil, resperror.NewError(resperror.OutboxMessageAlreadySentError)
+	}
+	if message.Status != StatusPending {
+		return nil, resperror.NewError(resperror.OutboxMessageNotPendingError)
+	}
+
+	o.logger.WithField("id", id).Info("Retrying email")
+	message.Status = StatusPending
+	message.Attempts = 0
+	message.NextAttemptAt = time.Now()
+	err = o.db.WithContext(ctx).Model(message).Select("status", "attempts", "next_attempt_at").Updates(message).Error
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (o *OutboxService) work(pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		// Keep going while there are due messages, instead of waiting for the next tick
+		for {
+			messages, err := o.claim()
+			if err != nil {
+				o.logger.WithField("err", err).Error("Failed to claim queued emails")
+				break
+			}
+			for i := range messages {
+				o.deliver(&messages[i])
+			}
+			if len(messages) < claimBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// Claim batch of due messages, so that other workers skip them while they are being sent
+func (o *OutboxService) claim() ([]OutboxMessage, error) {
+	var messages []OutboxMessage
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Order("next_attempt_at").Limit(claimBatchSize).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+		ids := make([]uint, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+		}
+		return tx.Model(&OutboxMessage{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimDuration)).Error
+	})
+	return messages, err
+}
+
+func (o *OutboxService) deliver(message *OutboxMessage) {
+	if message.ExpiresAt != nil && time.Now().After(*message.ExpiresAt) {
+		o.logger.WithField("id", message.ID).Warn("Email expired before it could be sent")
+		err := o.db.Model(message).Updates(finalUpdates(map[string]interface{}{
+			"status":     StatusDead,
+			"last_error": "Expired before it could be sent",
+		})).Error
+		if err != nil {
+			o.logger.WithField("err", err).Error("Failed to mark email as dead")
+		}
+		return
+	}
+
+	err := o.emailService.Send(email.Message{
+		To:       message.Recipient,
+		Subject:  message.Subject,
+		TextBody: message.TextBody,
+		HTMLBody: message.HTMLBody,
+		Headers:  message.Headers,
+	})
+	now := time.Now()
+	if errors.Is(err, email.ErrSuppressed) {
+		err := o.db.Model(message).Updates(finalUpdates(map[string]interface{}{
+			"status":     StatusSuppressed,
+			"last_error": err.Error(),
+		})).Error
+		if err != nil {
+			o.logger.WithField("err", err).Error("Failed to mark email as suppressed")
+		}
+		return
+	}
+	if err == nil {
+		err := o.db.Model(message).Updates(finalUpdates(map[string]interface{}{
+			"status":     StatusSent,
+			"attempts":   message.Attempts + 1,
+			"last_error": "",
+			"sent_at":    now,
+		})).Error
+		if err != nil {
+			o.logger.WithField("err", err).Error("Failed to mark email as sent")
+		}
+		return
+	}
+
+	attempts := message.Attempts + 1
+	nextAttemptAt := now.Add(backoff(attempts))
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      err.Error(),
+		"next_attempt_at": nextAttemptAt,
+	}
+	fields := logrus.Fields{
+		"id":       message.ID,
+		"attempts": attempts,
+		"err":      err,
+	}
+	if attempts >= o.maxAttempts {
+		updates["status"] = StatusDead
+		updates = finalUpdates(updates)
+		o.logger.WithFields(fields).Error("Giving up on email after too many failed attempts")
+	} else if message.ExpiresAt != nil && nextAttemptAt.After(*message.ExpiresAt) {
+		updates["status"] = StatusDead
+		updates = finalUpdates(updates)
+		o.logger.WithFields(fields).Error("Giving up on email that expires before it can be retried")
+	} else {
+		o.logger.WithFields(fields).Warn("Failed to send email, will retry")
+	}
+	if err := o.db.Model(message).Updates(updates).Error; err != nil {
+		o.logger.WithField("err", err).Error("Failed to record failed email delivery")
+	}
+}
+
+// Adds removal of the content of message to updates that move it to a final state
+func finalUpdates(updates map[string]interface{}) map[string]interface{} {
+	updates["text_body"] = ""
+	updates["html_body"] = ""
+	updates["headers"] = nil // eg. List-Unsubscribe links
+	return updates
+}
+
+func (o *OutboxService) purgePeriodically() {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := o.Purge(context.Background()); err != nil {
+			o.logger.WithField("err", err).Error("Failed to purge old emails")
+		}
+	}
+}
+
+// Delete sent, suppressed and dead messages older than the retention period. Returns the number of
+// messages deleted
+func (o *OutboxService) Purge(ctx context.Context) (int64, error) {
+	result := o.db.WithContext(ctx).
+		Where("status IN ? AND updated_at < ?", []string{StatusSent, StatusSuppressed, StatusDead}, time.Now().Add(-o.retention)).
+		Delete(&OutboxMessage{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		o.logger.WithField("count", result.RowsAffected).Info("Purged old emails")
+	}
+	return result.RowsAffected, nil
+}
+
+// Delay before next attempt after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,47 @@
+package session
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dominiclet/golang-base/service/user"
+	"gorm.io/gorm"
+)
+
+// Returned by authenticators if the user does not exist or the password is wrong.
+// Callers must not distinguish the two cases so that they do not reveal whether an account exists
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator verifies login credentials against a user store.
+// Authenticators are tried in order on login until one of them accepts the credentials
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username string, password string) (*user.User, error)
+}
+
+// Authenticates users with the password hash stored in the users table
+type passwordAuthenticator struct {
+	userService *user.UserService
+}
+
+func (p *passwordAuthenticator) Name() string {
+	return "password"
+}
+
+func (p *passwordAuthenticator) Authenticate(ctx context.Context, email string, password string) (*user.User, error) {
+	u, err := p.userService.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Password is still checked if user does not exist, so that response time does not reveal whether it exists.
+	// Users provisioned by external identity providers have no password and never match
+	ok, err := p.userService.CheckPassword(ctx, u, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errInvalidCredentials
+	}
+	return u, nil
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/lib/ldap"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Authenticates users with a simple bind to an LDAP directory.
+// Users are provisioned on first login, and linked to existing users by the email in the directory
+type ldapAuthenticator struct {
+	client      *ldap.Client
+	userService *user.UserService
+	db          *gorm.DB
+	logger      *logrus.Entry
+}
+
+func newLDAPAuthenticator(conf config.LDAP, userService *user.UserService, db *gorm.DB, logger *logrus.Entry) *ldapAuthenticator {
+	return &ldapAuthenticator{
+		client: ldap.NewClient(ldap.Config{
+			URL:            conf.URL,
+			BindDNTemplate: conf.BindDNTemplate,
+			StartTLS:       conf.StartTLS,
+			EmailAttribute: conf.Attributes.Email,
+			NameAttribute:  conf.Attributes.Name,
+		}),
+		userService: userService,
+		db:          db,
+		logger:      logger.WithField("authenticator", "ldap"),
+	}
+}
+
+func (l *ldapAuthenticator) Name() string {
+	return "ldap"
+}
+
+func (l *ldapAuthenticator) Authenticate(ctx context.Context, username string, password string) (*user.User, error) {
+	entry, err := l.client.Authenticate(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, ldap.ErrInvalidCredentials) {
+			return nil, errInvalidCredentials
+		}
+		return nil, err
+	}
+	if entry.Email == "" {
+		l.logger.WithField("dn", entry.DN).Error("Directory entry has no email")
+		return nil, errors.New("directory entry has no email")
+	}
+
+	u, err := l.userService.GetUserByEmail(ctx, entry.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if u == nil {
+		name := entry.Name
+		if name == "" {
+			name = username
+		}
+		l.logger.WithField("dn", entry.DN).Info("Provisioning user from directory")
+		return l.userService.CreateExternalUser(ctx, name, entry.Email)
+	}
+	if !u.IsVerified {
+		// The unverified account may have been registered by someone else who does not own the email
+		l.logger.WithField("email", u.Email).Warn("Verifying unverified user and clearing password on directory login")
+		u.IsVerified = true
+		u.Password = ""
+		u.VerificationToken = ""
+		err = l.db.WithContext(ctx).Model(u).
+			Select("is_verified", "password", "verification_token").Updates(u).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
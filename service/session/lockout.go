@@ -0,0 +1,235 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	randgenerate "github.com/dominiclet/golang-base/lib/rand_generate"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"github.com/dominiclet/golang-base/service/audit"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Key of account in the throttle table. Only used for throttling, as stored emails may differ in case
+func accountThrottleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Rejects login attempt if the account or IP is locked out, or has to wait before its next attempt.
+// The error details tell when the next attempt is allowed
+func (a *SessionService) checkLoginThrottle(ctx context.Context, accountKey string, ip string) error {
+	var throttles []LoginThrottle
+	err := a.db.WithContext(ctx).
+		Where("(scope = ? AND throttle_key = ?) OR (scope = ? AND throttle_key = ?)",
+			throttleScopeAccount, accountKey, throttleScopeIP, ip).
+		Find(&throttles).Error
+	if err != nil {
+		a.logger.WithField("err", err).Error("Failed to query login throttles")
+		return err
+	}
+
+	now := time.Now()
+	var lockedFor, delayedFor time.Duration
+	for _, throttle := range throttles {
+		if throttle.LockedUntil != nil && throttle.LockedUntil.Sub(now) > lockedFor {
+			lockedFor = throttle.LockedUntil.Sub(now)
+		}
+		if throttle.NextAttemptAt.Sub(now) > delayedFor {
+			delayedFor = throttle.NextAttemptAt.Sub(now)
+		}
+	}
+	if lockedFor == 0 && delayedFor == 0 {
+		return nil
+	}
+
+	a.logger.WithFields(logrus.Fields{
+		"email":  accountKey,
+		"ip":     ip,
+		"locked": lockedFor > 0,
+	}).Warn("Login attempt throttled")
+	if lockedFor > 0 {
+		return resperror.NewError(resperror.AccountLockedError).WithDetails(retryDetails(lockedFor))
+	}
+	return resperror.NewError(resperror.LoginThrottledError).WithDetails(retryDetails(delayedFor))
+}
+
+func retryDetails(retryAfter time.Duration) resperror.RetryDetails {
+	return resperror.RetryDetails{RetryAfter: int(math.Ceil(retryAfter.Seconds()))}
+}
+
+// Records failed login attempt of the account with email against both the account and the IP,
+// locking them out once their lockout threshold is reached
+func (a *SessionService) handleLoginFailure(ctx context.Context, email string, ip string) {
+	locked, err := a.recordLoginFailure(ctx, throttleScopeAccount, accountThrottleKey(email), accountThrottlePolicy)
+	if err != nil {
+		a.logger.WithField("err", err).Error("Failed to record failed login attempt of account")
+	} else if locked {
+		a.lockAccount(ctx, email, ip)
+	}
+
+	if ip == "" {
+		return
+	}
+	blocked, err := a.recordLoginFailure(ctx, throttleScopeIP, ip, ipThrottlePolicy)
+	if err != nil {
+		a.logger.WithField("err", err).Error("Failed to record failed login attempt of IP")
+	} else if blocked {
+		a.auditService.Record(ctx, audit.AuditEvent{
+			Event: audit.EventIPBlocked,
+			IP:    ip,
+			Details: map[string]interface{}{
+				"duration_minutes": ipThrottlePolicy.lockoutDuration.Minutes(),
+			},
+		})
+	}
+}
+
+// Returns whether this failure caused a lockout
+func (a *SessionService) recordLoginFailure(ctx context.Context, scope string, key string, policy throttlePolicy) (bool, error) {
+	locked := false
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&LoginThrottle{Scope: scope, ThrottleKey: key, WindowStart: now}).Error
+		if err != nil {
+			return err
+		}
+		var throttle LoginThrottle
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND throttle_key = ?", scope, key).
+			First(&throttle).Error
+		if err != nil {
+			return err
+		}
+
+		if now.Sub(throttle.WindowStart) > time.Minute*loginThrottleWindow {
+			throttle.Failures = 0
+			throttle.WindowStart = now
+		}
+		throttle.Failures++
+		throttle.NextAttemptAt = now.Add(policy.delay(throttle.Failures))
+		if throttle.Failures >= policy.lockoutThreshold {
+			// Counting starts over once the lockout ends
+			lockedUntil := now.Add(policy.lockoutDuration)
+			throttle.LockedUntil = &lockedUntil
+			throttle.Failures = 0
+			throttle.WindowStart = now
+			locked = true
+		}
+		return tx.Save(&throttle).Error
+	})
+	return locked, err
+}
+
+// Delay before next attempt is allowed after the given number of failures
+func (p throttlePolicy) delay(failures int64) time.Duration {
+	if failures <= p.freeAttempts {
+		return 0
+	}
+	delay := time.Second
+	for i := p.freeAttempts + 1; i < failures && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay {
+		return p.maxDelay
+	}
+	return delay
+}
+
+// Clears failed attempts of account after successful login
+func (a *SessionService) resetLoginFailures(ctx context.Context, accountKey string) {
+	err := a.db.WithContext(ctx).
+		Where("scope = ? AND throttle_key = ?", throttleScopeAccount, accountKey).
+		Delete(&LoginThrottle{}).Error
+	if err != nil {
+		a.logger.WithField("err", err).Error("Failed to reset failed login attempts")
+	}
+}
+
+// Records lockout of the account with email (as entered at login) in audit log and sends unlock link
+// if the account exists
+func (a *SessionService) lockAccount(ctx context.Context, email string, ip string) {
+	email = strings.TrimSpace(email)
+	event := audit.AuditEvent{
+		Event: audit.EventAccountLocked,
+		IP:    ip,
+		Details: map[string]interface{}{
+			"email":            email,
+			"duration_minutes": accountThrottlePolicy.lockoutDuration.Minutes(),
+		},
+	}
+	user, err := a.userService.GetUserByEmail(ctx, email)
+	if err == nil {
+		event.UserID = &user.ID
+		if err := a.sendUnlockEmail(ctx, user); err != nil {
+			a.logger.WithField("err", err).Error("Failed to send account unlock email")
+		}
+	}
+	a.auditService.Record(ctx, event)
+}
+
+func (a *SessionService) sendUnlockEmail(ctx context.Context, user *user.User) error {
+	token, err := randgenerate.GenerateSecureToken(unlockTokenLength)
+	if err != nil {
+		return err
+	}
+	msg, err := a.emailService.AccountUnlockEmail(user.Email, user.Language, token)
+	if err != nil {
+		return err
+	}
+	unlockToken := AccountUnlockToken{
+		UserID:    user.ID,
+		TokenHash: tokenhash.Hash(token),
+		ExpiresAt: time.Now().Add(time.Hour * unlockTokenValidity),
+		CreatedAt: time.Now(),
+	}
+	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&unlockToken).Error; err != nil {
+			return err
+		}
+		return a.outboxService.EnqueueUntil(ctx, tx, msg, unlockToken.ExpiresAt)
+	})
+}
+
+// Lifts lockout of account with the single-use token sent by email when the account was locked
+func (a *SessionService) UnlockAccount(ctx context.Context, token string, ip string) error {
+	var unlockToken AccountUnlockToken
+	err := a.db.WithContext(ctx).Where("token_hash = ?", tokenhash.Hash(token)).First(&unlockToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resperror.NewError(resperror.InvalidUnlockTokenError)
+		}
+		return err
+	}
+	if unlockToken.UsedAt != nil || time.Now().After(unlockToken.ExpiresAt) {
+		return resperror.NewError(resperror.InvalidUnlockTokenError)
+	}
+
+	now := time.Now()
+	result := a.db.WithContext(ctx).Model(&unlockToken).Where("used_at IS NULL").Update("used_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return resperror.NewError(resperror.InvalidUnlockTokenError)
+	}
+
+	user, err := a.userService.GetUserById(ctx, unlockToken.UserID)
+	if err != nil {
+		return err
+	}
+	a.resetLoginFailures(ctx, accountThrottleKey(user.Email))
+	a.auditService.Record(ctx, audit.AuditEvent{
+		Event:  audit.EventAccountUnlocked,
+		UserID: &user.ID,
+		IP:     ip,
+	})
+	return nil
+}
@@ -0,0 +1,114 @@
+package session
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	randgenerate "github.com/dominiclet/golang-base/lib/rand_generate"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"gorm.io/gorm"
+)
+
+// Sends a single-use login link to the email if an account with the email exists.
+// If bindBrowser is set, a binding nonce is returned which must be presented when the link is consumed
+// (to be stored in the requesting browser). A nonce is returned even if the account does not exist
+// so that callers cannot tell whether the account exists
+func (a *SessionService) RequestMagicLink(ctx context.Context, email string, bindBrowser bool) (string, error) {
+	var binding string
+	if bindBrowser {
+		var err error
+		binding, err = randgenerate.GenerateSecureToken(magicLinkBindingLength)
+		if err != nil {
+			a.logger.WithField("err", err).Error("Failed to generate magic link binding")
+			return "", err
+		}
+	}
+
+	user, err := a.userService.GetUserByEmail(ctx, email)
+	if err != nil {
+		return binding, err
+	}
+
+	// Rate limit number of links sent to each email
+	var recentCount int64
+	err = a.db.WithContext(ctx).Model(&MagicLinkToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-time.Minute*magicLinkRateWindow)).
+		Count(&recentCount).Error
+	if err != nil {
+		a.logger.WithField("err", err).Error("Failed to count recent magic links")
+		return binding, err
+	}
+	if recentCount >= maxMagicLinksPerWindow {
+		a.logger.WithField("email", email).Warn("Magic link rate limit reached")
+		return binding, resperror.NewError(resperror.TooManyRequests)
+	}
+
+	token, err := randgenerate.GenerateSecureToken(magicLinkTokenLength)
+	if err != nil {
+		a.logger.WithField("err", err).Error("Failed to generate magic link token")
+		return binding, err
+	}
+	magicLink := MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: tokenhash.Hash(token),
+		ExpiresAt: time.Now().Add(time.Minute * magicLinkValidity),
+		CreatedAt: time.Now(),
+	}
+	if binding != "" {
+		magicLink.BindingHash = tokenhash.Hash(binding)
+	}
+	msg, err := a.emailService.MagicLinkEmail(user.Email, user.Language, token)
+	if err != nil {
+		return binding, err
+	}
+	err = a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&magicLink).Error; err != nil {
+			a.logger.WithField("err", err).Error("Failed to store magic link token")
+			return err
+		}
+		return a.outboxService.EnqueueUntil(ctx, tx, msg, magicLink.ExpiresAt)
+	})
+	return binding, err
+}
+
+// Exchanges magic link token for a session, applying the same checks as CreateUserSession.
+// binding must match the nonce returned when the link was requested if the link is bound to a browser
+func (a *SessionService) ConsumeMagicLink(ctx context.Context, token string, binding string) (*LoginResult, error) {
+	var magicLink MagicLinkToken
+	err := a.db.WithContext(ctx).Where("token_hash = ?", tokenhash.Hash(token)).First(&magicLink).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resperror.NewError(resperror.InvalidTokenError)
+		}
+		return nil, err
+	}
+	if magicLink.UsedAt != nil || time.Now().After(magicLink.ExpiresAt) {
+		return nil, resperror.NewError(resperror.InvalidTokenError)
+	}
+	// Check binding before consuming so that the link is not used up when opened
+	// in another browser (eg. by link scanners)
+	if magicLink.BindingHash != "" &&
+		subtle.ConstantTimeCompare([]byte(magicLink.BindingHash), []byte(tokenhash.Hash(binding))) != 1 {
+		a.logger.WithField("user_id", magicLink.UserID).Warn("Magic link consumed from a different browser")
+		return nil, resperror.NewError(resperror.InvalidTokenError)
+	}
+
+	now := time.Now()
+	result := a.db.WithContext(ctx).Model(&magicLink).Where("used_at IS NULL").Update("used_at", &now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Link was consumed concurrently
+		return nil, resperror.NewError(resperror.InvalidTokenError)
+	}
+
+	user, err := a.userService.GetUserById(ctx, magicLink.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return a.LoginUser(ctx, user)
+}
@@ -1,3 +1,105 @@
 package session
 
+import (
+	"time"
+
+	"github.com/dominiclet/golang-base/service/user"
+)
+
 const DefaultSessionDurationDay = 7 // No. of days login session will last
+
+// Cached sessions are reloaded from DB after this long, so that sessions revoked or users changed
+// elsewhere (eg. by another instance or the CLI) take effect
+const sessionCacheTTL = time.Minute
+
+const (
+	magicLinkTokenLength   = 32
+	magicLinkBindingLength = 32
+	magicLinkValidity      = 15 // No. of minutes a magic link is valid
+	magicLinkRateWindow    = 15 // No. of minutes over which magic link requests are rate limited
+	maxMagicLinksPerWindow = 3  // No. of magic links that can be requested per email within rate window
+)
+
+// Session in cache, with the time it was loaded
+type cachedSession struct {
+	Session
+	CachedAt time.Time
+}
+
+// Result of a login attempt
+// If TwoFactorRequired is set, no session is created and ChallengeToken must be exchanged for a session
+type LoginResult struct {
+	User              *user.User
+	Token             string
+	Expiry            int64 // Unix time of session expiry
+	TwoFactorRequired bool
+	ChallengeToken    string
+}
+
+// MagicLinkToken is a single-use token sent by email that can be exchanged for a session.
+// If BindingHash is set, the link can only be consumed from the browser that requested it
+type MagicLinkToken struct {
+	ID          uint `gorm:"primarykey"`
+	UserID      uint
+	TokenHash   string
+	BindingHash string
+	ExpiresAt   time.Time
+	UsedAt      *time.Time
+	CreatedAt   time.Time
+}
+
+const (
+	loginThrottleWindow = 15 // No. of minutes over which failed login attempts are counted
+	unlockTokenLength   = 32
+	unlockTokenValidity = 24 // No. of hours an account unlock link is valid
+)
+
+// Scopes failed login attempts are tracked in
+const (
+	throttleScopeAccount = "account" // Keyed by (lowercased) email, whether or not the account exists
+	throttleScopeIP      = "ip"
+)
+
+type throttlePolicy struct {
+	freeAttempts     int64         // No. of failures before attempts are delayed
+	maxDelay         time.Duration // Delay doubles with each further failure up to this
+	lockoutThreshold int64         // No. of failures after which attempts are rejected for lockoutDuration
+	lockoutDuration  time.Duration
+}
+
+var accountThrottlePolicy = throttlePolicy{
+	freeAttempts:     3,
+	maxDelay:         30 * time.Second,
+	lockoutThreshold: 10,
+	lockoutDuration:  15 * time.Minute,
+}
+
+// IPs may be shared by many users (eg. NAT), so they are allowed more attempts
+var ipThrottlePolicy = throttlePolicy{
+	freeAttempts:     20,
+	maxDelay:         30 * time.Second,
+	lockoutThreshold: 100,
+	lockoutDuration:  15 * time.Minute,
+}
+
+// LoginThrottle tracks failed login attempts of an account or IP address
+type LoginThrottle struct {
+	ID            uint `gorm:"primarykey"`
+	Scope         string
+	ThrottleKey   string
+	Failures      int64
+	WindowStart   time.Time
+	NextAttemptAt time.Time // Attempts before this are rejected (progressive delay)
+	LockedUntil   *time.Time
+	UpdatedAt     time.Time
+}
+
+// AccountUnlockToken is a single-use token sent by email that lifts the lockout of an account
+type AccountUnlockToken struct {
+	ID        uint `gorm:"primarykey"`
+	UserID    uint
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
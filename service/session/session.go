@@ -5,13 +5,18 @@ import (
 	"errors"
 	"time"
 
+	"github.com/dominiclet/golang-base/init_server/config"
 	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/email"
 	"github.com/dominiclet/golang-base/lib/resperror"
 	"github.com/dominiclet/golang-base/lib/store"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"github.com/dominiclet/golang-base/service/audit"
+	"github.com/dominiclet/golang-base/service/outbox"
+	"github.com/dominiclet/golang-base/service/twofactor"
 	"github.com/dominiclet/golang-base/service/user"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -26,72 +31,165 @@ type Session struct {
 }
 
 type SessionService struct {
-	userService *user.UserService
-	db          *gorm.DB
-	logger      *logrus.Entry
+	userService      *user.UserService
+	twoFactorService *twofactor.TwoFactorService
+	emailService     *email.EmailService
+	outboxService    *outbox.OutboxService
+	auditService     *audit.AuditService
+	db               *gorm.DB
+	logger           *logrus.Entry
+	authenticators   []Authenticator
 	// sessionCache maps session tokens to the Session object it is associated with
 	// for faster validation of session token
-	sessionCache *store.Store[string, Session]
+	sessionCache *store.Store[string, cachedSession]
 }
 
-func InitSessionService(userService *user.UserService, db *gorm.DB) *SessionService {
-	return &SessionService{
-		userService:  userService,
-		db:           db,
-		sessionCache: store.NewStore[string, Session](),
-		logger:       logger.GetLogger().WithField("module", "session_service"),
+func InitSessionService(userService *user.UserService, twoFactorService *twofactor.TwoFactorService,
+	emailService *email.EmailService, outboxService *outbox.OutboxService, auditService *audit.AuditService,
+	config *config.Config, db *gorm.DB) *SessionService {
+	a := &SessionService{
+		userService:      userService,
+		twoFactorService: twoFactorService,
+		emailService:     emailService,
+		outboxService:    outboxService,
+		auditService:     auditService,
+		db:               db,
+		sessionCache:     store.NewStore[string, cachedSession](),
+		logger:           logger.GetLogger().WithField("module", "session_service"),
 	}
+	// Sessions may have been started by someone who knew the old password
+	userService.OnPasswordChange(func(ctx context.Context, userID uint) error {
+		_, err := a.RevokeUserSessions(ctx, userID)
+		return err
+	})
+	a.authenticators = []Authenticator{&passwordAuthenticator{userService: userService}}
+	if config.LDAP.URL != "" {
+		a.authenticators = append(a.authenticators, newLDAPAuthenticator(config.LDAP, userService, db, a.logger))
+	}
+	return a
+}
+
+// Verifies user credentials with each authenticator in turn, then generates session for user.
+// Failed attempts are counted per account and per IP, and further attempts are delayed or rejected
+// (see LoginThrottledError). Failures due to wrong credentials do not reveal whether the account exists.
+// If user has 2FA enabled, a 2FA challenge is returned instead of a session (see CompleteTwoFactorLogin)
+func (a *SessionService) CreateUserSession(ctx context.Context, email string, password string, ip string) (*LoginResult, error) {
+	accountKey := accountThrottleKey(email)
+	if err := a.checkLoginThrottle(ctx, accountKey, ip); err != nil {
+		return nil, err
+	}
+
+	var authErr error
+	for _, authenticator := range a.authenticators {
+		user, err := authenticator.Authenticate(ctx, email, password)
+		if err == nil {
+			// Failures of users with 2FA are only reset once the 2FA code is verified too
+			if !user.TOTPEnabled {
+				a.resetLoginFailures(ctx, accountKey)
+			}
+			return a.LoginUser(ctx, user)
+		}
+		if !errors.Is(err, errInvalidCredentials) {
+			a.logger.WithFields(logrus.Fields{
+				"authenticator": authenticator.Name(),
+				"err":           err,
+			}).Error("Error occurred during authentication")
+			if authErr == nil {
+				authErr = err
+			}
+		}
+	}
+
+	a.logger.WithFields(logrus.Fields{
+		"email": email,
+		"ip":    ip,
+	}).Warn("Failed login attempt")
+	a.handleLoginFailure(ctx, email, ip)
+	if authErr != nil {
+		return nil, authErr
+	}
+	return nil, resperror.NewError(resperror.InvalidCredentialsError)
+}
+
+// Checks if a user that has already been authenticated (eg. by password or an external identity provider)
+// is allowed to log in, then starts a session (or issues a 2FA challenge if user has 2FA enabled)
+func (a *SessionService) LoginUser(ctx context.Context, user *user.User) (*LoginResult, error) {
+	if err := a.checkLoginAllowed(user); err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		challengeToken, err := a.twoFactorService.CreateChallenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		a.logger.WithField("email", user.Email).Info("2FA required for login")
+		return &LoginResult{
+			User:              user,
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		}, nil
+	}
+
+	return a.startSession(ctx, user)
 }
 
-// Verifies user email and password, then generates session for user, returning the user object, session token, and time of expiry
-func (a *SessionService) CreateUserSession(ctx context.Context, email string, password string) (*user.User, string, int64, error) {
-	user, err := a.userService.GetUserByEmail(ctx, email)
+// Exchanges 2FA challenge token and TOTP (or recovery) code for a session. Wrong codes count as failed
+// login attempts of the account and IP, so that codes cannot be guessed by requesting new challenges
+func (a *SessionService) CompleteTwoFactorLogin(ctx context.Context, challengeToken string, code string, ip string) (*LoginResult, error) {
+	challengeUser, err := a.twoFactorService.ChallengeUser(ctx, challengeToken)
 	if err != nil {
-		return nil, "", 0, resperror.NewError(resperror.UserEmailNotFound)
+		return nil, err
+	}
+	accountKey := accountThrottleKey(challengeUser.Email)
+	if err := a.checkLoginThrottle(ctx, accountKey, ip); err != nil {
+		return nil, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	user, err := a.twoFactorService.VerifyChallenge(ctx, challengeToken, code)
 	if err != nil {
-		a.logger.WithField("err", err).Error("Incorrect password given")
-		return nil, "", 0, resperror.NewError(resperror.UserIncorrectPassword)
+		if resperror.HasCode(err, resperror.InvalidTwoFactorCodeError) {
+			a.logger.WithFields(logrus.Fields{
+				"email": challengeUser.Email,
+				"ip":    ip,
+			}).Warn("Failed 2FA login attempt")
+			a.handleLoginFailure(ctx, challengeUser.Email, ip)
+		}
+		return nil, err
+	}
+	// User may have changed since the challenge was issued
+	if err := a.checkLoginAllowed(user); err != nil {
+		return nil, err
 	}
+	a.resetLoginFailures(ctx, accountKey)
+	return a.startSession(ctx, user)
+}
 
+// Check if authenticated user is allowed to log in
+func (a *SessionService) checkLoginAllowed(user *user.User) error {
 	if !user.IsVerified {
-		a.logger.WithField("email", email).Error("User not verified")
-		return nil, "", 0, resperror.NewError(resperror.UserNotVerifiedError)
+		a.logger.WithField("email", user.Email).Error("User not verified")
+		return resperror.NewError(resperror.UserNotVerifiedError)
 	}
 
 	// Check if license of user is valid
 	if !a.userService.CheckLicenseValid(user) {
-		return nil, "", 0, resperror.NewError(resperror.UserLicenseExpiredError)
+		return resperror.NewError(resperror.UserLicenseExpiredError)
 	}
+	return nil
+}
 
+// Replaces any existing sessions of user with a new session
+func (a *SessionService) startSession(ctx context.Context, user *user.User) (*LoginResult, error) {
 	// Remove any existing sessions
-	var existingSessions []Session
-	result := a.db.Find(&existingSessions)
-	if result.Error != nil {
-		a.logger.WithField("err", err).Error("Error while querying for existing sessions")
-		return nil, "", 0, nil
-	}
-	// Remove sessions from cache
-	if result.RowsAffected != 0 {
-		for _, currSession := range existingSessions {
-			a.logger.WithField("session_token", currSession.Token).
-				Info("Removing session token from cache")
-			a.sessionCache.Delete(currSession.Token)
-		}
-	}
-	// Remove sessions from DB
-	err = a.db.Where("user_id = ?", user.ID).Delete(&Session{}).Error
-	if err != nil {
-		a.logger.WithField("err", err).Error("Error occurred while removing existing sessions for user")
-		return nil, "", 0, nil
+	if _, err := a.RevokeUserSessions(ctx, user.ID); err != nil {
+		return nil, err
 	}
 
 	// Create new session
 	token := uuid.NewString()
 	if token == "" {
-		return nil, "", 0, errors.New("Failed to generate uuid token")
+		return nil, errors.New("Failed to generate uuid token")
 	}
 
 	expiry := time.Now().Add(time.Hour * 24 * DefaultSessionDurationDay)
@@ -101,26 +199,39 @@ func (a *SessionService) CreateUserSession(ctx context.Context, email string, pa
 		CreatedAt: time.Now(),
 		ExpiresAt: expiry,
 	}
-	err = a.db.Create(newSession).Error
+	err := a.db.Create(newSession).Error
 	if err != nil {
 		a.logger.WithField("err", err).Error("Failed to store session token")
-		return nil, "", 0, err
+		return nil, err
 	}
 
 	// Store session in cache
 	newSession.User = *user
-	a.sessionCache.Set(newSession.Token, *newSession)
+	a.sessionCache.Set(newSession.Token, cachedSession{Session: *newSession, CachedAt: time.Now()})
 	a.logger.WithFields(logrus.Fields{
-		"session_token": newSession.Token,
-		"email":         newSession.User.Email,
+		"token_hash": tokenhash.Hash(newSession.Token),
+		"email":      newSession.User.Email,
 	}).Info("Stored session in cache")
 
-	return user, token, expiry.Unix(), nil
+	return &LoginResult{
+		User:   user,
+		Token:  token,
+		Expiry: expiry.Unix(),
+	}, nil
 }
 
 // Retrieves session user from token. If session is expired, deletes session and returns error
 func (a *SessionService) GetSessionByToken(token string) (*user.User, error) {
-	a.logger.WithField("token", token).Info("Getting session with token")
+	session, err := a.GetSession(token)
+	if err != nil {
+		return nil, err
+	}
+	return &session.User, nil
+}
+
+// Retrieves session with its user from token. If session is expired, deletes session and returns error
+func (a *SessionService) GetSession(token string) (*Session, error) {
+	a.logger.WithField("token_hash", tokenhash.Hash(token)).Info("Getting session with token")
 
 	session, err := a.getSession(token)
 	if err != nil {
@@ -131,7 +242,7 @@ func (a *SessionService) GetSessionByToken(token string) (*user.User, error) {
 	// Check if session has expired
 	if time.Now().After(session.ExpiresAt) {
 		a.logger.WithFields(logrus.Fields{
-			"token":      token,
+			"token_hash": tokenhash.Hash(token),
 			"expires_at": session.ExpiresAt,
 		}).Error("Session is expired")
 
@@ -139,32 +250,72 @@ func (a *SessionService) GetSessionByToken(token string) (*user.User, error) {
 		if err != nil {
 			return nil, err
 		}
-		return nil, errors.New("Session expired")
+		return nil, resperror.NewError(resperror.Unauthorized).WithInternal("Session expired")
 	}
 
-	return &session.User, nil
+	return &session, nil
 }
 
-// Retrieve session object (first queries cache, then on cache miss, query DB)
+// Retrieve session object (first queries cache, then on cache miss or if cached session is stale, query DB)
 func (a *SessionService) getSession(token string) (Session, error) {
-	cachedSession, err := a.sessionCache.Get(token)
-	if err == nil {
+	cached, err := a.sessionCache.Get(token)
+	if err == nil && time.Since(cached.CachedAt) < sessionCacheTTL {
 		a.logger.WithFields(logrus.Fields{
-			"user_email": cachedSession.User.Email,
-			"token":      cachedSession.Token,
+			"user_email": cached.User.Email,
+			"token_hash": tokenhash.Hash(cached.Token),
 		}).Info("Session cache hit")
-		return cachedSession, nil
+		return cached.Session, nil
 	}
+	a.sessionCache.Delete(token)
 	// Cache miss, query DB
-	a.logger.WithField("token", token).Info("Cache miss, querying DB for session")
+	a.logger.WithField("token_hash", tokenhash.Hash(token)).Info("Cache miss, querying DB for session")
 	var session Session
 	err = a.db.Where("token = ?", token).Preload("User").First(&session).Error
 	if err != nil {
 		return Session{}, err
 	}
+	a.sessionCache.Set(token, cachedSession{Session: session, CachedAt: time.Now()})
 	return session, nil
 }
 
+// Remove cached sessions of user so that the user is reloaded from DB on next access
+// (to be called after user data that session users depend on has changed)
+func (a *SessionService) InvalidateUserSessionCache(ctx context.Context, userID uint) {
+	var sessions []Session
+	err := a.db.WithContext(ctx).Where("user_id = ?", userID).Find(&sessions).Error
+	if err != nil {
+		a.logger.WithField("err", err).Error("Failed to query sessions of user")
+		return
+	}
+	for _, currSession := range sessions {
+		a.sessionCache.Delete(currSession.Token)
+	}
+}
+
+// Delete all sessions of user from cache and DB. Returns the number of sessions deleted.
+// Other instances stop accepting the sessions once their cached copies are stale
+func (a *SessionService) RevokeUserSessions(ctx context.Context, userID uint) (int64, error) {
+	var existingSessions []Session
+	result := a.db.WithContext(ctx).Where("user_id = ?", userID).Find(&existingSessions)
+	if result.Error != nil {
+		a.logger.WithField("err", result.Error).Error("Error while querying for existing sessions")
+		return 0, result.Error
+	}
+	// Remove sessions from cache
+	for _, currSession := range existingSessions {
+		a.logger.WithField("token_hash", tokenhash.Hash(currSession.Token)).
+			Info("Removing session token from cache")
+		a.sessionCache.Delete(currSession.Token)
+	}
+	// Remove sessions from DB
+	result = a.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&Session{})
+	if result.Error != nil {
+		a.logger.WithField("err", result.Error).Error("Error occurred while removing existing sessions for user")
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
 // Delete session from cache and DB
 func (a *SessionService) DeleteSession(session Session) error {
 	a.sessionCache.Delete(session.Token)
@@ -0,0 +1,27 @@
+package suppression
+
+import "time"
+
+// Reasons for suppressing an address
+const (
+	ReasonBounce    = "bounce"    // Mail to address bounced permanently
+	ReasonComplaint = "complaint" // Recipient marked mail as spam
+)
+
+// EmailSuppression is an address that no mail is sent to
+type EmailSuppression struct {
+	ID        uint `gorm:"primarykey"`
+	Email     string
+	Reason    string
+	Details   string // Eg. diagnostic of the receiving mail server
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Notification is a bounce or complaint in the generic JSON format
+type Notification struct {
+	Type      string `json:"type"` // bounce or complaint
+	Email     string `json:"email"`
+	Permanent bool   `json:"permanent"` // Whether bounce is permanent. Transient bounces do not suppress the address
+	Details   string `json:"details"`
+}
@@ -0,0 +1,154 @@
+package suppression
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SuppressionService keeps the list of addresses that must not be emailed, fed by bounce and complaint notifications
+type SuppressionService struct {
+	db            *gorm.DB
+	logger        *logrus.Entry
+	webhookSecret string
+}
+
+func InitSuppressionService(db *gorm.DB, config *config.Config) *SuppressionService {
+	return &SuppressionService{
+		db:            db,
+		logger:        logger.GetLogger().WithField("module", "suppression_service"),
+		webhookSecret: config.Email.BounceWebhookSecret,
+	}
+}
+
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+func (s *SuppressionService) IsSuppressed(address string) (bool, error) {
+	var count int64
+	err := s.db.Model(&EmailSuppression{}).Where("email = ?", normalizeAddress(address)).Count(&count).Error
+	if err != nil {
+		s.logger.WithField("err", err).Error("Failed to query suppression list")
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Add address to suppression list. If it is already suppressed, its reason is updated
+func (s *SuppressionService) Suppress(ctx context.Context, address string, reason string, details string) error {
+	s.logger.WithFields(logrus.Fields{
+		"email":  address,
+		"reason": reason,
+	}).Warn("Suppressing email address")
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "email"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "details", "updated_at"}),
+	}).Create(&EmailSuppression{
+		Email:   normalizeAddress(address),
+		Reason:  reason,
+		Details: details,
+	}).Error
+	if err != nil {
+		s.logger.WithField("err", err).Error("Failed to add address to suppression list")
+	}
+	return err
+}
+
+// List suppressed addresses, most recent first. If email is set, only its suppression is returned
+func (s *SuppressionService) ListSuppressions(ctx context.Context, address string, limit int, offset int) ([]EmailSuppression, error) {
+	query := s.db.WithContext(ctx).Order("id DESC").Limit(limit).Offset(offset)
+	if address != "" {
+		query = query.Where("email = ?", normalizeAddress(address))
+	}
+	var suppressions []EmailSuppression
+	if err := query.Find(&suppressions).Error; err != nil {
+		return nil, err
+	}
+	return suppressions, nil
+}
+
+// Remove address from suppression list, so that it is emailed again
+func (s *SuppressionService) DeleteSuppression(ctx context.Context, id uint) error {
+	result := s.db.WithContext(ctx).Delete(&EmailSuppression{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return resperror.NewError(resperror.EmailSuppressionNotFoundError)
+	}
+	s.logger.WithField("id", id).Info("Removed address from suppression list")
+	return nil
+}
+
+// Handle bounce or complaint notification. JSON payloads are parsed as Notification, anything else as
+// delivery status notification (RFC 3464). Requests must carry the configured secret as bearer token
+func (s *SuppressionService) HandleNotification(ctx context.Context, payload []byte, header http.Header) error {
+	if s.webhookSecret == "" {
+		return resperror.NewError(resperror.BounceWebhookNotConfiguredError)
+	}
+	token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.webhookSecret)) != 1 {
+		return resperror.NewError(resperror.Unauthorized)
+	}
+
+	contentType := header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" {
+		var notification Notification
+		if err := json.Unmarshal(payload, &notification); err != nil || notification.Email == "" {
+			return resperror.NewError(resperror.InvalidBounceNotificationError)
+		}
+		return s.handleNotification(ctx, notification)
+	}
+
+	recipients, err := email.ParseDSN(contentType, bytes.NewReader(payload))
+	if err != nil {
+		s.logger.WithField("err", err).Warn("Failed to parse delivery status notification")
+		return resperror.NewError(resperror.InvalidBounceNotificationError)
+	}
+	for _, recipient := range recipients {
+		if recipient.Action != email.DSNActionFailed && recipient.Action != email.DSNActionDelayed {
+			continue
+		}
+		err := s.handleNotification(ctx, Notification{
+			Type:      ReasonBounce,
+			Email:     recipient.Recipient,
+			Permanent: recipient.Permanent(),
+			Details:   strings.TrimSpace(recipient.Status + " " + recipient.Diagnostic),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SuppressionService) handleNotification(ctx context.Context, notification Notification) error {
+	switch notification.Type {
+	case ReasonComplaint:
+		return s.Suppress(ctx, notification.Email, ReasonComplaint, notification.Details)
+	case ReasonBounce:
+		if notification.Permanent {
+			return s.Suppress(ctx, notification.Email, ReasonBounce, notification.Details)
+		}
+		s.logger.WithFields(logrus.Fields{
+			"email":   notification.Email,
+			"details": notification.Details,
+		}).Info("Ignoring transient bounce")
+		return nil
+	}
+	return resperror.NewError(resperror.InvalidBounceNotificationError)
+}
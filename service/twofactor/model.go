@@ -0,0 +1,32 @@
+package twofactor
+
+import "time"
+
+const (
+	totpSkew             = 1  // No. of time steps of clock drift allowed
+	recoveryCodeCount    = 10 // No. of recovery codes issued on enrollment
+	recoveryCodeBytes    = 5  // No. of random bytes in each recovery code
+	challengeTokenLength = 32
+	challengeValidity    = 5 // No. of minutes a login challenge is valid
+	maxChallengeAttempts = 5 // No. of codes that can be tried per login challenge
+)
+
+// RecoveryCode is a one-time code that can be used in place of a TOTP code
+type RecoveryCode struct {
+	ID        uint `gorm:"primarykey"`
+	UserID    uint
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// TwoFactorChallenge is issued after password authentication succeeds for a user with 2FA enabled,
+// and is exchanged together with a TOTP or recovery code for a session
+type TwoFactorChallenge struct {
+	ID        uint `gorm:"primarykey"`
+	UserID    uint
+	TokenHash string
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,335 @@
+package twofactor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/logger"
+	"github.com/dominiclet/golang-base/lib/encryption"
+	randgenerate "github.com/dominiclet/golang-base/lib/rand_generate"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"github.com/dominiclet/golang-base/lib/totp"
+	"github.com/dominiclet/golang-base/service/user"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type TwoFactorService struct {
+	db        *gorm.DB
+	encrypter *encryption.Encrypter
+	issuer    string
+	logger    *logrus.Entry
+}
+
+func InitTwoFactorService(db *gorm.DB, encrypter *encryption.Encrypter, config *config.Config) *TwoFactorService {
+	issuer := config.Security.TOTPIssuer
+	if issuer == "" {
+		issuer = config.Domain
+	}
+	t := &TwoFactorService{
+		db:        db,
+		encrypter: encrypter,
+		issuer:    issuer,
+		logger:    logger.GetLogger().WithField("module", "two_factor_service"),
+	}
+	if !encrypter.HasKey() {
+		t.logger.Warn("security.encryption_key not set, 2FA cannot be enabled or verified")
+	}
+	return t
+}
+
+// Starts 2FA enrollment by generating a new TOTP secret for the user.
+// Returns the secret and the otpauth URI to be shown to the user.
+// 2FA is only enabled after the enrollment is confirmed with a code
+func (t *TwoFactorService) BeginEnrollment(ctx context.Context, userID uint) (string, string, error) {
+	u, err := t.getUser(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if u.TOTPEnabled {
+		return "", "", resperror.NewError(resperror.TwoFactorAlreadyEnabledError)
+	}
+	if !t.encrypter.HasKey() {
+		return "", "", resperror.NewError(resperror.TwoFactorNotConfiguredError)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to generate TOTP secret")
+		return "", "", err
+	}
+	encryptedSecret, err := t.encrypter.Encrypt([]byte(secret))
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to encrypt TOTP secret")
+		return "", "", err
+	}
+	err = t.db.WithContext(ctx).Model(u).Update("totp_secret", encryptedSecret).Error
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to store TOTP secret")
+		return "", "", err
+	}
+
+	return secret, totp.KeyURI(t.issuer, u.Email, secret), nil
+}
+
+// Confirms 2FA enrollment with the first code generated from the secret, enabling 2FA.
+// Returns recovery codes which are only shown to the user once
+func (t *TwoFactorService) ConfirmEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	u, err := t.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabled {
+		return nil, resperror.NewError(resperror.TwoFactorAlreadyEnabledError)
+	}
+	if u.TOTPSecret == "" {
+		return nil, resperror.NewError(resperror.TwoFactorEnrollmentNotStartedError)
+	}
+	step, err := t.validateTOTP(u, code)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes := make([]string, recoveryCodeCount)
+	err = t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(u).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		// Replace any recovery codes from previous enrollments
+		if err := tx.Where("user_id = ?", u.ID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for i := range recoveryCodes {
+			recoveryCodes[i], err = generateRecoveryCode()
+			if err != nil {
+				return err
+			}
+			err = tx.Create(&RecoveryCode{
+				UserID:   u.ID,
+				CodeHash: tokenhash.Hash(normalizeRecoveryCode(recoveryCodes[i])),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to enable 2FA")
+		return nil, err
+	}
+
+	t.logger.WithField("user_uuid", u.Uuid).Info("Enabled 2FA for user")
+	return recoveryCodes, nil
+}
+
+// Disables 2FA for user after verifying a TOTP or recovery code
+func (t *TwoFactorService) Disable(ctx context.Context, userID uint, code string) error {
+	u, err := t.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !u.TOTPEnabled {
+		return resperror.NewError(resperror.TwoFactorNotEnabledError)
+	}
+	if err := t.verifyCode(ctx, u, code); err != nil {
+		return err
+	}
+	err = t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(u).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", u.ID).Delete(&RecoveryCode{}).Error
+	})
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to disable 2FA")
+		return err
+	}
+	t.logger.WithField("user_uuid", u.Uuid).Info("Disabled 2FA for user")
+	return nil
+}
+
+// Create a login challenge for user, returning the challenge token
+func (t *TwoFactorService) CreateChallenge(ctx context.Context, userID uint) (string, error) {
+	token, err := randgenerate.GenerateSecureToken(challengeTokenLength)
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to generate challenge token")
+		return "", err
+	}
+	err = t.db.WithContext(ctx).Create(&TwoFactorChallenge{
+		UserID:    userID,
+		TokenHash: tokenhash.Hash(token),
+		ExpiresAt: time.Now().Add(time.Minute * challengeValidity),
+	}).Error
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to store 2FA challenge")
+		return "", err
+	}
+	return token, nil
+}
+
+// User that challenge belongs to, without verifying a code (eg. to check if the user is locked out)
+func (t *TwoFactorService) ChallengeUser(ctx context.Context, challengeToken string) (*user.User, error) {
+	var challenge TwoFactorChallenge
+	err := t.db.WithContext(ctx).Where("token_hash = ?", tokenhash.Hash(challengeToken)).First(&challenge).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resperror.NewError(resperror.InvalidTokenError)
+		}
+		return nil, err
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, resperror.NewError(resperror.InvalidTokenError)
+	}
+	return t.getUser(ctx, challenge.UserID)
+}
+
+// Verify TOTP or recovery code for challenge, returning the user that the challenge belongs to.
+// Challenge is consumed on success, or once the maximum number of attempts is reached
+func (t *TwoFactorService) VerifyChallenge(ctx context.Context, challengeToken string, code string) (*user.User, error) {
+	var challenge TwoFactorChallenge
+	err := t.db.WithContext(ctx).Where("token_hash = ?", tokenhash.Hash(challengeToken)).First(&challenge).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resperror.NewError(resperror.InvalidTokenError)
+		}
+		return nil, err
+	}
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+		t.deleteChallenge(ctx, &challenge)
+		return nil, resperror.NewError(resperror.InvalidTokenError)
+	}
+	// Count attempt before verifying the code, so that concurrent attempts cannot exceed the maximum
+	result := t.db.WithContext(ctx).Model(&challenge).Where("attempts < ?", maxChallengeAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		t.logger.WithField("err", result.Error).Error("Failed to count 2FA attempt")
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		t.deleteChallenge(ctx, &challenge)
+		return nil, resperror.NewError(resperror.InvalidTokenError)
+	}
+
+	u, err := t.getUser(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.verifyCode(ctx, u, code); err != nil {
+		if challenge.Attempts+1 >= maxChallengeAttempts {
+			t.logger.WithField("user_uuid", u.Uuid).Warn("Maximum 2FA attempts reached for challenge")
+			t.deleteChallenge(ctx, &challenge)
+		}
+		return nil, err
+	}
+
+	t.deleteChallenge(ctx, &challenge)
+	return u, nil
+}
+
+// Verify TOTP code, falling back to recovery codes
+func (t *TwoFactorService) verifyCode(ctx context.Context, u *user.User, code string) error {
+	step, totpErr := t.validateTOTP(u, code)
+	if totpErr == nil {
+		// Only record step if it is newer than the last used step, so that a code used concurrently is rejected
+		result := t.db.WithContext(ctx).Model(u).Where("totp_last_step < ?", step).Update("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return resperror.NewError(resperror.InvalidTwoFactorCodeError)
+		}
+		return nil
+	}
+
+	// Try code as a recovery code
+	var recoveryCode RecoveryCode
+	err := t.db.WithContext(ctx).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", u.ID, tokenhash.Hash(normalizeRecoveryCode(code))).
+		First(&recoveryCode).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if resperror.HasCode(totpErr, resperror.TwoFactorNotConfiguredError) {
+				return totpErr
+			}
+			return resperror.NewError(resperror.InvalidTwoFactorCodeError)
+		}
+		return err
+	}
+	now := time.Now()
+	result := t.db.WithContext(ctx).Model(&recoveryCode).Where("used_at IS NULL").Update("used_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Recovery code was used concurrently
+		return resperror.NewError(resperror.InvalidTwoFactorCodeError)
+	}
+	t.logger.WithField("user_uuid", u.Uuid).Info("Recovery code used")
+	return nil
+}
+
+// Validate TOTP code against user's secret, returning the matched time step
+func (t *TwoFactorService) validateTOTP(u *user.User, code string) (int64, error) {
+	if !t.encrypter.HasKey() {
+		return 0, resperror.NewError(resperror.TwoFactorNotConfiguredError)
+	}
+	secret, err := t.encrypter.Decrypt(u.TOTPSecret)
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to decrypt TOTP secret")
+		return 0, err
+	}
+	step, ok := totp.Validate(string(secret), code, time.Now(), totpSkew)
+	// Reject codes that have already been used
+	if !ok || step <= u.TOTPLastStep {
+		return 0, resperror.NewError(resperror.InvalidTwoFactorCodeError)
+	}
+	return step, nil
+}
+
+func (t *TwoFactorService) getUser(ctx context.Context, userID uint) (*user.User, error) {
+	var u user.User
+	err := t.db.WithContext(ctx).First(&u, userID).Error
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to query user by ID")
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (t *TwoFactorService) deleteChallenge(ctx context.Context, challenge *TwoFactorChallenge) {
+	err := t.db.WithContext(ctx).Delete(challenge).Error
+	if err != nil {
+		t.logger.WithField("err", err).Error("Failed to delete 2FA challenge")
+	}
+}
+
+// Generate recovery code in the format xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(b)
+	return code[:len(code)/2] + "-" + code[len(code)/2:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,110 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Operations for operators (eg. from the CLI). These skip the emails and tokens of the user-facing flows
+
+// Creates a user whose email is already verified, so no verification email is sent
+func (u *UserService) CreateVerifiedUser(ctx context.Context, name string,
+	email string, password string, language string) (*User, error) {
+	if err := u.validatePassword(password, email, name); err != nil {
+		return nil, err
+	}
+	err := u.db.WithContext(ctx).Where("email = ?", email).First(&User{}).Error
+	if err == nil {
+		return nil, resperror.NewError(resperror.UserAlreadyExistsError)
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"name":  name,
+		"email": email,
+	}).Info("Creating verified user")
+
+	hashedPassword, err := u.hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	newUser := User{
+		Name:          name,
+		Uuid:          uuid.NewString(),
+		Email:         email,
+		Password:      hashedPassword,
+		AccountType:   TestAccount, // TODO: Change test account to trial account after beta test
+		IsVerified:    true,
+		LicenseExpiry: time.Now().Add(time.Hour * 24 * TRIAL_DURATION_DAYS),
+		Language:      language,
+	}
+	if err := u.db.WithContext(ctx).Create(&newUser).Error; err != nil {
+		return nil, err
+	}
+	return &newUser, nil
+}
+
+// Marks email of user as verified without a verification token
+func (u *UserService) MarkVerified(ctx context.Context, user *User) error {
+	user.IsVerified = true
+	user.VerificationToken = ""
+	err := u.db.WithContext(ctx).Model(user).Select("is_verified", "verification_token").Updates(*user).Error
+	if err != nil {
+		u.logger.WithField("err", err).Error("Error updating verified status")
+		return err
+	}
+	u.resendEmailDisabled.Delete(user.ID)
+	return nil
+}
+
+// Sets password of user without a reset token. Password must satisfy the password policy.
+// Outstanding password reset tokens are invalidated and password change hooks are run
+func (u *UserService) SetPassword(ctx context.Context, user *User, password string) error {
+	if err := u.validatePassword(password, user.Email, user.Name); err != nil {
+		return err
+	}
+	hashedPassword, err := u.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+
+	err = u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Select("password").Updates(*user).Error; err != nil {
+			return err
+		}
+		return u.invalidateResetTokens(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+	return u.passwordChanged(ctx, user.ID)
+}
+
+// Sets account type and license expiry of user
+func (u *UserService) SetLicense(ctx context.Context, user *User, accountType AccountType, expiry time.Time) error {
+	user.AccountType = accountType
+	user.LicenseExpiry = expiry
+	err := u.db.WithContext(ctx).Model(user).Select("account_type", "license_expiry").Updates(*user).Error
+	if err != nil {
+		u.logger.WithField("err", err).Error("Failed to update license of user")
+	}
+	return err
+}
+
+// Grants or revokes admin rights of user
+func (u *UserService) SetAdmin(ctx context.Context, user *User, isAdmin bool) error {
+	user.IsAdmin = isAdmin
+	err := u.db.WithContext(ctx).Model(user).Select("is_admin").Updates(*user).Error
+	if err != nil {
+		u.logger.WithField("err", err).Error("Failed to update admin status of user")
+	}
+	return err
+}
@@ -1,5 +1,7 @@
 package user
 
+import "time"
+
 type AccountType int
 
 const (
@@ -28,3 +30,22 @@ const (
 	EmailVerificationTokenLength     = 16
 	verificationEmailDisableDuration = 2 // minutes
 )
+
+// Purposes of password reset tokens
+const (
+	resetPurposeCode     = "code"      // Short code the user types in
+	resetPurposeLink     = "link"      // Long token in single-click reset link
+	resetPurposeAuthCode = "auth_code" // Issued in exchange for code or link token, authorizes setting new password
+)
+
+// PasswordResetToken is a single-use token of the password reset flow
+type PasswordResetToken struct {
+	ID        uint `gorm:"primarykey"`
+	UserID    uint
+	Purpose   string
+	TokenHash string
+	Attempts  int // No. of failed attempts to use token
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
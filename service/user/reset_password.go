@@ -2,109 +2,219 @@ package user
 
 import (
 	"context"
-	"errors"
+	"crypto/subtle"
 	"time"
 
 	randgenerate "github.com/dominiclet/golang-base/lib/rand_generate"
-	"github.com/sirupsen/logrus"
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/lib/tokenhash"
+	"gorm.io/gorm"
 )
 
 const (
-	tokenLength      = 6
-	authCodeLength   = 16
-	tokenValidity    = 5 // No. of minutes where reset pw token is valid
-	authCodeValidity = 2 // No. of minutes where reset pw auth code is valid
+	resetCodeLength        = 6
+	resetLinkTokenLength   = 32
+	authCodeLength         = 16
+	resetTokenValidity     = 15 // No. of minutes where reset pw code and link are valid
+	authCodeValidity       = 10 // No. of minutes where reset pw auth code is valid
+	maxResetTokenAttempts  = 5  // No. of failed attempts after which a reset token is invalidated
+	resetRateWindow        = 15 // No. of minutes over which reset requests are rate limited
+	maxResetsPerRateWindow = 3  // No. of resets that can be requested per account within rate window
 )
 
 // Sends initial request to reset password for user with email
-// Sends an email to the user with reset token if account with email exists
+// Sends an email to the user with reset code (and single-click reset link if configured) if account with email exists.
+// Any reset tokens previously issued to the user are invalidated
 func (u *UserService) ResetPassword(ctx context.Context, email string) error {
 	user, err := u.GetUserByEmail(ctx, email)
 	if err != nil {
 		return err
 	}
-	token := randgenerate.GenerateAlphaNumericString(tokenLength)
-	u.resetPwTokens.Set(user.Email, token)
-	go func() {
-		// Remove token after token validity period has passed
-		time.Sleep(time.Minute * tokenValidity)
-		u.resetPwTokens.Delete(user.Email)
-	}()
-
-	// Send reset password token asynchronously
-	go func() {
-		err = u.emailService.SendResetPasswordToken(user.Email, token)
+
+	var recentCount int64
+	err = u.db.WithContext(ctx).Model(&PasswordResetToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?",
+			user.ID, resetPurposeCode, time.Now().Add(-time.Minute*resetRateWindow)).
+		Count(&recentCount).Error
+	if err != nil {
+		return err
+	}
+	if recentCount >= maxResetsPerRateWindow {
+		u.logger.WithField("email", user.Email).Warn("Reset password rate limit reached")
+		return resperror.NewError(resperror.TooManyRequests)
+	}
+
+	code, err := randgenerate.GenerateAlphaNumericString(resetCodeLength)
+	if err != nil {
+		return err
+	}
+	linkToken := ""
+	if u.emailService.ResetPasswordLinkEnabled() {
+		linkToken, err = randgenerate.GenerateSecureToken(resetLinkTokenLength)
 		if err != nil {
-			u.logger.WithField("err", err).Error("Failed to send reset password email")
+			return err
 		}
-	}()
+	}
+	msg, err := u.emailService.ResetPasswordEmail(user.Email, user.Language, code, linkToken)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		if err := u.invalidateResetTokens(ctx, tx, user.ID); err != nil {
+			return err
+		}
+		if err := u.storeResetToken(ctx, tx, user.ID, resetPurposeCode, code, resetTokenValidity); err != nil {
+			return err
+		}
+		if linkToken != "" {
+			if err := u.storeResetToken(ctx, tx, user.ID, resetPurposeLink, linkToken, resetTokenValidity); err != nil {
+				return err
+			}
+		}
+		return u.outboxService.EnqueueUntil(ctx, tx, msg, time.Now().Add(time.Minute*resetTokenValidity))
+	})
 }
 
+// Exchanges reset code or token from reset link for an auth code that authorizes setting a new password
 func (u *UserService) ResetPwAuthCodeExchange(ctx context.Context, token string, email string) (string, error) {
-	u.logger.WithFields(logrus.Fields{
-		"token": token,
-		"email": email,
-	}).Info("Exchanging reset pw token for auth code")
-	storedToken, err := u.resetPwTokens.Get(email)
+	u.logger.WithField("email", email).Info("Exchanging reset pw token for auth code")
+	user, err := u.GetUserByEmail(ctx, email)
 	if err != nil {
-		return "", errors.New("No token stored for provided email")
+		return "", resperror.NewError(resperror.InvalidTokenError)
 	}
 
-	// Check if provided token matches stored token
-	if storedToken != token {
-		u.logger.WithFields(logrus.Fields{
-			"want": storedToken,
-			"got":  token,
-		}).Error("Token mismatch")
-		return "", errors.New("Token mismatch")
+	if err := u.consumeResetToken(ctx, user.ID, token, resetPurposeCode, resetPurposeLink); err != nil {
+		return "", err
+	}
+	// Code and link are alternatives, so both are used up once either is
+	if err := u.invalidateResetTokens(ctx, u.db, user.ID); err != nil {
+		return "", err
 	}
-
-	u.resetPwTokens.Delete(email)
 
 	authCode, err := randgenerate.GenerateSecureToken(authCodeLength)
 	if err != nil {
 		u.logger.WithField("err", err).Error("Failed to generate auth code")
 		return "", err
 	}
-
-	u.resetPwAuthCodes.Set(email, authCode)
-	go func() {
-		// Remove auth code after auth code validity period has passed
-		time.Sleep(time.Minute * authCodeValidity)
-		u.resetPwAuthCodes.Delete(email)
-	}()
-
+	if err := u.storeResetToken(ctx, u.db, user.ID, resetPurposeAuthCode, authCode, authCodeValidity); err != nil {
+		return "", err
+	}
 	return authCode, nil
 }
 
 func (u *UserService) SetNewPassword(ctx context.Context, email string, authCode string, newPassword string) error {
 	u.logger.WithField("email", email).Info("Setting new password")
+	user, err := u.GetUserByEmail(ctx, email)
+	if err != nil {
+		return resperror.NewError(resperror.Unauthorized)
+	}
 
-	storedAuthCode, err := u.resetPwAuthCodes.Get(email)
+	// Auth code is only used up once the new password is accepted, so that user can retry with another password
+	resetToken, err := u.findResetToken(ctx, user.ID, authCode, resetPurposeAuthCode)
+	if err != nil {
+		return err
+	}
+	if err := u.validatePassword(newPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+	hashedPassword, err := u.hashPassword(newPassword)
 	if err != nil {
-		return errors.New("No auth code stored for provided email")
+		return err
 	}
+	user.Password = hashedPassword
 
-	// Check if provided auth code matches stored auth code
-	if storedAuthCode != authCode {
-		u.logger.Error("Auth code mismatch")
-		return errors.New("Auth code mismatch")
+	// Auth code is only used up if the password is updated, and the password is only updated once
+	err = u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := u.markResetTokenUsed(ctx, tx, resetToken); err != nil {
+			return err
+		}
+		return tx.Model(user).Select("password").Updates(*user).Error
+	})
+	if err != nil {
+		return err
 	}
+	// Log out sessions and revoke tokens that may have been obtained with the old password
+	return u.passwordChanged(ctx, user.ID)
+}
 
-	u.resetPwAuthCodes.Delete(email)
+func (u *UserService) storeResetToken(ctx context.Context, tx *gorm.DB, userID uint, purpose string, token string,
+	validityMins int) error {
+	err := tx.WithContext(ctx).Create(&PasswordResetToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: tokenhash.Hash(token),
+		ExpiresAt: time.Now().Add(time.Minute * time.Duration(validityMins)),
+		CreatedAt: time.Now(),
+	}).Error
+	if err != nil {
+		u.logger.WithField("err", err).Error("Failed to store reset password token")
+	}
+	return err
+}
 
-	// Update user with new password
-	user, err := u.GetUserByEmail(ctx, email)
+// Find active reset token of user with one of the given purposes that matches token.
+// If none matches, a failed attempt is counted against the active tokens, invalidating them after too many attempts
+func (u *UserService) findResetToken(ctx context.Context, userID uint, token string, purposes ...string) (*PasswordResetToken, error) {
+	var resetTokens []PasswordResetToken
+	err := u.db.WithContext(ctx).
+		Where("user_id = ? AND purpose IN ? AND used_at IS NULL AND expires_at > ?", userID, purposes, time.Now()).
+		Find(&resetTokens).Error
 	if err != nil {
-		return err
+		return nil, err
 	}
-	hashedPassword, err := u.hashPassword(newPassword)
+
+	tokenHash := tokenhash.Hash(token)
+	for i := range resetTokens {
+		if subtle.ConstantTimeCompare([]byte(tokenHash), []byte(resetTokens[i].TokenHash)) == 1 {
+			return &resetTokens[i], nil
+		}
+	}
+
+	if len(resetTokens) > 0 {
+		u.logger.WithField("user_id", userID).Warn("Reset password token mismatch")
+		ids := make([]uint, len(resetTokens))
+		for i := range resetTokens {
+			ids[i] = resetTokens[i].ID
+		}
+		err := u.db.WithContext(ctx).Model(&PasswordResetToken{}).Where("id IN ?", ids).
+			Update("attempts", gorm.Expr("attempts + 1")).Error
+		if err != nil {
+			return nil, err
+		}
+		err = u.db.WithContext(ctx).Model(&PasswordResetToken{}).
+			Where("id IN ? AND attempts >= ? AND used_at IS NULL", ids, maxResetTokenAttempts).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, resperror.NewError(resperror.InvalidTokenError)
+}
+
+// Find and use up matching reset token
+func (u *UserService) consumeResetToken(ctx context.Context, userID uint, token string, purposes ...string) error {
+	resetToken, err := u.findResetToken(ctx, userID, token, purposes...)
 	if err != nil {
 		return err
 	}
-	user.Password = hashedPassword
+	return u.markResetTokenUsed(ctx, u.db, resetToken)
+}
+
+// Mark token as used. Fails if token was used concurrently
+func (u *UserService) markResetTokenUsed(ctx context.Context, tx *gorm.DB, resetToken *PasswordResetToken) error {
+	result := tx.WithContext(ctx).Model(resetToken).Where("used_at IS NULL").Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return resperror.NewError(resperror.InvalidTokenError)
+	}
+	return nil
+}
 
-	return u.db.Model(user).Select("password").Updates(*user).Error
+func (u *UserService) invalidateResetTokens(ctx context.Context, tx *gorm.DB, userID uint) error {
+	return tx.WithContext(ctx).Model(&PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
 }
@@ -0,0 +1,182 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dominiclet/golang-base/lib/resperror"
+	"github.com/dominiclet/golang-base/service/outbox"
+	"gorm.io/gorm"
+)
+
+func activeResetTokens(t *testing.T, db *gorm.DB, user *User) []PasswordResetToken {
+	var tokens []PasswordResetToken
+	err := db.Where("user_id = ? AND used_at IS NULL AND expires_at > ?", user.ID, time.Now()).Find(&tokens).Error
+	if err != nil {
+		t.Fatalf("Failed to query reset tokens: %v", err)
+	}
+	return tokens
+}
+
+func storeTestResetToken(t *testing.T, u *UserService, db *gorm.DB, user *User, purpose string, token string) {
+	if err := u.storeResetToken(context.Background(), db, user.ID, purpose, token, resetTokenValidity); err != nil {
+		t.Fatalf("Failed to store reset token: %v", err)
+	}
+}
+
+func TestResetPasswordRateLimit(t *testing.T) {
+	u, db := newTestUserService(t)
+	user := createTestUser(t, u, db, "correct-horse7")
+
+	for i := 1; i <= maxResetsPerRateWindow; i++ {
+		if err := u.ResetPassword(context.Background(), user.Email); err != nil {
+			t.Fatalf("Reset %d failed: %v", i, err)
+		}
+		// Earlier codes are invalidated by each new reset
+		if tokens := activeResetTokens(t, db, user); len(tokens) != 1 {
+			t.Fatalf("%d active reset tokens after reset %d, want 1", len(tokens), i)
+		}
+	}
+	if err := u.ResetPassword(context.Background(), user.Email); !resperror.HasCode(err, resperror.TooManyRequests) {
+		t.Fatalf("Got error %v, want TooManyRequests", err)
+	}
+
+	var messages []outbox.OutboxMessage
+	if err := db.Find(&messages).Error; err != nil {
+		t.Fatalf("Failed to query outbox: %v", err)
+	}
+	if len(messages) != maxResetsPerRateWindow {
+		t.Fatalf("%d reset emails queued, want %d", len(messages), maxResetsPerRateWindow)
+	}
+	for _, msg := range messages {
+		if msg.ExpiresAt == nil || msg.ExpiresAt.After(time.Now().Add(time.Minute*resetTokenValidity)) {
+			t.Errorf("Reset email expires at %v, want within token validity", msg.ExpiresAt)
+		}
+	}
+
+	// Resets outside the window are not counted
+	err := db.Model(&PasswordResetToken{}).Where("user_id = ?", user.ID).
+		Update("created_at", time.Now().Add(-time.Minute*(resetRateWindow+1))).Error
+	if err != nil {
+		t.Fatalf("Failed to update reset tokens: %v", err)
+	}
+	if err := u.ResetPassword(context.Background(), user.Email); err != nil {
+		t.Errorf("Reset after rate window failed: %v", err)
+	}
+}
+
+func TestResetPwAuthCodeExchangeAttempts(t *testing.T) {
+	tests := []struct {
+		name           string
+		failedAttempts int
+		wantErr        bool
+	}{
+		{"Below limit", maxResetTokenAttempts - 1, false},
+		{"Limit reached", maxResetTokenAttempts, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, db := newTestUserService(t)
+			user := createTestUser(t, u, db, "correct-horse7")
+			storeTestResetToken(t, u, db, user, resetPurposeCode, "ABC123")
+
+			for i := 0; i < tt.failedAttempts; i++ {
+				_, err := u.ResetPwAuthCodeExchange(context.Background(), "XYZ789", user.Email)
+				if !resperror.HasCode(err, resperror.InvalidTokenError) {
+					t.Fatalf("Wrong code attempt %d: got error %v, want InvalidTokenError", i+1, err)
+				}
+			}
+			authCode, err := u.ResetPwAuthCodeExchange(context.Background(), "ABC123", user.Email)
+			if tt.wantErr {
+				if !resperror.HasCode(err, resperror.InvalidTokenError) {
+					t.Errorf("Got error %v, want InvalidTokenError", err)
+				}
+			} else if err != nil || authCode == "" {
+				t.Errorf("Exchange failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestResetPwAuthCodeExchangeExpired(t *testing.T) {
+	u, db := newTestUserService(t)
+	user := createTestUser(t, u, db, "correct-horse7")
+	storeTestResetToken(t, u, db, user, resetPurposeCode, "ABC123")
+	err := db.Model(&PasswordResetToken{}).Where("user_id = ?", user.ID).
+		Update("expires_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		t.Fatalf("Failed to update reset token: %v", err)
+	}
+
+	if _, err := u.ResetPwAuthCodeExchange(context.Background(), "ABC123", user.Email); !resperror.HasCode(err, resperror.InvalidTokenError) {
+		t.Errorf("Got error %v, want InvalidTokenError", err)
+	}
+}
+
+func TestResetTokensSingleUse(t *testing.T) {
+	u, db := newTestUserService(t)
+	user := createTestUser(t, u, db, "correct-horse7")
+	var changed []uint
+	u.OnPasswordChange(func(ctx context.Context, userID uint) error {
+		changed = append(changed, userID)
+		return nil
+	})
+	storeTestResetToken(t, u, db, user, resetPurposeCode, "ABC123")
+	storeTestResetToken(t, u, db, user, resetPurposeLink, "link-token")
+
+	authCode, err := u.ResetPwAuthCodeExchange(context.Background(), "ABC123", user.Email)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	// Code and link are both used up
+	for _, token := range []string{"ABC123", "link-token"} {
+		if _, err := u.ResetPwAuthCodeExchange(context.Background(), token, user.Email); !resperror.HasCode(err, resperror.InvalidTokenError) {
+			t.Errorf("Reusing %q: got error %v, want InvalidTokenError", token, err)
+		}
+	}
+
+	// Rejected password does not use up auth code
+	err = u.SetNewPassword(context.Background(), user.Email, authCode, "short")
+	if !resperror.HasCode(err, resperror.PasswordPolicyError) {
+		t.Fatalf("Got error %v, want PasswordPolicyError", err)
+	}
+	if err := u.SetNewPassword(context.Background(), user.Email, authCode, "new-horse-8"); err != nil {
+		t.Fatalf("Set new password failed: %v", err)
+	}
+	if ok, err := u.CheckPassword(context.Background(), reloadUser(t, db, user), "new-horse-8"); err != nil || !ok {
+		t.Errorf("New password not set: ok %v, err %v", ok, err)
+	}
+	if len(changed) != 1 || changed[0] != user.ID {
+		t.Errorf("Password change hooks ran for %v, want [%d]", changed, user.ID)
+	}
+
+	err = u.SetNewPassword(context.Background(), user.Email, authCode, "other-horse-9")
+	if !resperror.HasCode(err, resperror.InvalidTokenError) {
+		t.Errorf("Reusing auth code: got error %v, want InvalidTokenError", err)
+	}
+	if ok, _ := u.CheckPassword(context.Background(), reloadUser(t, db, user), "other-horse-9"); ok {
+		t.Error("Password changed with used auth code")
+	}
+}
+
+// Auth code found by a concurrent request before it was used cannot be used again
+func TestSetNewPasswordConcurrentUse(t *testing.T) {
+	u, db := newTestUserService(t)
+	user := createTestUser(t, u, db, "correct-horse7")
+	storeTestResetToken(t, u, db, user, resetPurposeAuthCode, "auth-code")
+	resetToken, err := u.findResetToken(context.Background(), user.ID, "auth-code", resetPurposeAuthCode)
+	if err != nil {
+		t.Fatalf("Failed to find auth code: %v", err)
+	}
+
+	if err := u.SetNewPassword(context.Background(), user.Email, "auth-code", "new-horse-8"); err != nil {
+		t.Fatalf("Set new password failed: %v", err)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return u.markResetTokenUsed(context.Background(), tx, resetToken)
+	})
+	if !resperror.HasCode(err, resperror.InvalidTokenError) {
+		t.Errorf("Got error %v, want InvalidTokenError", err)
+	}
+}
@@ -2,16 +2,17 @@ package user
 
 import (
 	"context"
-	"errors"
+	"crypto/subtle"
 	"time"
 
 	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/password"
 	randgenerate "github.com/dominiclet/golang-base/lib/rand_generate"
 	"github.com/dominiclet/golang-base/lib/resperror"
 	"github.com/dominiclet/golang-base/lib/store"
+	"github.com/dominiclet/golang-base/service/outbox"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -25,28 +26,60 @@ type User struct {
 	LicenseExpiry     time.Time
 	IsVerified        bool
 	VerificationToken string
+	TOTPSecret        string `gorm:"column:totp_secret"`    // Encrypted TOTP secret (set once 2FA enrollment starts)
+	TOTPEnabled       bool   `gorm:"column:totp_enabled"`   // Whether 2FA enrollment has been confirmed
+	TOTPLastStep      int64  `gorm:"column:totp_last_step"` // Time step of last accepted TOTP code (to reject reuse)
+	IsAdmin           bool
+	Language          string // Preferred language (BCP 47 tag) of emails. Default language is used if empty
 }
 
 type UserService struct {
 	db                  *gorm.DB
 	logger              *logrus.Entry
 	emailService        *email.EmailService
-	resetPwTokens       *store.Store[string, string] // Maps email to generated reset token (reset tokens are tokens sent to email on reset request)
-	resetPwAuthCodes    *store.Store[string, string] // Maps email to generate auth codes (auth codes are codes used to authorize a pw change API request)
-	resendEmailDisabled *store.Store[uint, bool]     // Set of user IDs that cannot request verification email to be resent
+	outboxService       *outbox.OutboxService
+	passwordPolicy      *password.Policy
+	passwordHasher      *password.Hasher
+	resendEmailDisabled *store.Store[uint, bool] // Set of user IDs that cannot request verification email to be resent
+	passwordChangeHooks []PasswordChangeHook
 }
 
-func InitUserService(db *gorm.DB, emailService *email.EmailService) *UserService {
+// Called after the password of a user has changed, to revoke credentials that were obtained with the old password
+type PasswordChangeHook func(ctx context.Context, userID uint) error
+
+func InitUserService(db *gorm.DB, emailService *email.EmailService, outboxService *outbox.OutboxService,
+	passwordPolicy *password.Policy, passwordHasher *password.Hasher) *UserService {
 	return &UserService{
 		db:                  db,
 		logger:              logrus.WithField("module", "user_service"),
 		emailService:        emailService,
-		resetPwTokens:       store.NewStore[string, string](),
-		resetPwAuthCodes:    store.NewStore[string, string](),
+		outboxService:       outboxService,
+		passwordPolicy:      passwordPolicy,
+		passwordHasher:      passwordHasher,
 		resendEmailDisabled: store.NewStore[uint, bool](),
 	}
 }
 
+// Register hook to run after the password of a user has changed. Services that the user service
+// cannot depend on (eg. sessions) register their hooks when they are initialized
+func (u *UserService) OnPasswordChange(hook PasswordChangeHook) {
+	u.passwordChangeHooks = append(u.passwordChangeHooks, hook)
+}
+
+// Run password change hooks. All hooks are run even if one fails, and the first error is returned
+func (u *UserService) passwordChanged(ctx context.Context, userID uint) error {
+	var firstErr error
+	for _, hook := range u.passwordChangeHooks {
+		if err := hook(ctx, userID); err != nil {
+			u.logger.WithField("err", err).Error("Failed to revoke credentials after password change")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
 // Get user by UUID
 func (u *UserService) GetUserByUuid(ctx context.Context, uuid string) (*User, error) {
 	var user User
@@ -60,7 +93,11 @@ func (u *UserService) GetUserByUuid(ctx context.Context, uuid string) (*User, er
 
 // Creates a user. Will hash provided password before storing into DB
 func (u *UserService) CreateUser(ctx context.Context, name string,
-	email string, password string) (*User, error) {
+	email string, password string, language string) (*User, error) {
+	if err := u.validatePassword(password, email, name); err != nil {
+		return nil, err
+	}
+
 	// Check if email already exists
 	err := u.db.Where("email = ?", email).First(&User{}).Error
 	if err == nil {
@@ -94,19 +131,51 @@ func (u *UserService) CreateUser(ctx context.Context, name string,
 		AccountType:   TestAccount, // TODO: Change test account to trial account after beta test
 		IsVerified:    false,       // Newly created user is unverified by default
 		LicenseExpiry: licenseExpiry,
+		Language:      language,
 	}
 
-	tx := u.db.Create(&newUser)
-	if tx.Error != nil {
-		return nil, tx.Error
+	// User is only created if verification email is queued
+	err = u.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		_, err := u.sendVerificationEmail(ctx, tx, &newUser)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Send verification email (asynchronously)
-	_, err = u.sendVerificationEmail(ctx, newUser.Email, newUser.Uuid, newUser.ID)
-	if err != nil {
+	return &newUser, nil
+}
+
+// Creates a user authenticated by an external identity provider.
+// User has no password and is verified as the provider has verified the email
+func (u *UserService) CreateExternalUser(ctx context.Context, name string, email string) (*User, error) {
+	err := u.db.Where("email = ?", email).First(&User{}).Error
+	if err == nil {
+		return nil, resperror.NewError(resperror.UserAlreadyExistsError)
+	}
+	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, err
 	}
 
+	u.logger.WithFields(logrus.Fields{
+		"name":  name,
+		"email": email,
+	}).Info("Creating external user")
+
+	newUser := User{
+		Name:          name,
+		Uuid:          uuid.NewString(),
+		Email:         email,
+		AccountType:   TestAccount, // TODO: Change test account to trial account after beta test
+		IsVerified:    true,
+		LicenseExpiry: time.Now().Add(time.Hour * 24 * TRIAL_DURATION_DAYS),
+	}
+	if err := u.db.Create(&newUser).Error; err != nil {
+		return nil, err
+	}
 	return &newUser, nil
 }
 
@@ -120,15 +189,13 @@ func (u *UserService) ResendVerificationEmail(ctx context.Context, email string,
 	}
 	// Do not resend email if it is temporarily disabled
 	if _, err := u.resendEmailDisabled.Get(user.ID); err == nil {
-		return NewSendVerificationEmailErr(ResendDisabled,
-			"Please wait for a period of time before trying to resend verification email")
+		return resperror.NewError(resperror.TooManyRequests).WithInternal("Resending verification email is disabled")
 	}
 	// Check if user is already verified
 	if user.IsVerified {
-		return NewSendVerificationEmailErr(UserVerified,
-			"User is already verified")
+		return resperror.NewError(resperror.UserAlreadyVerifiedError)
 	}
-	_, err = u.sendVerificationEmail(ctx, user.Email, user.Uuid, user.ID)
+	_, err = u.sendVerificationEmail(ctx, u.db, user)
 	if err != nil {
 		return err
 	}
@@ -150,36 +217,31 @@ func (u *UserService) disableResendVerification(ctx context.Context, userId uint
 	}()
 }
 
-// Generate verification token and send email
-func (u *UserService) sendVerificationEmail(ctx context.Context, email string, userUUID string, userId uint) (string, error) {
-	user := User{
-		Model: gorm.Model{ID: userId},
-	}
-
+// Generate verification token and queue verification email within tx
+func (u *UserService) sendVerificationEmail(ctx context.Context, tx *gorm.DB, user *User) (string, error) {
 	// Generate verification token
 	verificationToken, err := randgenerate.GenerateSecureToken(EmailVerificationTokenLength)
 	if err != nil {
 		u.logger.WithField("err", err).Error("Failed to generate email verification token")
 		return "", err
 	}
-
-	// Save verification token in db
-	err = u.db.Model(&user).Update("verification_token", verificationToken).Error
+	msg, err := u.emailService.VerificationEmail(user.Email, user.Language, user.Uuid, verificationToken)
 	if err != nil {
-		u.logger.WithField("err", err).Error("Failed to store verification token in DB")
-		return "", nil
+		return "", err
 	}
 
-	// Send verification email
-	go func() {
-		err := u.emailService.SendVerificationEmail(email, userUUID, verificationToken)
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		// Save verification token in db
+		err := tx.Model(&User{Model: gorm.Model{ID: user.ID}}).Update("verification_token", verificationToken).Error
 		if err != nil {
-			u.logger.WithFields(logrus.Fields{
-				"err":   err,
-				"email": email,
-			}).Error("Failed to send verification email")
+			u.logger.WithField("err", err).Error("Failed to store verification token in DB")
+			return err
 		}
-	}()
+		return u.outboxService.Enqueue(ctx, tx, msg)
+	})
+	if err != nil {
+		return "", err
+	}
 
 	return verificationToken, nil
 }
@@ -194,12 +256,9 @@ func (u *UserService) VerifyEmail(ctx context.Context, userUUID string, verifica
 	if user.IsVerified {
 		return nil
 	}
-	if user.VerificationToken != verificationToken {
-		u.logger.WithFields(logrus.Fields{
-			"want": user.VerificationToken,
-			"have": verificationToken,
-		}).Error("Verification token mismatch")
-		return errors.New("Verification token mismatch")
+	if user.VerificationToken == "" ||
+		subtle.ConstantTimeCompare([]byte(user.VerificationToken), []byte(verificationToken)) != 1 {
+		return resperror.NewError(resperror.InvalidTokenError).WithInternal("Verification token mismatch")
 	}
 	user.IsVerified = true
 	user.VerificationToken = ""
@@ -222,20 +281,70 @@ func (u *UserService) GetUserByEmail(ctx context.Context, email string) (*User,
 	return &user, nil
 }
 
+// Set preferred language of user (BCP 47 tag, or empty for default language)
+func (u *UserService) SetLanguage(ctx context.Context, user *User, language string) error {
+	err := u.db.WithContext(ctx).Model(user).Update("language", language).Error
+	if err != nil {
+		u.logger.WithField("err", err).Error("Failed to update language of user")
+	}
+	return err
+}
+
 func (u *UserService) GetUserById(ctx context.Context, id uint) (*User, error) {
 	var user User
-	err := u.db.First(user, id).Error
+	err := u.db.First(&user, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// Check password against password policy, returning the violated rules as error details
+func (u *UserService) validatePassword(password string, email string, name string) error {
+	violations := u.passwordPolicy.Validate(password, email, name)
+	if len(violations) > 0 {
+		return resperror.NewError(resperror.PasswordPolicyError).WithDetails(violations)
+	}
+	return nil
+}
+
 func (u *UserService) hashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	hashedPassword, err := u.passwordHasher.Hash(password)
 	if err != nil {
 		logrus.WithField("err", err).Error("Failed to generate hash from password")
 		return "", err
 	}
-	return string(hashedBytes), nil
+	return hashedPassword, nil
+}
+
+// Check password of user. If the password is correct but its hash is outdated (eg. hashed with bcrypt
+// or older parameters), the password is rehashed with the current settings.
+// Users without a password (or a nil user) never match
+func (u *UserService) CheckPassword(ctx context.Context, user *User, password string) (bool, error) {
+	hash := ""
+	if user != nil {
+		hash = user.Password
+	}
+	ok, needsRehash, err := u.passwordHasher.Verify(password, hash)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if needsRehash {
+		hashedPassword, err := u.hashPassword(password)
+		if err != nil {
+			return true, nil
+		}
+		// Only replace the hash that was verified, in case the password was changed concurrently
+		result := u.db.WithContext(ctx).Model(&User{}).
+			Where("id = ? AND password = ?", user.ID, user.Password).
+			Update("password", hashedPassword)
+		if result.Error != nil {
+			u.logger.WithField("err", result.Error).Error("Failed to store rehashed password")
+			return true, nil
+		}
+		u.logger.WithField("user_id", user.ID).Info("Rehashed outdated password hash")
+		user.Password = hashedPassword
+	}
+	return true, nil
 }
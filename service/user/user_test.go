@@ -0,0 +1,126 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dominiclet/golang-base/init_server/config"
+	"github.com/dominiclet/golang-base/init_server/env"
+	"github.com/dominiclet/golang-base/lib/dbtest"
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/lib/password"
+	"github.com/dominiclet/golang-base/service/outbox"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Cheap hashing parameters to keep tests fast
+func testConfig(algorithm string) *config.Config {
+	return &config.Config{
+		Domain: "example.com",
+		Email: config.Email{
+			Transport:    email.TransportMemory,
+			EmailAddress: "noreply@example.com",
+			// Keep queued messages in the DB, so that tests can check them
+			Outbox: config.EmailOutbox{Workers: 1, PollIntervalSeconds: 3600},
+		},
+		PasswordHashing: config.PasswordHashing{
+			Algorithm:         algorithm,
+			Argon2Memory:      64,
+			Argon2Iterations:  1,
+			Argon2Parallelism: 1,
+			BcryptCost:        4,
+		},
+	}
+}
+
+func newTestUserServiceWithDB(db *gorm.DB, conf *config.Config) *UserService {
+	emailService := email.InitEmailService(conf, &env.EnvVars{}, nil)
+	outboxService := outbox.InitOutboxService(db, emailService, conf)
+	return InitUserService(db, emailService, outboxService, password.InitPolicy(conf), password.InitHasher(conf))
+}
+
+func newTestUserService(t *testing.T) (*UserService, *gorm.DB) {
+	db := dbtest.Open(t, &User{}, &PasswordResetToken{}, &outbox.OutboxMessage{})
+	return newTestUserServiceWithDB(db, testConfig(password.AlgorithmArgon2id)), db
+}
+
+func createTestUser(t *testing.T, u *UserService, db *gorm.DB, pw string) *User {
+	hashedPassword, err := u.hashPassword(pw)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	user := &User{
+		Name:          "Test",
+		Uuid:          uuid.NewString(),
+		Email:         "test@example.com",
+		Password:      hashedPassword,
+		AccountType:   TrialAccount,
+		IsVerified:    true,
+		LicenseExpiry: time.Now().Add(24 * time.Hour),
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return user
+}
+
+func reloadUser(t *testing.T, db *gorm.DB, user *User) *User {
+	var reloaded User
+	if err := db.First(&reloaded, user.ID).Error; err != nil {
+		t.Fatalf("Failed to reload user: %v", err)
+	}
+	return &reloaded
+}
+
+// Hashes of the previous algorithm are replaced on login
+func TestCheckPasswordRehash(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       string
+		to         string
+		wantPrefix string
+	}{
+		{"Bcrypt to argon2id", password.AlgorithmBcrypt, password.AlgorithmArgon2id, "$argon2id$"},
+		{"Argon2id to bcrypt", password.AlgorithmArgon2id, password.AlgorithmBcrypt, "$2a$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.Open(t, &User{}, &PasswordResetToken{}, &outbox.OutboxMessage{})
+			user := createTestUser(t, newTestUserServiceWithDB(db, testConfig(tt.from)), db, "correct-horse7")
+			oldHash := user.Password
+			u := newTestUserServiceWithDB(db, testConfig(tt.to))
+
+			ok, err := u.CheckPassword(context.Background(), user, "wrong-horse7")
+			if err != nil || ok {
+				t.Fatalf("Wrong password: ok %v, err %v", ok, err)
+			}
+			if reloadUser(t, db, user).Password != oldHash {
+				t.Fatal("Hash replaced after wrong password")
+			}
+
+			ok, err = u.CheckPassword(context.Background(), user, "correct-horse7")
+			if err != nil || !ok {
+				t.Fatalf("Correct password: ok %v, err %v", ok, err)
+			}
+			stored := reloadUser(t, db, user).Password
+			if !strings.HasPrefix(stored, tt.wantPrefix) || user.Password != stored {
+				t.Fatalf("Stored hash %q, user hash %q, want prefix %q", stored, user.Password, tt.wantPrefix)
+			}
+
+			ok, err = u.CheckPassword(context.Background(), user, "correct-horse7")
+			if err != nil || !ok || reloadUser(t, db, user).Password != stored {
+				t.Errorf("Login after rehash: ok %v, err %v, hash changed again", ok, err)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordWithoutUser(t *testing.T) {
+	u, _ := newTestUserService(t)
+	if ok, err := u.CheckPassword(context.Background(), nil, "correct-horse7"); err != nil || ok {
+		t.Errorf("Nil user: ok %v, err %v", ok, err)
+	}
+}
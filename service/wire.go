@@ -1,9 +1,34 @@
 package service
 
 import (
+	"github.com/dominiclet/golang-base/lib/email"
+	"github.com/dominiclet/golang-base/service/audit"
+	"github.com/dominiclet/golang-base/service/billing"
+	"github.com/dominiclet/golang-base/service/entitlement"
+	"github.com/dominiclet/golang-base/service/identity"
+	"github.com/dominiclet/golang-base/service/metering"
+	"github.com/dominiclet/golang-base/service/notification"
+	"github.com/dominiclet/golang-base/service/oauth"
+	"github.com/dominiclet/golang-base/service/outbox"
 	"github.com/dominiclet/golang-base/service/session"
+	"github.com/dominiclet/golang-base/service/suppression"
+	"github.com/dominiclet/golang-base/service/twofactor"
 	"github.com/dominiclet/golang-base/service/user"
 	"github.com/google/wire"
 )
 
-var ServiceSet = wire.NewSet(user.InitUserService, session.InitSessionService)
+var ServiceSet = wire.NewSet(
+	user.InitUserService,
+	session.InitSessionService,
+	entitlement.InitEntitlementService,
+	metering.InitMeteringService,
+	billing.InitBillingService,
+	twofactor.InitTwoFactorService,
+	identity.InitIdentityService,
+	oauth.InitOAuthService,
+	audit.InitAuditService,
+	outbox.InitOutboxService,
+	suppression.InitSuppressionService,
+	notification.InitNotificationService,
+	wire.Bind(new(email.SuppressionList), new(*suppression.SuppressionService)),
+)